@@ -23,6 +23,7 @@ module.exports = {
         "exit",
         "git",
         "golang",
+        "jj",
         "julia",
         "kubectl",
         "node",
@@ -33,9 +34,11 @@ module.exports = {
         "session",
         "shell",
         "spotify",
+        "sysinfo",
         "terraform",
         "text",
         "time",
+        "tmux",
         "ytm",
       ]
     },
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gookit/color"
@@ -50,6 +51,35 @@ func getColorFromName(colorName string, isBackground bool) (string, error) {
 	return "", errors.New("color name does not exist")
 }
 
+// getColorFromIndex returns the color code for one of the 256 ANSI colors, like 208
+func getColorFromIndex(colorString string, isBackground bool) (string, bool) {
+	index, err := strconv.Atoi(colorString)
+	if err != nil || index < 0 || index > 255 {
+		return "", false
+	}
+	if isBackground {
+		return fmt.Sprintf("48;5;%d", index), true
+	}
+	return fmt.Sprintf("38;5;%d", index), true
+}
+
+// Returns true when the color string can be rendered,
+// either a hex color (`#FFFFFF` or `#FFF`), an ANSI color index (`0`-`255`), a color name, `transparent` or `auto`.
+// Palette references (`p:accent`) are resolved before, the ones left are unknown
+func isValidColor(colorString string) bool {
+	if colorString == Transparent || colorString == Auto {
+		return true
+	}
+	if _, err := getColorFromName(colorString, false); err == nil {
+		return true
+	}
+	if _, ok := getColorFromIndex(colorString, false); ok {
+		return true
+	}
+	values := findNamedRegexMatch(`^(?P<color>#([A-Fa-f0-9]{6}|[A-Fa-f0-9]{3}))$`, colorString)
+	return values["color"] != ""
+}
+
 // AnsiColor writes colorized strings
 type AnsiColor struct {
 	buffer  *bytes.Buffer
@@ -59,6 +89,10 @@ type AnsiColor struct {
 const (
 	// Transparent implies a transparent color
 	Transparent = "transparent"
+	// Inherit takes the color from the block the segment is part of
+	Inherit = "inherit"
+	// Auto picks a black or white foreground, whichever contrasts most with the background
+	Auto = "auto"
 )
 
 func (a *AnsiColor) init(shell string) {
@@ -80,19 +114,44 @@ func (a *AnsiColor) init(shell string) {
 }
 
 // Gets the ANSI color code for a given color string.
-// This can include a valid hex color in the format `#FFFFFF`,
+// This can include a valid hex color in the format `#FFFFFF`, an index of the 256 ANSI colors,
 // but also a name of one of the first 16 ANSI colors like `lightBlue`.
 func (a *AnsiColor) getAnsiFromColorString(colorString string, isBackground bool) string {
+	if colorString == Auto {
+		colorString = "default"
+	}
 	colorFromName, err := getColorFromName(colorString, isBackground)
 	if err == nil {
 		return colorFromName
 	}
+	if colorFromIndex, ok := getColorFromIndex(colorString, isBackground); ok {
+		return colorFromIndex
+	}
 	style := color.HEX(colorString, isBackground)
 	return style.Code()
 }
 
+// contrastForeground returns black or white for an auto foreground, whichever is the most readable on the background.
+// Without a hex background to compare to, the terminal's default foreground is used
+func contrastForeground(background, foreground string) string {
+	if foreground != Auto {
+		return foreground
+	}
+	rgb, ok := parseHexColor(background)
+	if !ok || !strings.HasPrefix(background, "#") {
+		return "default"
+	}
+	// perceived brightness, ITU-R BT.601
+	brightness := 0.299*float64(rgb[0]) + 0.587*float64(rgb[1]) + 0.114*float64(rgb[2])
+	if brightness >= 128 {
+		return "black"
+	}
+	return "white"
+}
+
 func (a *AnsiColor) writeColoredText(background, foreground, text string) {
 	var coloredText string
+	foreground = contrastForeground(background, foreground)
 	if foreground == Transparent && background != "" {
 		ansiColor := a.getAnsiFromColorString(background, false)
 		coloredText = fmt.Sprintf(a.formats.transparent, ansiColor, text)
@@ -220,3 +220,55 @@ func TestGetAnsiFromColorStringInvalidBg(t *testing.T) {
 	// then
 	assert.Equal(t, "", colorCode)
 }
+
+func TestIsValidColor(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Color    string
+		Expected bool
+	}{
+		{Case: "Hex", Color: "#CB4B16", Expected: true},
+		{Case: "Short hex", Color: "#fff", Expected: true},
+		{Case: "Color name", Color: "lightBlue", Expected: true},
+		{Case: "Transparent", Color: Transparent, Expected: true},
+		{Case: "Auto", Color: Auto, Expected: true},
+		{Case: "ANSI index", Color: "208", Expected: true},
+		{Case: "First ANSI index", Color: "0", Expected: true},
+		{Case: "ANSI index out of range", Color: "256", Expected: false},
+		{Case: "Negative ANSI index", Color: "-1", Expected: false},
+		{Case: "Unresolved palette reference", Color: "p:accent", Expected: false},
+		{Case: "Hex without hash", Color: "CB4B16", Expected: false},
+		{Case: "Invalid hex", Color: "#zzzzzz", Expected: false},
+		{Case: "Hex too long", Color: "#CB4B16A", Expected: false},
+		{Case: "Unknown name", Color: "lightPurple", Expected: false},
+		{Case: "Garbage", Color: "garbage", Expected: false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, isValidColor(tc.Color), tc.Case)
+	}
+}
+
+func TestGetAnsiFromColorStringIndex(t *testing.T) {
+	a := &AnsiColor{}
+	assert.Equal(t, "38;5;208", a.getAnsiFromColorString("208", false))
+	assert.Equal(t, "48;5;208", a.getAnsiFromColorString("208", true))
+	assert.Equal(t, "39", a.getAnsiFromColorString(Auto, false))
+}
+
+func TestContrastForeground(t *testing.T) {
+	cases := []struct {
+		Case       string
+		Background string
+		Foreground string
+		Expected   string
+	}{
+		{Case: "Light background", Background: "#ffeb3b", Foreground: Auto, Expected: "black"},
+		{Case: "Dark background", Background: "#193549", Foreground: Auto, Expected: "white"},
+		{Case: "Named background", Background: "blue", Foreground: Auto, Expected: "default"},
+		{Case: "No background", Foreground: Auto, Expected: "default"},
+		{Case: "Not auto", Background: "#ffeb3b", Foreground: "red", Expected: "red"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, contrastForeground(tc.Background, tc.Foreground), tc.Case)
+	}
+}
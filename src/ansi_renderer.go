@@ -38,6 +38,8 @@ type formats struct {
 	clearOEL              string
 	saveCursorPosition    string
 	restoreCursorPosition string
+	clearLine             string
+	osc7                  string
 }
 
 // AnsiRenderer exposes functionality using ANSI
@@ -56,6 +58,7 @@ func (r *AnsiRenderer) init(shell string) {
 		r.formats.left = "%%{\x1b[%dC%%}"
 		r.formats.right = "%%{\x1b[%dD%%}"
 		r.formats.title = "%%{\033]0;%s\007%%}"
+		r.formats.osc7 = "%%{\x1b]7;%s\007%%}"
 		r.formats.creset = "%{\x1b[0m%}"
 		r.formats.clearOEL = "%{\x1b[K%}"
 		r.formats.saveCursorPosition = "%{\x1b7%}"
@@ -65,6 +68,7 @@ func (r *AnsiRenderer) init(shell string) {
 		r.formats.left = "\\[\x1b[%dC\\]"
 		r.formats.right = "\\[\x1b[%dD\\]"
 		r.formats.title = "\\[\033]0;%s\007\\]"
+		r.formats.osc7 = "\\[\x1b]7;%s\007\\]"
 		r.formats.creset = "\\[\x1b[0m\\]"
 		r.formats.clearOEL = "\\[\x1b[K\\]"
 		r.formats.saveCursorPosition = "\\[\x1b7\\]"
@@ -74,11 +78,20 @@ func (r *AnsiRenderer) init(shell string) {
 		r.formats.left = "\x1b[%dC"
 		r.formats.right = "\x1b[%dD"
 		r.formats.title = "\033]0;%s\007"
+		r.formats.osc7 = "\x1b]7;%s\007"
 		r.formats.creset = "\x1b[0m"
 		r.formats.clearOEL = "\x1b[K"
 		r.formats.saveCursorPosition = "\x1b7"
 		r.formats.restoreCursorPosition = "\x1b8"
 	}
+	// PSReadLine can leave artifacts on the line when the prompt re-renders
+	if shell == pwsh || shell == powershell5 {
+		r.formats.clearLine = "\x1b[2K\r"
+	}
+}
+
+func (r *AnsiRenderer) clearLine() {
+	r.buffer.WriteString(r.formats.clearLine)
 }
 
 func (r *AnsiRenderer) carriageForward() {
@@ -103,6 +116,15 @@ func (r *AnsiRenderer) setConsoleTitle(title string) {
 	r.buffer.WriteString(fmt.Sprintf(r.formats.title, title))
 }
 
+// setOSC7 reports the working directory location to the terminal
+func (r *AnsiRenderer) setOSC7(location string) {
+	if r.shell == zsh {
+		// zsh expands % in the prompt, escape the encoded characters
+		location = strings.ReplaceAll(location, "%", "%%")
+	}
+	r.buffer.WriteString(fmt.Sprintf(r.formats.osc7, location))
+}
+
 func (r *AnsiRenderer) creset() {
 	r.buffer.WriteString(r.formats.creset)
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheFileName = "omp.cache"
+	// cacheEntryTTL is how long an entry is kept without being set again, like the status of a removed repository
+	cacheEntryTTL = 7 * 24 * time.Hour
+	// cacheLockTimeout is the age after which the lock of an interrupted write is abandoned
+	cacheLockTimeout = 5 * time.Second
+	cacheLockRetries = 10
+	cacheLockDelay   = 10 * time.Millisecond
+)
+
+// cacheRefreshers update the cache entry for a key, used by --refresh-cache.
+// The key can carry an argument for the refresher after a colon: git_fetch:/path/to/repo
+var cacheRefreshers = map[string]func(env environmentInfo, argument string){
+	batteryCacheKey: func(env environmentInfo, _ string) {
+		_, _ = cacheBatteryInfo(env)
+	},
+	gitFetchCacheKey: func(env environmentInfo, root string) {
+		gitFetch(env, root, newFileLock(gitFetchLockPath(root), gitFetchLockTimeout))
+	},
+	gitUserCacheKey:    gitUser,
+	gitCompareCacheKey: gitCompare,
+}
+
+func refreshCache(env environmentInfo, key string) {
+	name := key
+	var argument string
+	if index := strings.Index(key, ":"); index != -1 {
+		name, argument = key[:index], key[index+1:]
+	}
+	if refresh, ok := cacheRefreshers[name]; ok {
+		refresh(env, argument)
+	}
+}
+
+type cache interface {
+	// get returns the cached value for key and how long ago it was stored
+	get(key string) (string, time.Duration, bool)
+	set(key, value string)
+}
+
+type cacheEntry struct {
+	Value     string    `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// fileCache persists the entries as json in a single file,
+// every set writes the file so other prompts can pick up the value
+type fileCache struct {
+	path     string
+	now      func() time.Time
+	entries  map[string]*cacheEntry
+	lock     sync.RWMutex
+	fileLock *fileLock
+}
+
+func newFileCache(folder string) *fileCache {
+	path := filepath.Join(folder, cacheFileName)
+	fc := &fileCache{
+		path:     path,
+		now:      time.Now,
+		fileLock: newFileLock(path+".lock", cacheLockTimeout),
+	}
+	fc.entries = fc.read()
+	return fc
+}
+
+func (fc *fileCache) read() map[string]*cacheEntry {
+	entries := make(map[string]*cacheEntry)
+	content, err := ioutil.ReadFile(fc.path)
+	if err != nil {
+		return entries
+	}
+	if err = json.Unmarshal(content, &entries); err != nil || entries == nil {
+		return make(map[string]*cacheEntry)
+	}
+	return entries
+}
+
+func (fc *fileCache) get(key string) (string, time.Duration, bool) {
+	fc.lock.RLock()
+	defer fc.lock.RUnlock()
+	entry, found := fc.entries[key]
+	if !found {
+		return "", 0, false
+	}
+	return entry.Value, fc.now().Sub(entry.Timestamp), true
+}
+
+// set stores the value and writes the file while holding the lock file. Entries other prompts wrote
+// since the file was read are merged first, entries which weren't set for cacheEntryTTL are dropped.
+// When the lock can't be acquired, the value is only kept in memory.
+func (fc *fileCache) set(key, value string) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	now := fc.now()
+	fc.entries[key] = &cacheEntry{
+		Value:     value,
+		Timestamp: now,
+	}
+	if !fc.acquireFileLock() {
+		return
+	}
+	defer fc.fileLock.unlock()
+	for k, entry := range fc.read() {
+		if current, found := fc.entries[k]; !found || entry.Timestamp.After(current.Timestamp) {
+			fc.entries[k] = entry
+		}
+	}
+	for k, entry := range fc.entries {
+		if now.Sub(entry.Timestamp) > cacheEntryTTL {
+			delete(fc.entries, k)
+		}
+	}
+	content, err := json.Marshal(fc.entries)
+	if err != nil {
+		return
+	}
+	fc.write(content)
+}
+
+func (fc *fileCache) acquireFileLock() bool {
+	for i := 0; i < cacheLockRetries; i++ {
+		if fc.fileLock.tryLock() {
+			return true
+		}
+		time.Sleep(cacheLockDelay)
+	}
+	return false
+}
+
+// write replaces the file by renaming a temporary one, a prompt reading it never sees a partial write
+func (fc *fileCache) write(content []byte) {
+	folder := filepath.Dir(fc.path)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return
+	}
+	file, err := ioutil.TempFile(folder, cacheFileName+".*")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fc.path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFileCache(t *testing.T, now time.Time) (*fileCache, func()) {
+	folder, err := ioutil.TempDir("", "omp")
+	assert.NoError(t, err)
+	fc := newFileCache(folder)
+	fc.now = func() time.Time { return now }
+	return fc, func() { _ = os.RemoveAll(folder) }
+}
+
+func TestFileCacheGetMissing(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	_, _, found := fc.get("missing")
+	assert.False(t, found)
+}
+
+func TestFileCacheSetGet(t *testing.T) {
+	now := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	fc.set("key", "value")
+	fc.now = func() time.Time { return now.Add(90 * time.Second) }
+	value, age, found := fc.get("key")
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, 90*time.Second, age)
+}
+
+func TestFileCachePersisted(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	fc.set("key", "value")
+	reloaded := newFileCache(filepath.Dir(fc.path))
+	value, _, found := reloaded.get("key")
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+}
+
+func TestFileCacheCorrupt(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(fc.path, []byte("{garbage"), 0644))
+	reloaded := newFileCache(filepath.Dir(fc.path))
+	_, _, found := reloaded.get("key")
+	assert.False(t, found)
+	reloaded.set("key", "value")
+	_, _, found = reloaded.get("key")
+	assert.True(t, found)
+}
+
+func TestFileCacheMergesOtherWrites(t *testing.T) {
+	now := time.Now()
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	other := newFileCache(filepath.Dir(fc.path))
+	other.now = func() time.Time { return now.Add(time.Second) }
+	fc.set("first", "value")
+	other.set("second", "value")
+	fc.set("third", "value")
+	reloaded := newFileCache(filepath.Dir(fc.path))
+	for _, key := range []string{"first", "second", "third"} {
+		_, _, found := reloaded.get(key)
+		assert.True(t, found, key)
+	}
+}
+
+func TestFileCacheEvictsExpiredEntries(t *testing.T) {
+	now := time.Now()
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	fc.set("git_status_/removed/repo", "# branch.head main")
+	fc.now = func() time.Time { return now.Add(cacheEntryTTL + time.Hour) }
+	fc.set("battery", "{}")
+	_, _, found := fc.get("git_status_/removed/repo")
+	assert.False(t, found)
+	reloaded := newFileCache(filepath.Dir(fc.path))
+	_, _, found = reloaded.get("git_status_/removed/repo")
+	assert.False(t, found)
+	_, _, found = reloaded.get("battery")
+	assert.True(t, found)
+}
+
+func TestFileCacheWriteLeavesNoTemporaryFiles(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	fc.set("key", "value")
+	fc.set("key", "other value")
+	files, err := ioutil.ReadDir(filepath.Dir(fc.path))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, cacheFileName, files[0].Name())
+}
+
+func TestFileCacheLocked(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	assert.True(t, fc.fileLock.tryLock())
+	fc.set("key", "value")
+	value, _, found := fc.get("key")
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+	fc.fileLock.unlock()
+	reloaded := newFileCache(filepath.Dir(fc.path))
+	_, _, found = reloaded.get("key")
+	assert.False(t, found)
+}
+
+func TestRefreshCacheArgument(t *testing.T) {
+	var got string
+	cacheRefreshers["test"] = func(env environmentInfo, argument string) {
+		got = argument
+	}
+	defer delete(cacheRefreshers, "test")
+	refreshCache(nil, "test:C:\\Users\\jan\\repo")
+	assert.Equal(t, "C:\\Users\\jan\\repo", got)
+	refreshCache(nil, "test")
+	assert.Empty(t, got)
+}
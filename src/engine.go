@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 )
 
@@ -94,6 +96,13 @@ func (e *engine) renderBlockSegments(block *Block) string {
 	defer e.resetBlock()
 	e.activeBlock = block
 	e.setStringValues(block.Segments)
+	var count int
+	for _, segment := range block.Segments {
+		if segment.active {
+			count++
+		}
+	}
+	var position int
 	for _, segment := range block.Segments {
 		if !segment.active {
 			continue
@@ -102,6 +111,10 @@ func (e *engine) renderBlockSegments(block *Block) string {
 		e.endPowerline()
 		text := segment.stringValue
 		e.activeSegment.Background = segment.props.background
+		if e.activeSegment.Background == "" || e.activeSegment.Background == Inherit {
+			e.activeSegment.Background = block.inheritedBackground(position, count)
+		}
+		position++
 		e.activeSegment.Foreground = segment.props.foreground
 		e.renderSegmentText(text)
 	}
@@ -112,20 +125,57 @@ func (e *engine) renderBlockSegments(block *Block) string {
 }
 
 func (e *engine) setStringValues(segments []*Segment) {
-	wg := sync.WaitGroup{}
-	wg.Add(len(segments))
-	defer wg.Wait()
 	cwd := e.env.getcwd()
 	debug := *e.env.getArgs().Debug
+	// segments referencing other segments have to wait until those are rendered,
+	// they render one after the other in order of declaration
+	var dependents []*Segment
+	wg := sync.WaitGroup{}
 	for _, segment := range segments {
+		segment.nerdFontVersion = e.settings.NerdFontVersion
+		segment.icons = e.settings.icons
+		segment.caseSensitiveGlobs = e.settings.CaseSensitiveGlobs
+		if e.activeBlock != nil {
+			segment.blockForeground = e.activeBlock.Foreground
+		}
+		if segment.referencesSegments() {
+			dependents = append(dependents, segment)
+			continue
+		}
+		wg.Add(1)
 		go func(s *Segment) {
 			defer wg.Done()
 			s.setStringValue(e.env, cwd, debug)
 		}(segment)
 	}
+	wg.Wait()
+	for _, segment := range dependents {
+		segment.renderedSegments = e.getRenderedSegments(segment)
+		segment.setStringValue(e.env, cwd, debug)
+	}
+}
+
+// getRenderedSegments returns the writers of the active segments declared before the given segment,
+// segments declared after it are not rendered yet and thus not available
+func (e *engine) getRenderedSegments(current *Segment) map[string]SegmentWriter {
+	rendered := make(map[string]SegmentWriter)
+	for _, block := range e.settings.Blocks {
+		for _, segment := range block.Segments {
+			if segment == current {
+				return rendered
+			}
+			if segment.active {
+				rendered[string(segment.Type)] = segment.writer
+			}
+		}
+	}
+	return rendered
 }
 
 func (e *engine) render() {
+	if e.settings.ClearLine {
+		e.renderer.clearLine()
+	}
 	for _, block := range e.settings.Blocks {
 		// if line break, append a line break
 		switch block.Type {
@@ -158,6 +208,12 @@ func (e *engine) render() {
 			e.renderer.setConsoleTitle(base(e.env.getcwd(), e.env))
 		}
 	}
+	if e.settings.OSC7 {
+		e.renderer.setOSC7(osc7Location(e.env))
+	}
+	if *e.env.getArgs().Debug {
+		e.renderTemplateModels()
+	}
 	e.renderer.creset()
 	if e.settings.FinalSpace {
 		e.renderer.print(" ")
@@ -165,6 +221,47 @@ func (e *engine) render() {
 	e.write()
 }
 
+// renderTemplateModels prints the data available to the templates of every segment
+// with debug_template enabled below the prompt, one JSON object per segment
+func (e *engine) renderTemplateModels() {
+	for _, block := range e.settings.Blocks {
+		for _, segment := range block.Segments {
+			if segment.writer == nil || !segment.props.getBool(DebugTemplate, false) {
+				continue
+			}
+			e.renderer.print(fmt.Sprintf("\n%s: %s", segment.Type, dumpTemplateModel(segment.writer)))
+		}
+	}
+}
+
+// renderSecondaryPrompt renders the continuation prompt shells display
+// when a command spans multiple lines
+func (e *engine) renderSecondaryPrompt() string {
+	block := e.settings.SecondaryPrompt
+	if block == nil || len(block.Segments) == 0 {
+		block = getDefaultSecondaryPrompt()
+	}
+	e.renderer.print(e.renderBlockSegments(block))
+	e.renderer.creset()
+	return e.renderer.string()
+}
+
+// osc7Location returns the working directory as a file URL,
+// Windows paths like C:\Users become file://host/C:/Users
+func osc7Location(env environmentInfo) string {
+	host, _ := env.getHostName()
+	pwd := strings.TrimPrefix(env.getcwd(), "Microsoft.PowerShell.Core\\FileSystem::")
+	if env.getRuntimeGOOS() == windowsPlatform {
+		pwd = "/" + strings.ReplaceAll(pwd, "\\", "/")
+	}
+	location := &url.URL{
+		Scheme: "file",
+		Host:   host,
+		Path:   pwd,
+	}
+	return location.String()
+}
+
 func (e *engine) write() {
 	switch e.env.getShellName() {
 	case zsh:
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStringValuesReferencesEarlierSegment(t *testing.T) {
+	debug := false
+	env := new(MockedEnvironment)
+	env.On("getcwd", nil).Return("/usr/home")
+	env.On("getArgs", nil).Return(&args{Debug: &debug})
+	block := &Block{
+		Segments: []*Segment{
+			{
+				Type: Text,
+				Properties: map[Property]interface{}{
+					TextProperty: "{{ if .Segments.git.Dirty }}dirty{{ end }}",
+				},
+			},
+			{
+				Type: Text,
+				Properties: map[Property]interface{}{
+					TextProperty: "hello",
+				},
+			},
+			{
+				Type: Text,
+				Properties: map[Property]interface{}{
+					TextProperty: "{{ if .Segments.text }}after text{{ end }}",
+				},
+			},
+			{
+				Type: EnvVar,
+				Properties: map[Property]interface{}{
+					VarName: "HELLO",
+				},
+			},
+		},
+	}
+	env.On("getenv", "HELLO").Return("world")
+	engine := &engine{
+		settings: &Settings{
+			Blocks: []*Block{block},
+		},
+		env: env,
+	}
+	engine.setStringValues(block.Segments)
+	assert.False(t, block.Segments[0].active)
+	assert.Equal(t, "hello", block.Segments[1].stringValue)
+	assert.Equal(t, "after text", block.Segments[2].stringValue)
+	assert.True(t, block.Segments[3].active)
+}
+
+func TestGetRenderedSegments(t *testing.T) {
+	git := &Segment{Type: Git, active: true, writer: &git{}}
+	path := &Segment{Type: Path, active: false, writer: &path{}}
+	current := &Segment{Type: Text}
+	later := &Segment{Type: Exit, active: true, writer: &exit{}}
+	engine := &engine{
+		settings: &Settings{
+			Blocks: []*Block{
+				{Segments: []*Segment{git}},
+				{Segments: []*Segment{path, current, later}},
+			},
+		},
+	}
+	rendered := engine.getRenderedSegments(current)
+	assert.Len(t, rendered, 1)
+	assert.Equal(t, git.writer, rendered["git"])
+}
+
+func bootStrapEngineTest(settings *Settings, shell string) *engine {
+	debug := false
+	eval := false
+	env := new(MockedEnvironment)
+	env.On("getcwd", nil).Return("/usr/home")
+	env.On("getArgs", nil).Return(&args{Debug: &debug, Eval: &eval})
+	renderer := &AnsiRenderer{
+		buffer: new(bytes.Buffer),
+	}
+	colorer := &AnsiColor{
+		buffer: new(bytes.Buffer),
+	}
+	renderer.init(shell)
+	colorer.init(shell)
+	return &engine{
+		settings: settings,
+		env:      env,
+		color:    colorer,
+		renderer: renderer,
+	}
+}
+
+func TestRenderSecondaryPrompt(t *testing.T) {
+	settings := &Settings{
+		SecondaryPrompt: &Block{
+			Type:      Prompt,
+			Alignment: Left,
+			Segments: []*Segment{
+				{
+					Type:       Text,
+					Style:      Plain,
+					Foreground: "#ffffff",
+					Properties: map[Property]interface{}{
+						TextProperty: "continue",
+					},
+				},
+			},
+		},
+	}
+	engine := bootStrapEngineTest(settings, "shell")
+	got := engine.renderSecondaryPrompt()
+	assert.Contains(t, got, " continue ")
+}
+
+func TestRenderSecondaryPromptNotConfigured(t *testing.T) {
+	engine := bootStrapEngineTest(&Settings{}, "shell")
+	got := engine.renderSecondaryPrompt()
+	assert.Contains(t, got, ">")
+	assert.NotContains(t, got, " >")
+}
+
+func TestRenderClearLine(t *testing.T) {
+	cases := []struct {
+		Case      string
+		Shell     string
+		ClearLine bool
+		Expected  bool
+	}{
+		{Case: "pwsh enabled", Shell: pwsh, ClearLine: true, Expected: true},
+		{Case: "powershell enabled", Shell: powershell5, ClearLine: true, Expected: true},
+		{Case: "pwsh disabled", Shell: pwsh},
+		{Case: "bash enabled", Shell: bash, ClearLine: true},
+		{Case: "zsh enabled", Shell: zsh, ClearLine: true},
+	}
+	for _, tc := range cases {
+		engine := bootStrapEngineTest(&Settings{ClearLine: tc.ClearLine}, tc.Shell)
+		env := engine.env.(*MockedEnvironment)
+		env.On("getShellName", nil).Return(tc.Shell)
+		stdout := os.Stdout
+		_, os.Stdout, _ = os.Pipe()
+		engine.render()
+		os.Stdout = stdout
+		got := strings.HasPrefix(engine.renderer.string(), "\x1b[2K\r")
+		assert.Equal(t, tc.Expected, got, tc.Case)
+	}
+}
+
+func TestRenderOSC7(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Shell    string
+		GOOS     string
+		Pwd      string
+		Expected string
+	}{
+		{Case: "Unix", GOOS: "linux", Pwd: "/home/jan/code", Expected: "\x1b]7;file://omp/home/jan/code\007"},
+		{Case: "Unix spaces", GOOS: "linux", Pwd: "/home/jan/my code", Expected: "\x1b]7;file://omp/home/jan/my%20code\007"},
+		{Case: "Windows", GOOS: windowsPlatform, Pwd: "C:\\Users\\jan", Expected: "\x1b]7;file://omp/C:/Users/jan\007"},
+		{
+			Case:     "Windows PowerShell provider",
+			GOOS:     windowsPlatform,
+			Pwd:      "Microsoft.PowerShell.Core\\FileSystem::C:\\Users\\jan",
+			Expected: "\x1b]7;file://omp/C:/Users/jan\007",
+		},
+		{Case: "zsh", Shell: zsh, GOOS: "darwin", Pwd: "/Users/jan/my code", Expected: "%{\x1b]7;file://omp/Users/jan/my%%20code\007%}"},
+		{Case: "bash", Shell: bash, GOOS: "linux", Pwd: "/home/jan", Expected: "\\[\x1b]7;file://omp/home/jan\007\\]"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getHostName", nil).Return("omp", nil)
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("getRuntimeGOOS", nil).Return(tc.GOOS)
+		renderer := &AnsiRenderer{
+			buffer: new(bytes.Buffer),
+		}
+		renderer.init(tc.Shell)
+		renderer.setOSC7(osc7Location(env))
+		assert.Equal(t, tc.Expected, renderer.string(), tc.Case)
+	}
+}
+
+func TestRenderBlockSegmentColors(t *testing.T) {
+	textSegment := func(text, background string) *Segment {
+		return &Segment{
+			Type:       Text,
+			Style:      Powerline,
+			Background: background,
+			Properties: map[Property]interface{}{
+				TextProperty: text,
+			},
+		}
+	}
+	block := &Block{
+		Type:          Prompt,
+		Alignment:     Left,
+		SegmentColors: []string{"#ff0000", "#00ff00"},
+		Segments: []*Segment{
+			textSegment("one", ""),
+			{
+				Type: Text,
+				Properties: map[Property]interface{}{
+					TextProperty:    "disabled",
+					EnabledTemplate: "false",
+				},
+			},
+			textSegment("two", "#ffffff"),
+			textSegment("three", ""),
+			textSegment("four", ""),
+		},
+	}
+	engine := bootStrapEngineTest(&Settings{Blocks: []*Block{block}}, "shell")
+	env := engine.env.(*MockedEnvironment)
+	env.On("environ", nil).Return(map[string]string{})
+	env.On("getShellName", nil).Return("shell")
+	_ = engine.renderBlockSegments(block)
+	// the disabled segment takes no position, explicit backgrounds do
+	assert.Equal(t, "#ff0000", block.Segments[0].Background)
+	assert.Equal(t, "#ffffff", block.Segments[2].Background)
+	assert.Equal(t, "#ff0000", block.Segments[3].Background)
+	assert.Equal(t, "#00ff00", block.Segments[4].Background)
+}
+
+func TestRenderBlockSegmentsInherit(t *testing.T) {
+	textSegment := func(text, foreground, background string) *Segment {
+		return &Segment{
+			Type:       Text,
+			Style:      Plain,
+			Foreground: foreground,
+			Background: background,
+			Properties: map[Property]interface{}{
+				TextProperty: text,
+			},
+		}
+	}
+	block := &Block{
+		Type:       Prompt,
+		Alignment:  Left,
+		Foreground: "#ffffff",
+		Background: "#000000",
+		Segments: []*Segment{
+			textSegment("inherit", Inherit, Inherit),
+			textSegment("override", "#ff0000", "#00ff00"),
+			textSegment("empty", "", ""),
+		},
+	}
+	engine := bootStrapEngineTest(&Settings{Blocks: []*Block{block}}, "shell")
+	_ = engine.renderBlockSegments(block)
+	assert.Equal(t, "#ffffff", block.Segments[0].Foreground)
+	assert.Equal(t, "#000000", block.Segments[0].Background)
+	assert.Equal(t, "#ff0000", block.Segments[1].Foreground)
+	assert.Equal(t, "#00ff00", block.Segments[1].Background)
+	assert.Equal(t, "#ffffff", block.Segments[2].Foreground)
+	assert.Equal(t, "#000000", block.Segments[2].Background)
+}
+
+func TestRenderBlockGradientBackground(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Segments []string
+		Expected []string
+	}{
+		{Case: "Three segments", Segments: []string{"one", "two", "three"}, Expected: []string{"#000000", "#808080", "#ffffff"}},
+		{Case: "Single segment", Segments: []string{"one"}, Expected: []string{"#000000"}},
+	}
+	for _, tc := range cases {
+		block := &Block{
+			Type:      Prompt,
+			Alignment: Left,
+			GradientBackground: &GradientBackground{
+				From: "#000000",
+				To:   "#ffffff",
+			},
+		}
+		for _, text := range tc.Segments {
+			block.Segments = append(block.Segments, &Segment{
+				Type:  Text,
+				Style: Powerline,
+				Properties: map[Property]interface{}{
+					TextProperty: text,
+				},
+			})
+		}
+		engine := bootStrapEngineTest(&Settings{Blocks: []*Block{block}}, "shell")
+		_ = engine.renderBlockSegments(block)
+		for i, expected := range tc.Expected {
+			assert.Equal(t, expected, block.Segments[i].Background, tc.Case)
+		}
+	}
+}
+
+func TestRenderTemplateModels(t *testing.T) {
+	jj := &jujutsu{ChangeId: "kxqvopwn"}
+	settings := &Settings{
+		Blocks: []*Block{
+			{
+				Segments: []*Segment{
+					{Type: Jujutsu, writer: jj, props: &properties{values: map[Property]interface{}{DebugTemplate: true}}},
+					{Type: Text, writer: &text{}, props: &properties{values: map[Property]interface{}{}}},
+				},
+			},
+		},
+	}
+	engine := bootStrapEngineTest(settings, "")
+	engine.renderTemplateModels()
+	expected := "\njj: {\n  \"Bookmark\": \"\",\n  \"ChangeId\": \"kxqvopwn\",\n  \"Dirty\": false\n}"
+	assert.True(t, strings.HasPrefix(engine.renderer.string(), expected))
+}
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -13,8 +15,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/distatus/battery"
+	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/host"
 	"github.com/shirou/gopsutil/process"
 )
@@ -22,13 +28,16 @@ import (
 const (
 	unknown         = "unknown"
 	windowsPlatform = "windows"
+	darwinPlatform  = "darwin"
+	linuxPlatform   = "linux"
 )
 
 type environmentInfo interface {
 	getenv(key string) string
+	environ() map[string]string
 	getcwd() string
 	homeDir() string
-	hasFiles(pattern string) bool
+	hasFiles(pattern string, caseSensitive bool) bool
 	hasFilesInDir(dir, pattern string) bool
 	hasFolder(folder string) bool
 	getFileContent(file string) string
@@ -40,19 +49,32 @@ type environmentInfo interface {
 	getPlatform() string
 	hasCommand(command string) bool
 	runCommand(command string, args ...string) (string, error)
+	runCommandContext(ctx context.Context, command string, args ...string) (string, string, int, error)
 	runShellCommand(shell, command string) string
 	lastErrorCode() int
 	executionTime() float64
 	getArgs() *args
 	getBatteryInfo() (*battery.Battery, error)
+	getCPUTimes() ([]cpu.TimesStat, error)
 	getShellName() string
 	getWindowTitle(imageName, windowTitleRegex string) (string, error)
 	doGet(url string) ([]byte, error)
+	cache() cache
+	refreshCacheInBackground(key string) error
+	getFreeSpace(path string) (uint64, error)
+	getFileMode(path string) (os.FileMode, error)
+	getModTime(path string) (time.Time, error)
+	getDeviceID(path string) (uint64, error)
+	getDirEntries(dir string, limit int) ([]string, error)
 }
 
 type environment struct {
-	args *args
-	cwd  string
+	args      *args
+	cwd       string
+	fileCache *fileCache
+	cacheOnce sync.Once
+	// getwd reads the working directory, os.Getwd when not set
+	getwd func() (string, error)
 }
 
 type commandError struct {
@@ -67,6 +89,17 @@ func (env *environment) getenv(key string) string {
 	return os.Getenv(key)
 }
 
+func (env *environment) environ() map[string]string {
+	variables := make(map[string]string)
+	for _, variable := range os.Environ() {
+		keyValue := strings.SplitN(variable, "=", 2)
+		if len(keyValue) == 2 {
+			variables[keyValue[0]] = keyValue[1]
+		}
+	}
+	return variables
+}
+
 func (env *environment) getcwd() string {
 	if env.cwd != "" {
 		return env.cwd
@@ -79,15 +112,27 @@ func (env *environment) getcwd() string {
 		env.cwd = correctPath(*env.args.PWD)
 		return env.cwd
 	}
-	dir, err := os.Getwd()
+	getwd := env.getwd
+	if getwd == nil {
+		getwd = os.Getwd
+	}
+	dir, err := getwd()
 	if err != nil {
+		// the working directory can be removed from underneath us,
+		// in that case fall back to what the shell knows
+		dir = os.Getenv("PWD")
+	}
+	if dir == "" {
 		return ""
 	}
 	env.cwd = correctPath(dir)
 	return env.cwd
 }
 
-func (env *environment) hasFiles(pattern string) bool {
+func (env *environment) hasFiles(pattern string, caseSensitive bool) bool {
+	if !caseSensitive {
+		pattern = caseInsensitivePattern(pattern)
+	}
 	cwd := env.getcwd()
 	pattern = cwd + env.getPathSeperator() + pattern
 	matches, err := filepath.Glob(pattern)
@@ -97,6 +142,47 @@ func (env *environment) hasFiles(pattern string) bool {
 	return len(matches) > 0
 }
 
+// walkUpFolders calls visit for the folder and each of its parents up to the root,
+// it stops as soon as visit returns true and reports if it did
+func walkUpFolders(folder string, visit func(folder string) bool) bool {
+	for folder != "" {
+		if visit(folder) {
+			return true
+		}
+		parent := filepath.Dir(folder)
+		if parent == folder {
+			return false
+		}
+		folder = parent
+	}
+	return false
+}
+
+// caseInsensitivePattern turns every letter of a glob pattern into a character class
+// matching both cases, e.g. *.cs becomes *.[cC][sS]. Character classes are left untouched
+func caseInsensitivePattern(pattern string) string {
+	var builder strings.Builder
+	inClass := false
+	for _, char := range pattern {
+		switch {
+		case inClass:
+			inClass = char != ']'
+			builder.WriteRune(char)
+		case char == '[':
+			inClass = true
+			builder.WriteRune(char)
+		case unicode.ToLower(char) != unicode.ToUpper(char):
+			builder.WriteRune('[')
+			builder.WriteRune(unicode.ToLower(char))
+			builder.WriteRune(unicode.ToUpper(char))
+			builder.WriteRune(']')
+		default:
+			builder.WriteRune(char)
+		}
+	}
+	return builder.String()
+}
+
 func (env *environment) hasFilesInDir(dir, pattern string) bool {
 	pattern = dir + env.getPathSeperator() + pattern
 	matches, err := filepath.Glob(pattern)
@@ -111,6 +197,36 @@ func (env *environment) hasFolder(folder string) bool {
 	return !os.IsNotExist(err)
 }
 
+func (env *environment) getFileMode(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Mode(), nil
+}
+
+func (env *environment) getModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// getDirEntries returns the names of at most limit entries of the directory, in directory order
+func (env *environment) getDirEntries(dir string, limit int) ([]string, error) {
+	folder, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer folder.Close()
+	names, err := folder.Readdirnames(limit)
+	if err == io.EOF {
+		return names, nil
+	}
+	return names, err
+}
+
 func (env *environment) getFileContent(file string) string {
 	content, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -183,6 +299,28 @@ func (env *environment) runCommand(command string, args ...string) (string, erro
 	return output.String(), nil
 }
 
+// runCommandContext runs the command until it exits or the context is done.
+// It returns the trimmed stdout and stderr output together with the exit code,
+// a non-zero exit code is reported as a commandError.
+func (env *environment) runCommandContext(ctx context.Context, command string, args ...string) (string, string, int, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), -1, ctx.Err()
+	}
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+		err = &commandError{exitCode: exitCode}
+	}
+	return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), exitCode, err
+}
+
 func (env *environment) runShellCommand(shell, command string) string {
 	out, err := exec.Command(shell, "-c", command).Output()
 	if err != nil {
@@ -216,6 +354,11 @@ func (env *environment) getBatteryInfo() (*battery.Battery, error) {
 	return battery.Get(0)
 }
 
+// getCPUTimes returns the cumulative times of every cpu, on macOS using host_processor_info
+func (env *environment) getCPUTimes() ([]cpu.TimesStat, error) {
+	return cpu.Times(true)
+}
+
 func (env *environment) getShellName() string {
 	pid := os.Getppid()
 	p, _ := process.NewProcess(int32(pid))
@@ -251,6 +394,59 @@ func (env *environment) doGet(url string) ([]byte, error) {
 	return body, nil
 }
 
+func (env *environment) cache() cache {
+	env.cacheOnce.Do(func() {
+		env.fileCache = newFileCache(cacheFolder())
+	})
+	return env.fileCache
+}
+
+// cacheFolder holds the cache and lock files
+func cacheFolder() string {
+	folder, err := os.UserCacheDir()
+	if err != nil {
+		folder = os.TempDir()
+	}
+	return filepath.Join(folder, "oh-my-posh")
+}
+
+// refreshCacheInBackground starts a detached oh-my-posh process
+// which refreshes the cache entry for key, see --refresh-cache
+func (env *environment) refreshCacheInBackground(key string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, "--refresh-cache", key)
+	cmd.Env = nonInteractiveEnvironment(os.Environ())
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// nonInteractiveEnvironment keeps the commands of a background process, like git fetch, from prompting
+// for credentials or an ssh passphrase in the terminal of the user, they fail instead
+func nonInteractiveEnvironment(environ []string) []string {
+	result := make([]string, 0, len(environ)+3)
+	sshCommand := "ssh"
+	for _, variable := range environ {
+		if strings.HasPrefix(variable, "GIT_SSH_COMMAND=") {
+			sshCommand = strings.TrimPrefix(variable, "GIT_SSH_COMMAND=")
+			continue
+		}
+		if strings.HasPrefix(variable, "GIT_TERMINAL_PROMPT=") || strings.HasPrefix(variable, "GCM_INTERACTIVE=") {
+			continue
+		}
+		result = append(result, variable)
+	}
+	return append(result,
+		"GIT_TERMINAL_PROMPT=0",
+		"GCM_INTERACTIVE=never",
+		"GIT_SSH_COMMAND="+sshCommand+" -o BatchMode=yes",
+	)
+}
+
 func cleanHostName(hostName string) string {
 	garbage := []string{
 		".lan",
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -21,3 +28,195 @@ func TestHostNameWithLan(t *testing.T) {
 	cleanHostName := cleanHostName(hostName)
 	assert.Equal(t, "hello", cleanHostName)
 }
+
+// TestHelperProcess isn't a real test, it's used as the command to run
+// by the runCommandContext tests, based on the environment variables set
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("OMP_HELPER_PROCESS") != "1" {
+		return
+	}
+	fmt.Fprint(os.Stdout, os.Getenv("OMP_HELPER_STDOUT"))
+	fmt.Fprint(os.Stderr, os.Getenv("OMP_HELPER_STDERR"))
+	if os.Getenv("OMP_HELPER_SLEEP") == "1" {
+		time.Sleep(10 * time.Second)
+	}
+	if os.Getenv("OMP_HELPER_EXIT") == "1" {
+		os.Exit(3)
+	}
+	os.Exit(0)
+}
+
+func runHelperProcess(ctx context.Context, stdout, stderr string, exit, sleep bool) (string, string, int, error) {
+	setFlag := func(key string, value bool) {
+		if value {
+			_ = os.Setenv(key, "1")
+			return
+		}
+		_ = os.Setenv(key, "")
+	}
+	_ = os.Setenv("OMP_HELPER_STDOUT", stdout)
+	_ = os.Setenv("OMP_HELPER_STDERR", stderr)
+	setFlag("OMP_HELPER_PROCESS", true)
+	setFlag("OMP_HELPER_EXIT", exit)
+	setFlag("OMP_HELPER_SLEEP", sleep)
+	defer setFlag("OMP_HELPER_PROCESS", false)
+	env := &environment{}
+	return env.runCommandContext(ctx, os.Args[0], "-test.run=TestHelperProcess")
+}
+
+func TestRunCommandContextStdout(t *testing.T) {
+	stdout, stderr, exitCode, err := runHelperProcess(context.Background(), "hello\n", "", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", stdout)
+	assert.Equal(t, "", stderr)
+	assert.Equal(t, 0, exitCode)
+}
+
+func TestRunCommandContextStderr(t *testing.T) {
+	stdout, stderr, exitCode, err := runHelperProcess(context.Background(), "", "java version 1.8", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "", stdout)
+	assert.Equal(t, "java version 1.8", stderr)
+	assert.Equal(t, 0, exitCode)
+}
+
+func TestRunCommandContextExitCode(t *testing.T) {
+	stdout, stderr, exitCode, err := runHelperProcess(context.Background(), "out", "err", true, false)
+	assert.Equal(t, &commandError{exitCode: 3}, err)
+	assert.Equal(t, "out", stdout)
+	assert.Equal(t, "err", stderr)
+	assert.Equal(t, 3, exitCode)
+}
+
+func TestRunCommandContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, exitCode, err := runHelperProcess(ctx, "", "", false, true)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, -1, exitCode)
+}
+
+func TestHasFilesCaseSensitivity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "omp-globs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, file := range []string{"Program.CS", "readme.md", "Cargo.toml"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		Case          string
+		Pattern       string
+		CaseSensitive bool
+		Expected      bool
+	}{
+		{Case: "sensitive, same case", Pattern: "*.CS", CaseSensitive: true, Expected: true},
+		{Case: "sensitive, other case", Pattern: "*.cs", CaseSensitive: true},
+		{Case: "sensitive, literal name", Pattern: "cargo.toml", CaseSensitive: true},
+		{Case: "insensitive, other case", Pattern: "*.cs", Expected: true},
+		{Case: "insensitive, literal name", Pattern: "CARGO.TOML", Expected: true},
+		{Case: "insensitive, lowercase file", Pattern: "README.*", Expected: true},
+		{Case: "insensitive, no match", Pattern: "*.go"},
+	}
+	for _, tc := range cases {
+		env := &environment{cwd: dir}
+		assert.Equal(t, tc.Expected, env.hasFiles(tc.Pattern, tc.CaseSensitive), tc.Case)
+	}
+}
+
+func TestCaseInsensitivePattern(t *testing.T) {
+	cases := []struct {
+		Pattern  string
+		Expected string
+	}{
+		{Pattern: "*.cs", Expected: "*.[cC][sS]"},
+		{Pattern: "Cargo.toml", Expected: "[cC][aA][rR][gG][oO].[tT][oO][mM][lL]"},
+		{Pattern: "*.[ch]pp", Expected: "*.[ch][pP][pP]"},
+		{Pattern: "?_1.*", Expected: "?_1.*"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, caseInsensitivePattern(tc.Pattern), tc.Pattern)
+	}
+}
+
+func TestWalkUpFolders(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Folder   string
+		Stop     string
+		Expected []string
+		Found    bool
+	}{
+		{Case: "Up to the root", Folder: "/usr/home/code", Expected: []string{"/usr/home/code", "/usr/home", "/usr", "/"}},
+		{Case: "Stops when found", Folder: "/usr/home/code", Stop: "/usr/home", Expected: []string{"/usr/home/code", "/usr/home"}, Found: true},
+		{Case: "Root", Folder: "/", Expected: []string{"/"}},
+		{Case: "Empty", Folder: ""},
+	}
+	for _, tc := range cases {
+		var visited []string
+		found := walkUpFolders(filepath.FromSlash(tc.Folder), func(folder string) bool {
+			visited = append(visited, filepath.ToSlash(folder))
+			return folder == filepath.FromSlash(tc.Stop)
+		})
+		assert.Equal(t, tc.Found, found, tc.Case)
+		assert.Equal(t, tc.Expected, visited, tc.Case)
+	}
+}
+
+func TestNonInteractiveEnvironment(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Environ  []string
+		Expected []string
+	}{
+		{
+			Case:     "Defaults",
+			Environ:  []string{"HOME=/usr/home"},
+			Expected: []string{"HOME=/usr/home", "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "GIT_SSH_COMMAND=ssh -o BatchMode=yes"},
+		},
+		{
+			Case:     "Custom ssh command",
+			Environ:  []string{"GIT_SSH_COMMAND=ssh -i ~/.ssh/work", "GIT_TERMINAL_PROMPT=1"},
+			Expected: []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never", "GIT_SSH_COMMAND=ssh -i ~/.ssh/work -o BatchMode=yes"},
+		},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, nonInteractiveEnvironment(tc.Environ), tc.Case)
+	}
+}
+
+func TestGetcwdFallback(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Getwd    string
+		Err      error
+		PWD      string
+		Expected string
+	}{
+		{Case: "Working directory", Getwd: "/usr/home/code", PWD: "/usr/home/other", Expected: "/usr/home/code"},
+		{Case: "Removed working directory", Err: errors.New("getwd: no such file or directory"), PWD: "/usr/home/removed", Expected: "/usr/home/removed"},
+		{Case: "Removed without PWD", Err: errors.New("getwd: no such file or directory")},
+	}
+	original, set := os.LookupEnv("PWD")
+	defer func() {
+		if set {
+			_ = os.Setenv("PWD", original)
+			return
+		}
+		_ = os.Unsetenv("PWD")
+	}()
+	for _, tc := range cases {
+		assert.NoError(t, os.Setenv("PWD", tc.PWD))
+		pwd := ""
+		env := &environment{
+			args: &args{PWD: &pwd},
+			getwd: func() (string, error) {
+				return tc.Getwd, tc.Err
+			},
+		}
+		assert.Equal(t, tc.Expected, env.getcwd(), tc.Case)
+	}
+}
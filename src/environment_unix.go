@@ -5,6 +5,7 @@ package main
 import (
 	"errors"
 	"os"
+	"syscall"
 )
 
 func (env *environment) isRunningAsRoot() bool {
@@ -18,3 +19,23 @@ func (env *environment) homeDir() string {
 func (env *environment) getWindowTitle(imageName, windowTitleRegex string) (string, error) {
 	return "", errors.New("not implemented")
 }
+
+func (env *environment) getFreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+func (env *environment) getDeviceID(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.New("unable to read the device id")
+	}
+	return uint64(stat.Dev), nil
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"golang.org/x/sys/windows"
@@ -53,3 +54,19 @@ func (env *environment) homeDir() string {
 func (env *environment) getWindowTitle(imageName, windowTitleRegex string) (string, error) {
 	return getWindowTitle(imageName, windowTitleRegex)
 }
+
+func (env *environment) getFreeSpace(path string) (uint64, error) {
+	directory, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	if err = windows.GetDiskFreeSpaceEx(directory, &free, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
+
+func (env *environment) getDeviceID(path string) (uint64, error) {
+	return 0, errors.New("not implemented")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// gradient returns steps hex colors evenly spread from the from color to the to color, both included.
+// A single step returns the from color, colors which are not hex colors return nothing
+func gradient(from, to string, steps int) []string {
+	start, ok := parseHexColor(from)
+	if !ok || steps <= 0 {
+		return nil
+	}
+	end, ok := parseHexColor(to)
+	if !ok {
+		return nil
+	}
+	colors := make([]string, steps)
+	for i := range colors {
+		var ratio float64
+		if steps > 1 {
+			ratio = float64(i) / float64(steps-1)
+		}
+		var rgb [3]uint8
+		for c := range rgb {
+			value := float64(start[c]) + (float64(end[c])-float64(start[c]))*ratio
+			rgb[c] = uint8(math.Round(value))
+		}
+		colors[i] = fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+	}
+	return colors
+}
+
+// parseHexColor returns the red, green and blue values of a #FFFFFF or #FFF color
+func parseHexColor(hex string) ([3]uint8, bool) {
+	var rgb [3]uint8
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = strings.Repeat(hex[0:1], 2) + strings.Repeat(hex[1:2], 2) + strings.Repeat(hex[2:3], 2)
+	}
+	if len(hex) != 6 {
+		return rgb, false
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb, false
+	}
+	rgb[0] = uint8(value >> 16)
+	rgb[1] = uint8(value >> 8)
+	rgb[2] = uint8(value)
+	return rgb, true
+}
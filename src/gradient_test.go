@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGradient(t *testing.T) {
+	cases := []struct {
+		Case     string
+		From     string
+		To       string
+		Steps    int
+		Expected []string
+	}{
+		{Case: "Three steps", From: "#000000", To: "#ffffff", Steps: 3, Expected: []string{"#000000", "#808080", "#ffffff"}},
+		{Case: "Short notation", From: "#f00", To: "#00f", Steps: 2, Expected: []string{"#ff0000", "#0000ff"}},
+		{Case: "Single step", From: "#E06C75", To: "#61AFEF", Steps: 1, Expected: []string{"#e06c75"}},
+		{Case: "Per channel", From: "#102030", To: "#302010", Steps: 3, Expected: []string{"#102030", "#202020", "#302010"}},
+		{Case: "Invalid from", From: "red", To: "#ffffff", Steps: 3},
+		{Case: "Invalid to", From: "#000000", To: "#zzzzzz", Steps: 3},
+		{Case: "No steps", From: "#000000", To: "#ffffff"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, gradient(tc.From, tc.To, tc.Steps), tc.Case)
+	}
+}
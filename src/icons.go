@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// iconReferencePrefix marks a property value as the name of an icon in the icon pack: icon:branch
+	iconReferencePrefix = "icon:"
+)
+
+// iconPack maps logical icon names to glyphs
+type iconPack map[string]string
+
+// builtinIconPacks can be referenced by name in icon_pack
+var builtinIconPacks = map[string]iconPack{
+	"nerd-font": {
+		"branch":    "\uE0A0",
+		"commit":    "\uF417",
+		"tag":       "\uF412",
+		"rebase":    "\uE728",
+		"stash":     "\uF692",
+		"folder":    "\uF115",
+		"home":      "\uF015",
+		"lock":      "\uF023",
+		"error":     "\uF00D",
+		"success":   "\uF00C",
+		"warning":   "\uF071",
+		"root":      "\uF0E7",
+		"git":       "\uE5FB",
+		"github":    "\uF408",
+		"gitlab":    "\uF296",
+		"bitbucket": "\uF171",
+		"python":    "\uE235",
+		"node":      "\uE718",
+		"go":        "\uE627",
+	},
+	"ascii": {
+		"branch":    "",
+		"commit":    "@",
+		"tag":       "#",
+		"rebase":    "rebase ",
+		"stash":     "stash:",
+		"folder":    "..",
+		"home":      "~",
+		"lock":      "ro",
+		"error":     "x",
+		"success":   "ok",
+		"warning":   "!",
+		"root":      "#",
+		"git":       "git:",
+		"github":    "gh:",
+		"gitlab":    "gl:",
+		"bitbucket": "bb:",
+		"python":    "py ",
+		"node":      "node ",
+		"go":        "go ",
+	},
+}
+
+// loadIconPack returns the built-in pack with the reference as name, or reads the reference as a json file
+// mapping icon names to glyphs. A relative file is resolved against the folder of the configuration
+func loadIconPack(reference, configFolder string) (iconPack, error) {
+	if reference == "" {
+		return nil, nil
+	}
+	if pack, ok := builtinIconPacks[reference]; ok {
+		return pack, nil
+	}
+	file := reference
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(configFolder, file)
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var pack iconPack
+	if err = json.Unmarshal(content, &pack); err != nil {
+		return nil, fmt.Errorf("%s: %s", reference, err)
+	}
+	return pack, nil
+}
+
+// resolve returns the glyph for an icon:name reference, values which aren't a reference are returned as-is.
+// A name missing in the pack is not resolved
+func (ip iconPack) resolve(value string) (string, bool) {
+	if !strings.HasPrefix(value, iconReferencePrefix) {
+		return value, true
+	}
+	icon, ok := ip[strings.TrimPrefix(value, iconReferencePrefix)]
+	return icon, ok
+}
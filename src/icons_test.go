@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadIconPackBuiltin(t *testing.T) {
+	pack, err := loadIconPack("ascii", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "@", pack["commit"])
+	pack, err = loadIconPack("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, pack)
+}
+
+func TestLoadIconPackFile(t *testing.T) {
+	folder, err := ioutil.TempDir("", "omp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+	err = ioutil.WriteFile(filepath.Join(folder, "icons.json"), []byte(`{"branch": "b:", "commit": "c:"}`), 0644)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(folder, "invalid.json"), []byte(`["branch"]`), 0644)
+	assert.NoError(t, err)
+	cases := []struct {
+		Case      string
+		Reference string
+		Expected  iconPack
+		Error     bool
+	}{
+		{Case: "Relative to the config", Reference: "icons.json", Expected: iconPack{"branch": "b:", "commit": "c:"}},
+		{Case: "Absolute", Reference: filepath.Join(folder, "icons.json"), Expected: iconPack{"branch": "b:", "commit": "c:"}},
+		{Case: "Missing file", Reference: "missing.json", Error: true},
+		{Case: "Invalid file", Reference: "invalid.json", Error: true},
+	}
+	for _, tc := range cases {
+		pack, err := loadIconPack(tc.Reference, folder)
+		assert.Equal(t, tc.Error, err != nil, tc.Case)
+		assert.Equal(t, tc.Expected, pack, tc.Case)
+	}
+}
+
+func TestGetStringIconPack(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Value    string
+		Pack     iconPack
+		Expected string
+	}{
+		{Case: "Named icon", Value: "icon:branch", Pack: iconPack{"branch": "b:"}, Expected: "b:"},
+		{Case: "Missing name", Value: "icon:commit", Pack: iconPack{"branch": "b:"}, Expected: "default"},
+		{Case: "No pack", Value: "icon:branch", Expected: "default"},
+		{Case: "Plain value", Value: "\uE0A0", Pack: iconPack{"branch": "b:"}, Expected: "\uE0A0"},
+		{Case: "Built-in pack", Value: "icon:tag", Pack: builtinIconPacks["ascii"], Expected: "#"},
+	}
+	for _, tc := range cases {
+		props := &properties{
+			values: map[Property]interface{}{BranchIcon: tc.Value},
+			icons:  tc.Pack,
+		}
+		assert.Equal(t, tc.Expected, props.getString(BranchIcon, "default"), tc.Case)
+	}
+}
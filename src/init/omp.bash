@@ -6,4 +6,5 @@ function _update_ps1() {
 
 if [ "$TERM" != "linux" ] && [ -x "$(command -v ::OMP::)" ]; then
     PROMPT_COMMAND="_update_ps1; $PROMPT_COMMAND"
+    PS2="$(::OMP:: --config $POSH_THEME --print secondary)"
 fi
@@ -64,3 +64,8 @@ function Set-GitStatus {
     Set-GitStatus
 }
 Set-Item -Path Function:prompt -Value $Prompt -Force
+
+if (Get-Command -Name "Set-PSReadLineOption" -ErrorAction SilentlyContinue) {
+    $continuationPrompt = @(&"::OMP::" --config="$($global:PoshSettings.Theme)" --print secondary) -join ""
+    Set-PSReadLineOption -ContinuationPrompt $continuationPrompt
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileLock coordinates processes using a lock file, only one of them holds it at a time.
+// A lock older than the timeout is considered abandoned, like after a crash, and can be taken over
+type fileLock struct {
+	path    string
+	timeout time.Duration
+	now     func() time.Time
+	rename  func(oldpath, newpath string) error
+}
+
+func newFileLock(path string, timeout time.Duration) *fileLock {
+	return &fileLock{
+		path:    path,
+		timeout: timeout,
+		now:     time.Now,
+		rename:  os.Rename,
+	}
+}
+
+// lockTakeoverRetries limits how often a process tries again after it moved a fresh lock instead of the abandoned one
+const lockTakeoverRetries = 3
+
+// tryLock acquires the lock without waiting, it returns false when another process holds it
+func (l *fileLock) tryLock() bool {
+	for i := 0; i < lockTakeoverRetries; i++ {
+		if l.create() {
+			return true
+		}
+		if !l.removeAbandoned() {
+			return false
+		}
+	}
+	return false
+}
+
+// removeAbandoned moves an abandoned lock out of the way first, only one process succeeds in doing so.
+// Between checking and moving it, another process can take it over and create a fresh lock.
+// When the moved file isn't the abandoned one, it's put back unless yet another lock exists by then.
+func (l *fileLock) removeAbandoned() bool {
+	info, err := os.Stat(l.path)
+	if err != nil || l.now().Sub(info.ModTime()) < l.timeout {
+		return false
+	}
+	owner, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return false
+	}
+	abandoned := fmt.Sprintf("%s.%d", l.path, os.Getpid())
+	if err = l.rename(l.path, abandoned); err != nil {
+		return false
+	}
+	defer func() { _ = os.Remove(abandoned) }()
+	moved, err := os.Stat(abandoned)
+	if err != nil {
+		return true
+	}
+	movedOwner, err := ioutil.ReadFile(abandoned)
+	if err == nil && moved.ModTime().Equal(info.ModTime()) && bytes.Equal(owner, movedOwner) {
+		return true
+	}
+	// a link fails when the path exists, unlike a rename it can't replace the lock of yet another process
+	_ = os.Link(abandoned, l.path)
+	return true
+}
+
+func (l *fileLock) create() bool {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return false
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return false
+	}
+	_, _ = fmt.Fprint(file, os.Getpid())
+	return file.Close() == nil
+}
+
+func (l *fileLock) unlock() {
+	_ = os.Remove(l.path)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFileLock(t *testing.T) (*fileLock, func()) {
+	folder, err := ioutil.TempDir("", "omp")
+	assert.NoError(t, err)
+	return newFileLock(filepath.Join(folder, "test.lock"), time.Minute), func() { _ = os.RemoveAll(folder) }
+}
+
+func TestFileLock(t *testing.T) {
+	lock, cleanup := newTestFileLock(t)
+	defer cleanup()
+	assert.True(t, lock.tryLock())
+	assert.False(t, lock.tryLock(), "held")
+	lock.unlock()
+	assert.True(t, lock.tryLock(), "released")
+}
+
+func TestFileLockConcurrent(t *testing.T) {
+	lock, cleanup := newTestFileLock(t)
+	defer cleanup()
+	var acquired int
+	var mutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// every render has its own lock pointing to the same file
+			render := newFileLock(lock.path, lock.timeout)
+			if render.tryLock() {
+				mutex.Lock()
+				acquired++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, acquired)
+}
+
+func TestFileLockAbandoned(t *testing.T) {
+	lock, cleanup := newTestFileLock(t)
+	defer cleanup()
+	assert.True(t, lock.tryLock())
+	other := newFileLock(lock.path, lock.timeout)
+	other.now = func() time.Time { return time.Now().Add(30 * time.Second) }
+	assert.False(t, other.tryLock(), "not timed out")
+	other.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.True(t, other.tryLock(), "timed out")
+	assert.False(t, lock.tryLock(), "taken over")
+}
+
+func TestFileLockAbandonedTakenOverMeanwhile(t *testing.T) {
+	lock, cleanup := newTestFileLock(t)
+	defer cleanup()
+	assert.True(t, lock.tryLock())
+	stale := time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, os.Chtimes(lock.path, stale, stale))
+	fresh := newFileLock(lock.path, lock.timeout)
+	other := newFileLock(lock.path, lock.timeout)
+	other.rename = func(oldpath, newpath string) error {
+		// another process takes over the abandoned lock between the check and the rename
+		other.rename = os.Rename
+		assert.NoError(t, os.Remove(oldpath))
+		assert.True(t, fresh.tryLock())
+		return os.Rename(oldpath, newpath)
+	}
+	assert.False(t, other.tryLock(), "fresh lock restored")
+	_, err := os.Stat(lock.path)
+	assert.NoError(t, err)
+	files, err := ioutil.ReadDir(filepath.Dir(lock.path))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -22,6 +22,8 @@ const (
 	pwsh        = "pwsh"
 	fish        = "fish"
 	powershell5 = "powershell"
+	primary     = "primary"
+	secondary   = "secondary"
 )
 
 type args struct {
@@ -38,6 +40,10 @@ type args struct {
 	Eval          *bool
 	Init          *bool
 	PrintInit     *bool
+	RefreshCache  *string
+	Print         *string
+	Watch         *bool
+	Validate      *bool
 }
 
 func main() {
@@ -94,6 +100,22 @@ func main() {
 			"print-init",
 			false,
 			"Print the shell initialization script"),
+		RefreshCache: flag.String(
+			"refresh-cache",
+			"",
+			"Refresh the cached value for a key, used for asynchronous segments"),
+		Print: flag.String(
+			"print",
+			primary,
+			"Print the primary or secondary (continuation) prompt"),
+		Watch: flag.Bool(
+			"watch",
+			false,
+			"Render the prompt again every time the config changes, for theme development"),
+		Validate: flag.Bool(
+			"validate",
+			false,
+			"Validate the config, like its colors, and exit with 1 when it's invalid"),
 	}
 	flag.Parse()
 	env := &environment{
@@ -103,6 +125,10 @@ func main() {
 		fmt.Print(time.Now().UnixNano() / 1000000)
 		return
 	}
+	if *args.RefreshCache != "" {
+		refreshCache(env, *args.RefreshCache)
+		return
+	}
 	if *args.Init {
 		init := initShell(*args.Shell, *args.Config)
 		fmt.Print(init)
@@ -113,6 +139,14 @@ func main() {
 		fmt.Print(init)
 		return
 	}
+	if *args.Validate {
+		if err := validateConfig(env); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("VALID CONFIG")
+		return
+	}
 	settings := GetSettings(env)
 	if *args.PrintConfig {
 		theme, _ := json.MarshalIndent(settings, "", "    ")
@@ -131,6 +165,23 @@ func main() {
 	if *args.Shell != "" {
 		shell = *args.Shell
 	}
+	if *args.Watch {
+		// the prompt is written to the terminal, not to a shell,
+		// only escape it for a shell when one is asked for explicitly
+		watchConfig(*args.Config, func() {
+			newEngine(GetSettings(env), env, *args.Shell).render()
+		})
+		return
+	}
+	engine := newEngine(settings, env, shell)
+	if *args.Print == secondary {
+		fmt.Print(engine.renderSecondaryPrompt())
+		return
+	}
+	engine.render()
+}
+
+func newEngine(settings *Settings, env environmentInfo, shell string) *engine {
 	renderer := &AnsiRenderer{
 		buffer: new(bytes.Buffer),
 	}
@@ -139,13 +190,12 @@ func main() {
 	}
 	renderer.init(shell)
 	colorer.init(shell)
-	engine := &engine{
+	return &engine{
 		settings: settings,
 		env:      env,
 		color:    colorer,
 		renderer: renderer,
 	}
-	engine.render()
 }
 
 func initShell(shell, config string) string {
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// palette maps the names of derived colors, like accent.light, to hex colors
+type palette map[string]string
+
+const (
+	// paletteReference prefixes a color taken from the palette: p:accent.dark
+	paletteReference = "p:"
+	// the lightness shift of the light and dark shades, doubled for the lighter and darker ones
+	paletteShadeStep = 0.15
+)
+
+// derivePalette returns the accent color and its shades, lighter and darker by shifting the lightness,
+// and the complementary color with the opposite hue. An accent which is not a hex color returns nothing
+func derivePalette(accent string) (palette, bool) {
+	rgb, ok := parseHexColor(accent)
+	if !ok {
+		return nil, false
+	}
+	h, s, l := rgbToHSL(rgb)
+	shade := func(shift float64) string {
+		return hslToHex(h, s, math.Max(0, math.Min(1, l+shift)))
+	}
+	return palette{
+		"accent":            hslToHex(h, s, l),
+		"accent.light":      shade(paletteShadeStep),
+		"accent.lighter":    shade(2 * paletteShadeStep),
+		"accent.dark":       shade(-paletteShadeStep),
+		"accent.darker":     shade(-2 * paletteShadeStep),
+		"accent.complement": hslToHex(math.Mod(h+180, 360), s, l),
+	}, true
+}
+
+// resolve returns the palette color a p:name reference points to,
+// other colors and unknown references are returned as is
+func (p palette) resolve(color string) string {
+	if !strings.HasPrefix(color, paletteReference) {
+		return color
+	}
+	if value, ok := p[strings.TrimPrefix(color, paletteReference)]; ok {
+		return value
+	}
+	return color
+}
+
+// rgbToHSL returns the hue (0-360), saturation and lightness (0-1) of a color
+func rgbToHSL(rgb [3]uint8) (h, s, l float64) {
+	r, g, b := float64(rgb[0])/255, float64(rgb[1])/255, float64(rgb[2])/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	delta := max - min
+	if l > 0.5 {
+		s = delta / (2 - max - min)
+	} else {
+		s = delta / (max + min)
+	}
+	switch max {
+	case r:
+		h = math.Mod((g-b)/delta+6, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	return h * 60, s, l
+}
+
+// hslToHex returns the hex color of a hue (0-360), saturation and lightness (0-1)
+func hslToHex(h, s, l float64) string {
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - chroma/2
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = chroma, x
+	case h < 120:
+		r, g = x, chroma
+	case h < 180:
+		g, b = chroma, x
+	case h < 240:
+		g, b = x, chroma
+	case h < 300:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	channel := func(value float64) uint8 {
+		return uint8(math.Round((value + m) * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+}
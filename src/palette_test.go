@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivePalette(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Accent   string
+		Expected palette
+	}{
+		{
+			Case:   "Blue",
+			Accent: "#0077c2",
+			Expected: palette{
+				"accent":            "#0077c2",
+				"accent.light":      "#10a2ff",
+				"accent.lighter":    "#5cc0ff",
+				"accent.dark":       "#004875",
+				"accent.darker":     "#001929",
+				"accent.complement": "#c24b00",
+			},
+		},
+		{
+			Case:   "Short notation",
+			Accent: "#f00",
+			Expected: palette{
+				"accent":            "#ff0000",
+				"accent.light":      "#ff4d4d",
+				"accent.lighter":    "#ff9999",
+				"accent.dark":       "#b30000",
+				"accent.darker":     "#660000",
+				"accent.complement": "#00ffff",
+			},
+		},
+		{
+			Case:   "Gray has no hue",
+			Accent: "#808080",
+			Expected: palette{
+				"accent":            "#808080",
+				"accent.light":      "#a6a6a6",
+				"accent.lighter":    "#cdcdcd",
+				"accent.dark":       "#5a5a5a",
+				"accent.darker":     "#343434",
+				"accent.complement": "#808080",
+			},
+		},
+		{
+			Case:   "Clamped lightness",
+			Accent: "#ffffff",
+			Expected: palette{
+				"accent":            "#ffffff",
+				"accent.light":      "#ffffff",
+				"accent.lighter":    "#ffffff",
+				"accent.dark":       "#d9d9d9",
+				"accent.darker":     "#b3b3b3",
+				"accent.complement": "#ffffff",
+			},
+		},
+	}
+	for _, tc := range cases {
+		colors, ok := derivePalette(tc.Accent)
+		assert.True(t, ok, tc.Case)
+		assert.Equal(t, tc.Expected, colors, tc.Case)
+	}
+}
+
+func TestDerivePaletteInvalidAccent(t *testing.T) {
+	for _, accent := range []string{"", "blue", "#zzzzzz"} {
+		colors, ok := derivePalette(accent)
+		assert.False(t, ok, accent)
+		assert.Nil(t, colors, accent)
+	}
+}
+
+func TestPaletteResolve(t *testing.T) {
+	colors := palette{"accent.dark": "#004875"}
+	cases := []struct {
+		Color    string
+		Expected string
+	}{
+		{Color: "p:accent.dark", Expected: "#004875"},
+		{Color: "p:accent.unknown", Expected: "p:accent.unknown"},
+		{Color: "#ffffff", Expected: "#ffffff"},
+		{Color: "accent.dark", Expected: "accent.dark"},
+		{Color: "", Expected: ""},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, colors.resolve(tc.Color), tc.Color)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Property defines one property of a segment for context
@@ -21,23 +22,74 @@ const (
 	IgnoreFolders Property = "ignore_folders"
 	// DisplayVersion show the version number or not
 	DisplayVersion Property = "display_version"
+	// EnabledTemplate a template which has to render true for the segment to be enabled
+	EnabledTemplate Property = "enabled"
+	// Cache the rendered output of the segment for the given amount of seconds
+	Cache Property = "cache"
+	// MinWidth pads the segment text with spaces up to the number of characters
+	MinWidth Property = "min_width"
+	// MaxWidth truncates the segment text to the number of characters
+	MaxWidth Property = "max_width"
+	// DebugTemplate prints the data available to the templates of the segment below the prompt when running with --debug
+	DebugTemplate Property = "debug_template"
+	// RenderError displays the error_template instead of hiding the segment when its logic fails, like a command exiting with an error
+	RenderError Property = "render_if_error"
+	// ErrorTemplate the template of the error displayed by render_if_error
+	ErrorTemplate Property = "error_template"
+	// Platforms the operating systems the segment is enabled on: windows, linux or darwin
+	Platforms Property = "platforms"
+	// Transforms a list of operations applied in order to the segment text, e.g. lower or replace:foo:bar
+	Transforms Property = "transforms"
+	// SegmentTemplate the template to render the segment text with, for segments which support one
+	SegmentTemplate Property = "template"
 )
 
 type properties struct {
-	values     map[Property]interface{}
-	foreground string
-	background string
+	values             map[Property]interface{}
+	foreground         string
+	background         string
+	nerdFontVersion    NerdFontVersion
+	icons              iconPack
+	caseSensitiveGlobs *bool
 }
 
 func (p *properties) getString(property Property, defaultValue string) string {
-	if p == nil || p.values == nil {
+	if p == nil {
+		return defaultValue
+	}
+	// only the built-in defaults follow the Nerd Font version, user values are untouched
+	defaultValue = p.nerdFontIcons(defaultValue)
+	if p.values == nil {
 		return defaultValue
 	}
 	val, found := p.values[property]
 	if !found {
 		return defaultValue
 	}
-	return parseString(val, defaultValue)
+	// icon:name references an icon of the icon pack, a missing icon falls back to the default
+	if icon, ok := p.icons.resolve(parseString(val, defaultValue)); ok {
+		return icon
+	}
+	return defaultValue
+}
+
+// nerdFontIcons maps the Nerd Font v2 Material Design icons (U+F500 - U+FD46)
+// to their v3 location (U+F0001 - U+F1AF0) when using v3
+func (p *properties) nerdFontIcons(text string) string {
+	if p.nerdFontVersion != NerdFontV3 {
+		return text
+	}
+	const (
+		v2Start = 0xF500
+		v2End   = 0xFD46
+		v3Start = 0xF0001
+	)
+	return strings.Map(func(r rune) rune {
+		if r >= v2Start && r <= v2End {
+			return r - v2Start + v3Start
+		}
+		return r
+	}, text)
 }
 
 func parseString(value interface{}, defaultValue string) string {
@@ -57,8 +109,7 @@ func (p *properties) getColor(property Property, defaultValue string) string {
 		return defaultValue
 	}
 	colorString := parseString(val, defaultValue)
-	_, err := getColorFromName(colorString, false)
-	if err == nil {
+	if isValidColor(colorString) {
 		return colorString
 	}
 	values := findNamedRegexMatch(`(?P<color>#[A-Fa-f0-9]{6}|[A-Fa-f0-9]{3})`, colorString)
@@ -114,6 +165,17 @@ func (p *properties) getKeyValueMap(property Property, defaultValue map[string]s
 	return keyValues
 }
 
+func (p *properties) getStringArray(property Property, defaultValue []string) []string {
+	if p == nil || p.values == nil {
+		return defaultValue
+	}
+	val, found := p.values[property]
+	if !found {
+		return defaultValue
+	}
+	return parseStringArray(val)
+}
+
 func parseStringArray(param interface{}) []string {
 	switch v := param.(type) {
 	default:
@@ -146,5 +208,11 @@ func parseKeyValueArray(param interface{}) map[string]string {
 		return keyValueArray
 	case map[string]string:
 		return v
+	case map[string]interface{}:
+		keyValues := make(map[string]string)
+		for key, value := range v {
+			keyValues[key] = fmt.Sprint(value)
+		}
+		return keyValues
 	}
 }
@@ -144,3 +144,62 @@ func TestGetFloat64InvalidBoolProperty(t *testing.T) {
 	value := properties.getFloat64(ThresholdProperty, expected)
 	assert.Equal(t, expected, value)
 }
+
+func TestGetStringArray(t *testing.T) {
+	expected := []string{"a", "b"}
+	values := map[Property]interface{}{IgnoreFolders: []interface{}{"a", "b"}}
+	properties := properties{
+		values: values,
+	}
+	value := properties.getStringArray(IgnoreFolders, []string{})
+	assert.Equal(t, expected, value)
+}
+
+func TestGetStringArrayPropertyNotInMap(t *testing.T) {
+	expected := []string{"default"}
+	properties := properties{
+		values: map[Property]interface{}{},
+	}
+	value := properties.getStringArray(IgnoreFolders, expected)
+	assert.Equal(t, expected, value)
+}
+
+func TestGetKeyValueMapFromObject(t *testing.T) {
+	expected := map[string]string{"127": "not found", "1": "2"}
+	values := map[Property]interface{}{CodeMap: map[string]interface{}{"127": "not found", "1": 2}}
+	properties := properties{
+		values: values,
+	}
+	value := properties.getKeyValueMap(CodeMap, map[string]string{})
+	assert.Equal(t, expected, value)
+}
+
+func TestGetStringNerdFontVersion(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Version  NerdFontVersion
+		Values   map[Property]interface{}
+		Expected string
+	}{
+		{Case: "Not set", Values: map[Property]interface{}{}, Expected: "\uF8E3 "},
+		{Case: "v2", Version: NerdFontV2, Values: map[Property]interface{}{}, Expected: "\uF8E3 "},
+		{Case: "v3", Version: NerdFontV3, Values: map[Property]interface{}{}, Expected: "\U000F03E4 "},
+		{Case: "v3 user value", Version: NerdFontV3, Values: map[Property]interface{}{Prefix: "\uF8E3"}, Expected: "\uF8E3"},
+		{Case: "v3 no values", Version: NerdFontV3, Expected: "\U000F03E4 "},
+	}
+	for _, tc := range cases {
+		props := &properties{
+			values:          tc.Values,
+			nerdFontVersion: tc.Version,
+		}
+		assert.Equal(t, tc.Expected, props.getString(Prefix, "\uF8E3 "), tc.Case)
+	}
+}
+
+func TestNerdFontIconsRange(t *testing.T) {
+	props := &properties{
+		nerdFontVersion: NerdFontV3,
+	}
+	assert.Equal(t, "\uF4FF\U000F0001\U000F0847\uFD47", props.nerdFontIcons("\uF4FF\uF500\uFD46\uFD47"))
+	assert.Equal(t, "\uE0B0 text", props.nerdFontIcons("\uE0B0 text"))
+}
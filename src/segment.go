@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -23,6 +26,16 @@ type Segment struct {
 	stringValue     string
 	active          bool
 	timing          time.Duration
+	// the writers of the active segments which rendered before this one
+	renderedSegments map[string]SegmentWriter
+	nerdFontVersion  NerdFontVersion
+	icons            iconPack
+	// the global case sensitivity of the file-detection globs, nil follows the platform
+	caseSensitiveGlobs *bool
+	// the foreground of the block, used when the segment has none or inherits it
+	blockForeground string
+	// the error which made the writer fail, only set when it reports errors
+	err error
 }
 
 // SegmentWriter is the interface used to define what and if to write to the prompt
@@ -32,6 +45,22 @@ type SegmentWriter interface {
 	init(props *properties, env environmentInfo)
 }
 
+// segmentsReader is implemented by writers which can reference the segments rendered before them
+type segmentsReader interface {
+	setSegments(segments map[string]SegmentWriter)
+}
+
+// platformRestricter is implemented by writers which only work on some platforms,
+// used when the segment doesn't list its own platforms
+type platformRestricter interface {
+	defaultPlatforms() []string
+}
+
+// errorReporter is implemented by writers which can tell why they are not enabled
+type errorReporter interface {
+	getError() error
+}
+
 // SegmentStyle the syle of segment, for more information, see the constants
 type SegmentStyle string
 
@@ -91,6 +120,12 @@ const (
 	YTM SegmentType = "ytm"
 	// ExecutionTime writes the execution time of the last run command
 	ExecutionTime SegmentType = "executiontime"
+	// SysInfo writes the cpu usage
+	SysInfo SegmentType = "sysinfo"
+	// Tmux writes the tmux session and window name
+	Tmux SegmentType = "tmux"
+	// Jujutsu writes the jj change and bookmark information
+	Jujutsu SegmentType = "jj"
 )
 
 func (segment *Segment) string() string {
@@ -124,8 +159,20 @@ func (segment *Segment) shouldIgnoreFolder(cwd string) bool {
 	return false
 }
 
-func (segment *Segment) mapSegmentWithWriter(env environmentInfo) error {
-	functions := map[SegmentType]SegmentWriter{
+// referencesSegments indicates if one of the properties references
+// other segments using {{ .Segments }} in a template
+func (segment *Segment) referencesSegments() bool {
+	for _, value := range segment.Properties {
+		if text, ok := value.(string); ok && strings.Contains(text, ".Segments") {
+			return true
+		}
+	}
+	return false
+}
+
+// newSegmentWriters returns a new, uninitialized writer for every segment type
+func newSegmentWriters() map[SegmentType]SegmentWriter {
+	return map[SegmentType]SegmentWriter{
 		Session:       &session{},
 		Path:          &path{},
 		Git:           &git{},
@@ -149,14 +196,31 @@ func (segment *Segment) mapSegmentWithWriter(env environmentInfo) error {
 		Julia:         &julia{},
 		YTM:           &ytm{},
 		ExecutionTime: &executiontime{},
+		SysInfo:       &sysinfo{},
+		Tmux:          &tmux{},
+		Jujutsu:       &jujutsu{},
 	}
+}
+
+func (segment *Segment) mapSegmentWithWriter(env environmentInfo) error {
+	functions := newSegmentWriters()
 	if writer, ok := functions[segment.Type]; ok {
+		foreground := segment.Foreground
+		if foreground == "" || foreground == Inherit {
+			foreground = segment.blockForeground
+		}
 		props := &properties{
-			values:     segment.Properties,
-			foreground: segment.Foreground,
-			background: segment.Background,
+			values:             segment.Properties,
+			foreground:         foreground,
+			background:         segment.Background,
+			nerdFontVersion:    segment.nerdFontVersion,
+			icons:              segment.icons,
+			caseSensitiveGlobs: segment.caseSensitiveGlobs,
 		}
 		writer.init(props, env)
+		if reader, ok := writer.(segmentsReader); ok {
+			reader.setSegments(segment.renderedSegments)
+		}
 		segment.writer = writer
 		segment.props = props
 		return nil
@@ -164,11 +228,93 @@ func (segment *Segment) mapSegmentWithWriter(env environmentInfo) error {
 	return errors.New("unable to map writer")
 }
 
+// cacheKey identifies the output of a segment by its type, properties and the working directory,
+// most segments display something else in another folder, like the path or the git status
+func (segment *Segment) cacheKey(cwd string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(segment.Type))
+	_, _ = hash.Write([]byte(cwd))
+	// json sorts the map keys, making the hash stable
+	properties, _ := json.Marshal(segment.Properties)
+	_, _ = hash.Write(properties)
+	return fmt.Sprintf("segment_%s_%x", segment.Type, hash.Sum64())
+}
+
+func (segment *Segment) setCachedStringValue(env environmentInfo, cwd string) bool {
+	ttl := segment.props.getFloat64(Cache, 0)
+	if ttl <= 0 {
+		return false
+	}
+	value, age, found := env.cache().get(segment.cacheKey(cwd))
+	if !found || age.Seconds() >= ttl {
+		return false
+	}
+	segment.stringValue = value
+	segment.active = value != ""
+	return true
+}
+
+func (segment *Segment) cacheStringValue(env environmentInfo, cwd string) {
+	if segment.props.getFloat64(Cache, 0) <= 0 || segment.err != nil {
+		return
+	}
+	env.cache().set(segment.cacheKey(cwd), segment.stringValue)
+}
+
+// enabledContext is available in the enabled template
+type enabledContext struct {
+	Env   map[string]string
+	Cwd   string
+	Shell string
+}
+
+// enabledOnPlatform indicates the platforms list contains the current operating system.
+// Without a list, the segment is enabled on every platform its writer supports
+func (segment *Segment) enabledOnPlatform(env environmentInfo) bool {
+	defaultPlatforms := []string{}
+	if restricter, ok := segment.writer.(platformRestricter); ok {
+		defaultPlatforms = restricter.defaultPlatforms()
+	}
+	platforms := segment.props.getStringArray(Platforms, defaultPlatforms)
+	if len(platforms) == 0 {
+		return true
+	}
+	goos := env.getRuntimeGOOS()
+	for _, platform := range platforms {
+		if strings.EqualFold(platform, goos) {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledByTemplate evaluates the enabled template, which has to render true.
+// Without a template, the segment's own logic decides
+func (segment *Segment) enabledByTemplate(env environmentInfo, cwd string) bool {
+	enabledTemplate := segment.props.getString(EnabledTemplate, "")
+	if enabledTemplate == "" {
+		return true
+	}
+	template := &textTemplate{
+		Template: enabledTemplate,
+		Context: &enabledContext{
+			Env:   env.environ(),
+			Cwd:   cwd,
+			Shell: env.getShellName(),
+		},
+	}
+	return strings.TrimSpace(template.render()) == "true"
+}
+
 func (segment *Segment) setStringValue(env environmentInfo, cwd string, debug bool) {
 	err := segment.mapSegmentWithWriter(env)
-	if err != nil || segment.shouldIgnoreFolder(cwd) {
+	if err != nil || !segment.enabledOnPlatform(env) || segment.shouldIgnoreFolder(cwd) || !segment.enabledByTemplate(env, cwd) {
+		return
+	}
+	if segment.setCachedStringValue(env, cwd) {
 		return
 	}
+	defer segment.cacheStringValue(env, cwd)
 	// add timing only in debug
 	if debug {
 		start := time.Now()
@@ -183,6 +329,48 @@ func (segment *Segment) setStringValue(env environmentInfo, cwd string, debug bo
 		})()
 	}
 	if segment.enabled() {
-		segment.stringValue = segment.string()
+		text := applyTransforms(segment.string(), segment.props.getStringArray(Transforms, []string{}))
+		segment.stringValue = segment.fitWidth(text)
+		return
+	}
+	segment.setErrorValue()
+}
+
+// errorContext is available in the error template
+type errorContext struct {
+	Error string
+	Type  SegmentType
+}
+
+// setErrorValue displays the error of a writer which failed instead of hiding the segment
+// when render_if_error is set, the error is not cached so the next prompt tries again
+func (segment *Segment) setErrorValue() {
+	reporter, ok := segment.writer.(errorReporter)
+	if !ok || !segment.props.getBool(RenderError, false) {
+		return
+	}
+	segment.err = reporter.getError()
+	if segment.err == nil {
+		return
+	}
+	template := &textTemplate{
+		Template: segment.props.getString(ErrorTemplate, "\uF071 {{ .Error }}"),
+		Context: &errorContext{
+			Error: segment.err.Error(),
+			Type:  segment.Type,
+		},
+	}
+	segment.stringValue = template.render()
+	segment.active = segment.stringValue != ""
+}
+
+// fitWidth pads or truncates the visible text to the min_width and max_width properties
+func (segment *Segment) fitWidth(text string) string {
+	maxWidth := int(segment.props.getFloat64(MaxWidth, 0))
+	text = truncateToWidth(text, maxWidth)
+	minWidth := int(segment.props.getFloat64(MinWidth, 0))
+	if maxWidth > 0 && minWidth > maxWidth {
+		minWidth = maxWidth
 	}
+	return padToWidth(text, minWidth)
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/distatus/battery"
 )
@@ -11,6 +13,7 @@ type batt struct {
 	props          *properties
 	env            environmentInfo
 	percentageText string
+	stale          bool
 }
 
 const (
@@ -32,10 +35,23 @@ const (
 	DischargingColor Property = "discharging_color"
 	// DisplayCharging Hide the battery icon while it's charging
 	DisplayCharging Property = "display_charging"
+	// Async uses the cached battery information and refreshes it in the background
+	Async Property = "async"
+	// StaleIcon to display when the cached battery information is older than the stale threshold
+	StaleIcon Property = "stale_icon"
+	// StaleThreshold the age in seconds after which the cached battery information is stale
+	StaleThreshold Property = "stale_threshold"
+	// RefreshInterval the age in seconds after which the cached battery information is refreshed in the background
+	RefreshInterval Property = "refresh_interval"
+
+	batteryCacheKey = "battery"
+	// batteryRefreshCacheKey marks a background refresh as started, no other one starts within batteryRefreshTTL
+	batteryRefreshCacheKey = "battery_refresh"
+	batteryRefreshTTL      = 10 * time.Second
 )
 
 func (b *batt) enabled() bool {
-	bt, err := b.env.getBatteryInfo()
+	bt, err := b.getBatteryInfo()
 
 	display := b.props.getBool(DisplayCharging, true)
 	if !display && (bt.State == battery.Charging || bt.State == battery.Full) {
@@ -85,9 +101,52 @@ func (b *batt) enabled() bool {
 	}
 	batteryIcon := b.props.getString(BatteryIcon, "")
 	b.percentageText = fmt.Sprintf("%s%s%s", icon, batteryIcon, percentageText)
+	if b.stale {
+		b.percentageText += b.props.getString(StaleIcon, "\u2022")
+	}
 	return true
 }
 
+// getBatteryInfo reads the battery information, in async mode the cached value is used
+// and a refresh is started in the background once it's older than the refresh interval.
+// When nothing is cached yet, it's read directly.
+func (b *batt) getBatteryInfo() (*battery.Battery, error) {
+	if !b.props.getBool(Async, false) {
+		return b.env.getBatteryInfo()
+	}
+	value, age, found := b.env.cache().get(batteryCacheKey)
+	var bt battery.Battery
+	if !found || json.Unmarshal([]byte(value), &bt) != nil {
+		return cacheBatteryInfo(b.env)
+	}
+	b.stale = age.Seconds() > b.props.getFloat64(StaleThreshold, 60)
+	if age.Seconds() > b.props.getFloat64(RefreshInterval, 10) {
+		b.refreshInBackground()
+	}
+	return &bt, nil
+}
+
+// refreshInBackground starts a refresh unless one started recently and is still running,
+// otherwise every prompt until it writes the cache would start another process
+func (b *batt) refreshInBackground() {
+	if _, age, found := b.env.cache().get(batteryRefreshCacheKey); found && age < batteryRefreshTTL {
+		return
+	}
+	b.env.cache().set(batteryRefreshCacheKey, "")
+	_ = b.env.refreshCacheInBackground(batteryCacheKey)
+}
+
+func cacheBatteryInfo(env environmentInfo) (*battery.Battery, error) {
+	bt, err := env.getBatteryInfo()
+	if err != nil {
+		return bt, err
+	}
+	if value, err := json.Marshal(bt); err == nil {
+		env.cache().set(batteryCacheKey, string(value))
+	}
+	return bt, nil
+}
+
 func (b *batt) string() string {
 	return b.percentageText
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/distatus/battery"
 	"github.com/stretchr/testify/assert"
@@ -183,3 +185,95 @@ func TestBatteryChargedAndDisplayChargingDisabled(t *testing.T) {
 	b := setupBatteryTests(battery.Full, 100, props)
 	assert.Equal(t, false, b.enabled())
 }
+
+func setupAsyncBatteryTest(t *testing.T, cached *battery.Battery, age time.Duration) (*batt, *MockedEnvironment, func()) {
+	now := time.Now()
+	fc, cleanup := newTestFileCache(t, now.Add(-age))
+	if cached != nil {
+		value, _ := json.Marshal(cached)
+		fc.set(batteryCacheKey, string(value))
+	}
+	fc.now = func() time.Time { return now }
+	env := &MockedEnvironment{}
+	env.On("cache", nil).Return(fc)
+	env.On("refreshCacheInBackground", batteryCacheKey).Return(nil)
+	env.On("getBatteryInfo", nil).Return(&battery.Battery{
+		State:   battery.Charging,
+		Full:    100,
+		Current: 40,
+	}, nil)
+	b := &batt{
+		props: &properties{
+			values: map[Property]interface{}{
+				Async:           true,
+				StaleIcon:       "*",
+				StaleThreshold:  float64(60),
+				RefreshInterval: float64(10),
+			},
+		},
+		env: env,
+	}
+	return b, env, cleanup
+}
+
+func TestBatteryAsyncRecentCache(t *testing.T) {
+	cached := &battery.Battery{State: battery.Discharging, Full: 100, Current: 70}
+	b, env, cleanup := setupAsyncBatteryTest(t, cached, 5*time.Second)
+	defer cleanup()
+	assert.True(t, b.enabled())
+	assert.Equal(t, "70", b.string())
+	env.AssertNotCalled(t, "getBatteryInfo", nil)
+	env.AssertNotCalled(t, "refreshCacheInBackground", batteryCacheKey)
+}
+
+func TestBatteryAsyncFreshCache(t *testing.T) {
+	cached := &battery.Battery{State: battery.Discharging, Full: 100, Current: 70}
+	b, env, cleanup := setupAsyncBatteryTest(t, cached, 30*time.Second)
+	defer cleanup()
+	assert.True(t, b.enabled())
+	assert.Equal(t, "70", b.string())
+	env.AssertNotCalled(t, "getBatteryInfo", nil)
+	env.AssertCalled(t, "refreshCacheInBackground", batteryCacheKey)
+}
+
+func TestBatteryAsyncStaleCache(t *testing.T) {
+	cached := &battery.Battery{State: battery.Discharging, Full: 100, Current: 70}
+	b, env, cleanup := setupAsyncBatteryTest(t, cached, 5*time.Minute)
+	defer cleanup()
+	assert.True(t, b.enabled())
+	assert.Equal(t, "70*", b.string())
+	env.AssertNotCalled(t, "getBatteryInfo", nil)
+	env.AssertCalled(t, "refreshCacheInBackground", batteryCacheKey)
+}
+
+func TestBatteryAsyncMissingCache(t *testing.T) {
+	b, env, cleanup := setupAsyncBatteryTest(t, nil, 0)
+	defer cleanup()
+	assert.True(t, b.enabled())
+	assert.Equal(t, "40", b.string())
+	env.AssertNotCalled(t, "refreshCacheInBackground", batteryCacheKey)
+	_, _, found := b.env.cache().get(batteryCacheKey)
+	assert.True(t, found)
+}
+
+func TestBatteryAsyncRefreshStartedOnce(t *testing.T) {
+	cached := &battery.Battery{State: battery.Discharging, Full: 100, Current: 70}
+	b, env, cleanup := setupAsyncBatteryTest(t, cached, 30*time.Second)
+	defer cleanup()
+	assert.True(t, b.enabled())
+	assert.True(t, b.enabled())
+	env.AssertNumberOfCalls(t, "refreshCacheInBackground", 1)
+}
+
+func TestBatteryAsyncRefreshMarkerExpired(t *testing.T) {
+	cached := &battery.Battery{State: battery.Discharging, Full: 100, Current: 70}
+	b, env, cleanup := setupAsyncBatteryTest(t, cached, 30*time.Second)
+	defer cleanup()
+	fc := b.env.cache().(*fileCache)
+	now := fc.now()
+	fc.now = func() time.Time { return now.Add(-batteryRefreshTTL) }
+	fc.set(batteryRefreshCacheKey, "")
+	fc.now = func() time.Time { return now }
+	assert.True(t, b.enabled())
+	env.AssertNumberOfCalls(t, "refreshCacheInBackground", 1)
+}
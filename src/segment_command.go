@@ -1,11 +1,16 @@
 package main
 
-import "strings"
+import (
+	"context"
+	"strings"
+)
 
 type command struct {
 	props *properties
 	env   environmentInfo
 	value string
+	// err is the error of the last failing command
+	err error
 }
 
 const (
@@ -24,7 +29,7 @@ func (c *command) enabled() bool {
 	if strings.Contains(command, "||") {
 		commands := strings.Split(command, "||")
 		for _, cmd := range commands {
-			output := c.env.runShellCommand(shell, cmd)
+			output := c.runShellCommand(shell, cmd)
 			if output != "" {
 				c.value = output
 				return true
@@ -35,15 +40,33 @@ func (c *command) enabled() bool {
 		var output string
 		commands := strings.Split(command, "&&")
 		for _, cmd := range commands {
-			output += c.env.runShellCommand(shell, cmd)
+			output += c.runShellCommand(shell, cmd)
 		}
 		c.value = output
 		return c.value != ""
 	}
-	c.value = c.env.runShellCommand(shell, command)
+	c.value = c.runShellCommand(shell, command)
 	return c.value != ""
 }
 
+// runShellCommand runs the command in the shell, when the error is rendered the exit code is kept
+// which requires running it with runCommandContext
+func (c *command) runShellCommand(shell, command string) string {
+	if !c.props.getBool(RenderError, false) {
+		return c.env.runShellCommand(shell, command)
+	}
+	output, _, _, err := c.env.runCommandContext(context.Background(), shell, "-c", command)
+	if err != nil {
+		c.err = err
+		return ""
+	}
+	return output
+}
+
+func (c *command) getError() error {
+	return c.err
+}
+
 func (c *command) string() string {
 	return c.value
 }
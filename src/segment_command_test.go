@@ -126,3 +126,33 @@ func TestExecuteMultipleCommandsOrDisabled(t *testing.T) {
 	enabled := c.enabled()
 	assert.False(t, enabled)
 }
+
+func TestExecuteCommandRenderError(t *testing.T) {
+	cases := []struct {
+		Case          string
+		Command       string
+		RenderError   bool
+		ExpectedError string
+	}{
+		{Case: "Failing command", Command: "exit 3", RenderError: true, ExpectedError: "3"},
+		{Case: "Failing command without render_if_error", Command: "exit 3"},
+		{Case: "Last failing command", Command: "echo hello && exit 2", RenderError: true, ExpectedError: "2"},
+	}
+	for _, tc := range cases {
+		c := &command{
+			props: &properties{
+				values: map[Property]interface{}{
+					Command:     tc.Command,
+					RenderError: tc.RenderError,
+				},
+			},
+			env: &environment{},
+		}
+		c.enabled()
+		if tc.ExpectedError == "" {
+			assert.NoError(t, c.getError(), tc.Case)
+			continue
+		}
+		assert.EqualError(t, c.getError(), tc.ExpectedError, tc.Case)
+	}
+}
@@ -1,7 +1,8 @@
 package main
 
 import (
-	"errors"
+	"context"
+	"time"
 )
 
 type dotnet struct {
@@ -15,6 +16,9 @@ const (
 	// UnsupportedDotnetVersionIcon is displayed when the dotnet version in
 	// the current folder isn't supported by the installed dotnet SDK set.
 	UnsupportedDotnetVersionIcon Property = "unsupported_version_icon"
+
+	// dotnetTimeout stops dotnet --version when it hangs, like during its first run
+	dotnetTimeout = 5 * time.Second
 )
 
 func (d *dotnet) string() string {
@@ -39,7 +43,9 @@ func (d *dotnet) enabled() bool {
 		return false
 	}
 
-	output, err := d.env.runCommand("dotnet", "--version")
+	ctx, cancel := context.WithTimeout(context.Background(), dotnetTimeout)
+	defer cancel()
+	output, _, exitCode, err := d.env.runCommandContext(ctx, "dotnet", "--version")
 	if err == nil {
 		d.activeVersion = output
 		return true
@@ -48,8 +54,7 @@ func (d *dotnet) enabled() bool {
 	// Exit code 145 is a special indicator that dotnet
 	// ran, but the current project config settings specify
 	// use of an SDK that isn't installed.
-	var exerr *commandError
-	if errors.As(err, &exerr) && exerr.exitCode == 145 {
+	if exitCode == 145 {
 		d.unsupportedVersion = true
 		return true
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,16 +13,22 @@ type dotnetArgs struct {
 	unsupported     bool
 	unsupportedIcon string
 	displayVersion  bool
+	exitCode        int
+	stderr          string
 }
 
 func bootStrapDotnetTest(args *dotnetArgs) *dotnet {
 	env := new(MockedEnvironment)
 	env.On("hasCommand", "dotnet").Return(args.enabled)
-	if args.unsupported {
+	switch {
+	case args.unsupported:
 		err := &commandError{exitCode: 145}
-		env.On("runCommand", "dotnet", []string{"--version"}).Return("", err)
-	} else {
-		env.On("runCommand", "dotnet", []string{"--version"}).Return(args.version, nil)
+		env.On("runCommandContext", "dotnet", []string{"--version"}).Return("", "", 145, err)
+	case args.exitCode != 0:
+		err := &commandError{exitCode: args.exitCode}
+		env.On("runCommandContext", "dotnet", []string{"--version"}).Return("", args.stderr, args.exitCode, err)
+	default:
+		env.On("runCommandContext", "dotnet", []string{"--version"}).Return(args.version, "", 0, nil)
 	}
 	props := &properties{
 		values: map[Property]interface{}{
@@ -79,3 +86,25 @@ func TestDotnetVersionUnsupported(t *testing.T) {
 	assert.True(t, dotnet.enabled())
 	assert.Equal(t, expected, dotnet.string())
 }
+
+func TestDotnetVersionFailed(t *testing.T) {
+	args := &dotnetArgs{
+		enabled:        true,
+		displayVersion: true,
+		exitCode:       1,
+		stderr:         "something went wrong",
+	}
+	dotnet := bootStrapDotnetTest(args)
+	assert.False(t, dotnet.enabled())
+}
+
+func TestDotnetTimeout(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "dotnet").Return(true)
+	env.On("runCommandContext", "dotnet", []string{"--version"}).Return("", "", -1, context.DeadlineExceeded)
+	dotnet := &dotnet{
+		env:   env,
+		props: &properties{},
+	}
+	assert.False(t, dotnet.enabled())
+}
@@ -16,6 +16,8 @@ const (
 	ErrorColor Property = "error_color"
 	// AlwaysNumeric shows error codes as numbers
 	AlwaysNumeric Property = "always_numeric"
+	// CodeMap custom text for exit codes, keyed by the number or signal name: 127 or SIGINT
+	CodeMap Property = "code_map"
 )
 
 func (e *exit) enabled() bool {
@@ -50,9 +52,21 @@ func (e *exit) getMeaningFromExitCode() string {
 	if !e.props.getBool(DisplayExitCode, true) {
 		return ""
 	}
+	codeMap := e.props.getKeyValueMap(CodeMap, map[string]string{})
+	if text, ok := codeMap[fmt.Sprintf("%d", e.env.lastErrorCode())]; ok {
+		return text
+	}
+	meaning := e.getExitCodeName()
+	if text, ok := codeMap[meaning]; ok {
+		return text
+	}
 	if e.props.getBool(AlwaysNumeric, false) {
 		return fmt.Sprintf("%d", e.env.lastErrorCode())
 	}
+	return meaning
+}
+
+func (e *exit) getExitCodeName() string {
 	switch e.env.lastErrorCode() {
 	case 1:
 		return "ERROR"
@@ -106,3 +106,54 @@ func TestAlwaysNumericExitCode(t *testing.T) {
 	}
 	assert.Equal(t, "1", e.getMeaningFromExitCode())
 }
+
+func TestExitCodeMap(t *testing.T) {
+	cases := []struct {
+		Case     string
+		ExitCode int
+		Expected string
+	}{
+		{Case: "Mapped code", ExitCode: 127, Expected: "not found"},
+		{Case: "Mapped signal", ExitCode: 130, Expected: "interrupted"},
+		{Case: "Code over signal name", ExitCode: 137, Expected: "killed"},
+		{Case: "Unmapped signal", ExitCode: 143, Expected: "SIGTERM"},
+		{Case: "Unmapped code", ExitCode: 7000, Expected: "7000"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("lastErrorCode", nil).Return(tc.ExitCode)
+		props := &properties{
+			values: map[Property]interface{}{
+				CodeMap: map[string]interface{}{
+					"127":     "not found",
+					"137":     "killed",
+					"SIGINT":  "interrupted",
+					"SIGKILL": "SIGKILL",
+				},
+			},
+		}
+		e := &exit{
+			env:   env,
+			props: props,
+		}
+		assert.Equal(t, tc.Expected, e.getMeaningFromExitCode(), tc.Case)
+	}
+}
+
+func TestExitCodeMapAlwaysNumeric(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("lastErrorCode", nil).Return(130)
+	props := &properties{
+		values: map[Property]interface{}{
+			AlwaysNumeric: true,
+			CodeMap: []interface{}{
+				[]interface{}{"127", "not found"},
+			},
+		},
+	}
+	e := &exit{
+		env:   env,
+		props: props,
+	}
+	assert.Equal(t, "130", e.getMeaningFromExitCode())
+}
@@ -2,9 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type gitRepo struct {
@@ -16,6 +22,11 @@ type gitRepo struct {
 	upstream   string
 	stashCount string
 	root       string
+	branchInfo string
+	// local is the name of the checked out branch, empty when detached
+	local string
+	// commit is the HEAD commit reported by the porcelain v2 status, empty with the short format
+	commit string
 }
 
 type gitStatus struct {
@@ -25,6 +36,8 @@ type gitStatus struct {
 	modified  int
 	untracked int
 	changed   bool
+	// untracked files are no longer counted beyond this number, 0 counts all
+	maxUntracked int
 }
 
 func (s *gitStatus) string(prefix, color string) string {
@@ -38,7 +51,9 @@ func (s *gitStatus) string(prefix, color string) string {
 	status += stringIfValue(s.added, "+")
 	status += stringIfValue(s.modified, "~")
 	status += stringIfValue(s.deleted, "-")
-	status += stringIfValue(s.untracked, "?")
+	if s.untracked > 0 {
+		status += fmt.Sprintf(" ?%s", s.untrackedString())
+	}
 	status += stringIfValue(s.unmerged, "x")
 	if status != "" {
 		return fmt.Sprintf("<%s>%s%s</>", color, prefix, status)
@@ -46,10 +61,71 @@ func (s *gitStatus) string(prefix, color string) string {
 	return status
 }
 
+// untrackedString returns the number of untracked files, capped at maxUntracked: 99+
+func (s *gitStatus) untrackedString() string {
+	if s.maxUntracked > 0 && s.untracked > s.maxUntracked {
+		return fmt.Sprintf("%d+", s.maxUntracked)
+	}
+	return strconv.Itoa(s.untracked)
+}
+
+// gitStash is a single stash entry, the latest one has index 0
+type gitStash struct {
+	Index   int
+	Message string
+}
+
 type git struct {
-	props *properties
-	env   environmentInfo
-	repo  *gitRepo
+	props  *properties
+	env    environmentInfo
+	repo   *gitRepo
+	isBare bool
+	// root is the top level folder of the working tree, empty in a bare repository
+	root string
+	now  func() time.Time
+	// headCommit and headTags are read from git log when the status doesn't provide them
+	headCommit string
+	headTags   []string
+	// userKnown indicates UserName and UserEmail were looked up, an unknown user is no mismatch
+	userKnown bool
+	// the time since the last fetch, LastFetch is its readable form
+	lastFetchElapsed time.Duration
+	// UserName is the effective user.name of the repository
+	UserName string
+	// UserEmail is the effective user.email of the repository
+	UserEmail string
+	// Stash holds the stash entries, latest first
+	Stash []*gitStash
+	// CompareAhead the number of commits HEAD is ahead of the compare_branch
+	CompareAhead int
+	// CompareBehind the number of commits HEAD is behind the compare_branch
+	CompareBehind int
+	// RebaseStep is the step of the rebase in progress
+	RebaseStep int
+	// RebaseTotal is the number of steps of the rebase in progress
+	RebaseTotal int
+	// Signed indicates HEAD has a signature, valid or not
+	Signed bool
+	// SignatureStatus is the readable state of the signature of HEAD
+	SignatureStatus string
+	// BaseBranch is the default branch of origin, resolved from refs/remotes/origin/HEAD
+	BaseBranch string
+	// UpstreamBranch is the name of the upstream branch without the remote: feature/x for origin/feature/x
+	UpstreamBranch string
+	// NameMismatch indicates the local branch name differs from the upstream branch name
+	NameMismatch bool
+	// LocalBranches is the number of local branches
+	LocalBranches int
+	// Tags holds the tags pointing at HEAD
+	Tags []string
+	// IndexLocked indicates another git process holds .git/index.lock
+	IndexLocked bool
+	// HasUpstreamChanges indicates the upstream has commits to pull, the branch is behind
+	HasUpstreamChanges bool
+	// HasLocalChanges indicates there are local commits to push, the branch is ahead
+	HasLocalChanges bool
+	// LastFetch is the time since the remotes were last fetched, like 5m 12s, empty when never fetched
+	LastFetch string
 }
 
 const (
@@ -63,6 +139,10 @@ const (
 	BranchBehindIcon Property = "branch_behind_icon"
 	// BranchGoneIcon the icon to use when ther's no remote
 	BranchGoneIcon Property = "branch_gone_icon"
+	// SyncedIcon the icon to display when the local branch is in sync with the remote, defaults to the branch_identical_icon
+	SyncedIcon Property = "branch_synced_icon"
+	// DivergedIcon the icon to display instead of the ahead and behind counts when the branches diverged, disabled when empty
+	DivergedIcon Property = "branch_diverged_icon"
 	// LocalWorkingIcon the icon to use as the local working area changes indicator
 	LocalWorkingIcon Property = "local_working_icon"
 	// LocalStagingIcon the icon to use as the local staging area changes indicator
@@ -77,6 +157,8 @@ const (
 	CherryPickIcon Property = "cherry_pick_icon"
 	// CommitIcon shows before the detached context
 	CommitIcon Property = "commit_icon"
+	// CommitHashLength the number of characters of the commit hash to display
+	CommitHashLength Property = "commit_hash_length"
 	// TagIcon shows before the tag context
 	TagIcon Property = "tag_icon"
 	// DisplayStashCount show stash count or not
@@ -101,6 +183,10 @@ const (
 	WorkingColor Property = "working_color"
 	// StagingColor if set, the color to use on the staging area
 	StagingColor Property = "staging_color"
+	// StagedForeground if set, the foreground color of the staged changes, takes precedence over staging_color
+	StagedForeground Property = "staged_foreground"
+	// UnstagedForeground if set, the foreground color of the unstaged changes, takes precedence over working_color
+	UnstagedForeground Property = "unstaged_foreground"
 	// StatusColorsEnabled enables status colors
 	StatusColorsEnabled Property = "status_colors_enabled"
 	// LocalChangesColor if set, the color to use when there are local changes
@@ -111,17 +197,149 @@ const (
 	BehindColor Property = "behind_color"
 	// AheadColor if set, the color to use when the branch is ahead and behind the remote
 	AheadColor Property = "ahead_color"
+	// BranchInfoRegex regex with named capture groups to extract information from the branch name
+	BranchInfoRegex Property = "branch_info_regex"
+	// BranchInfoTemplate the template to render the captured branch information
+	BranchInfoTemplate Property = "branch_info_template"
+	// DisplayTag shows the tag instead of the commit hash when HEAD is detached at a tag
+	DisplayTag Property = "display_tag"
+	// BareIcon shows before the HEAD context of a bare repository
+	BareIcon Property = "bare_icon"
+	// FetchUser fetches the configured user.name and user.email of the repository
+	FetchUser Property = "fetch_user"
+	// ExpectedEmail the user.email the repository should be configured with
+	ExpectedEmail Property = "expected_email"
+	// UserMismatchIcon shows when user.email does not match the expected email
+	UserMismatchIcon Property = "user_mismatch_icon"
+	// FetchStashList fetches the stash entries including their message
+	FetchStashList Property = "fetch_stash_list"
+	// MaxUntracked the maximum number of untracked files to count, displayed as 99+ when exceeded
+	MaxUntracked Property = "max_untracked"
+	// CompareBranch the branch, like upstream/main, to count the commits ahead and behind of
+	CompareBranch Property = "compare_branch"
+	// FetchSignature fetches the signature status of HEAD
+	FetchSignature Property = "fetch_signature"
+	// SignatureGoodIcon shows when HEAD has a good signature
+	SignatureGoodIcon Property = "signature_good_icon"
+	// SignatureBadIcon shows when HEAD has a bad signature
+	SignatureBadIcon Property = "signature_bad_icon"
+	// SignatureUntrustedIcon shows when the signature of HEAD is good but can't be trusted, like an expired or unknown key
+	SignatureUntrustedIcon Property = "signature_untrusted_icon"
+	// SignatureNoneIcon shows when HEAD is not signed
+	SignatureNoneIcon Property = "signature_none_icon"
+	// DisplayStatusBar replaces the status details with a compact bar of the staged, modified, untracked and conflicted files
+	DisplayStatusBar Property = "display_status_bar"
+	// StatusBarStagedIcon the icon before the number of staged files in the status bar
+	StatusBarStagedIcon Property = "status_bar_staged_icon"
+	// StatusBarModifiedIcon the icon before the number of modified files in the status bar
+	StatusBarModifiedIcon Property = "status_bar_modified_icon"
+	// StatusBarUntrackedIcon the icon before the number of untracked files in the status bar
+	StatusBarUntrackedIcon Property = "status_bar_untracked_icon"
+	// StatusBarConflictedIcon the icon before the number of conflicted files in the status bar
+	StatusBarConflictedIcon Property = "status_bar_conflicted_icon"
+	// StatusBarStagedColor the color of the staged files in the status bar
+	StatusBarStagedColor Property = "status_bar_staged_color"
+	// StatusBarModifiedColor the color of the modified files in the status bar
+	StatusBarModifiedColor Property = "status_bar_modified_color"
+	// StatusBarUntrackedColor the color of the untracked files in the status bar
+	StatusBarUntrackedColor Property = "status_bar_untracked_color"
+	// StatusBarConflictedColor the color of the conflicted files in the status bar
+	StatusBarConflictedColor Property = "status_bar_conflicted_color"
+	// NameMismatchIcon shows when the local branch name differs from the upstream branch name, disabled when empty
+	NameMismatchIcon Property = "name_mismatch_icon"
+	// FetchInBackground fetches the remotes in a background process once the fetch_interval passed
+	FetchInBackground Property = "fetch_in_background"
+	// FetchInterval the number of seconds between two background fetches
+	FetchInterval Property = "fetch_interval"
+	// DisplayBaseBranch displays the default branch of origin after the branch name
+	DisplayBaseBranch Property = "display_base_branch"
+	// BaseBranchIcon the separator between the branch name and the base branch
+	BaseBranchIcon Property = "base_branch_icon"
+	// DisplayLocalBranches displays the number of local branches, counted in the files of .git
+	DisplayLocalBranches Property = "display_local_branches"
+	// LocalBranchesIcon shows before the number of local branches
+	LocalBranchesIcon Property = "local_branches_icon"
+	// DisplayHEADTags displays the first tag pointing at HEAD, followed by +N for the other ones
+	DisplayHEADTags Property = "display_head_tags"
+	// HEADTagsIcon shows before the tags pointing at HEAD
+	HEADTagsIcon Property = "head_tags_icon"
+	// DisplayIndexLock displays the IndexLockedIcon while another git process holds the index lock
+	DisplayIndexLock Property = "display_index_lock"
+	// IndexLockedIcon shows in front of the HEAD context when the index is locked
+	IndexLockedIcon Property = "index_locked_icon"
+	// DisplayLastFetch displays the time since the remotes were last fetched
+	DisplayLastFetch Property = "display_last_fetch"
+	// LastFetchIcon shows before the time since the last fetch
+	LastFetchIcon Property = "last_fetch_icon"
+	// LastFetchStaleThreshold the number of seconds after which the last fetch is stale
+	LastFetchStaleThreshold Property = "last_fetch_stale_threshold"
+	// LastFetchStaleColor the color of the time since the last fetch when it's stale
+	LastFetchStaleColor Property = "last_fetch_stale_color"
+	// ColorBranchByName colors the branch name with a color of the branch_color_palette picked by hashing the name
+	ColorBranchByName Property = "color_branch_by_name"
+	// BranchColorPalette the colors to pick the color of a branch name from
+	BranchColorPalette Property = "branch_color_palette"
+	// PullNeededIcon the icon to display when the upstream has commits to pull, disabled when empty
+	PullNeededIcon Property = "pull_needed_icon"
+	// PushNeededIcon the icon to display when there are local commits to push, disabled when empty
+	PushNeededIcon Property = "push_needed_icon"
+
+	signatureGood       = "good"
+	signatureBad        = "bad"
+	signatureUnknown    = "unknown validity"
+	signatureExpired    = "expired"
+	signatureExpiredKey = "expired key"
+	signatureRevokedKey = "revoked key"
+	signatureMissingKey = "missing key"
+	signatureNone       = "none"
+
+	// the default colors of the staged and unstaged changes
+	defaultStagedForeground   = "green"
+	defaultUnstagedForeground = "red"
+
+	// the user and the compare_branch counts are looked up by a background process, the prompt uses the cached values
+	// and starts a new lookup once they're older than their TTL
+	gitUserCacheKey    = "git_user"
+	gitUserTTL         = time.Minute
+	gitCompareCacheKey = "git_compare"
+	gitCompareTTL      = time.Minute
+
+	gitFetchCacheKey = "git_fetch"
+	// a fetch which takes longer is abandoned, its lock can be taken over after the lock timeout
+	gitFetchTimeout     = 60 * time.Second
+	gitFetchLockTimeout = 2 * gitFetchTimeout
+	// the cache key remembering git only knows the short status format, checked again after the TTL
+	gitShortStatusCacheKey = "git_short_status"
+	gitShortStatusTTL      = 24 * time.Hour
+	// the largest status kept for display_index_lock, a repository with more changes shows no status while it's locked
+	gitStatusCacheLimit = 32 * 1024
 )
 
 func (g *git) enabled() bool {
 	if !g.env.hasCommand("git") {
 		return false
 	}
-	output, _ := g.env.runCommand("git", "rev-parse", "--is-inside-work-tree")
-	return output == "true"
+	// a single call answers all of them, one line each: true or false and the top level folder.
+	// In a bare repository git fails on the top level folder after answering the first two
+	output, _ := g.env.runCommand("git", "rev-parse", "--is-inside-work-tree", "--is-bare-repository", "--show-toplevel")
+	lines := strings.Split(output, "\n")
+	if len(lines) < 2 {
+		return false
+	}
+	if lines[0] == "true" {
+		if len(lines) > 2 {
+			g.root = lines[2]
+		}
+		return true
+	}
+	g.isBare = lines[1] == "true"
+	return g.isBare
 }
 
 func (g *git) string() string {
+	if g.isBare {
+		return g.getBareString()
+	}
 	g.setGitStatus()
 	if g.props.getBool(StatusColorsEnabled, false) {
 		g.SetStatusColor()
@@ -131,11 +349,112 @@ func (g *git) string() string {
 	if g.repo.upstream != "" && g.props.getBool(DisplayUpstreamIcon, false) {
 		fmt.Fprintf(buffer, "%s", g.getUpstreamSymbol())
 	}
-	fmt.Fprintf(buffer, "%s", g.repo.HEAD)
+	if g.IndexLocked {
+		fmt.Fprint(buffer, g.props.getString(IndexLockedIcon, "\uF023 "))
+	}
+	if branchColor := g.getBranchColor(); branchColor != "" {
+		fmt.Fprintf(buffer, "<%s>%s</>", branchColor, g.repo.HEAD)
+	} else {
+		fmt.Fprintf(buffer, "%s", g.repo.HEAD)
+	}
+	if g.repo.branchInfo != "" {
+		fmt.Fprintf(buffer, " %s", g.repo.branchInfo)
+	}
+	fmt.Fprint(buffer, g.getNameMismatchIcon())
+	fmt.Fprint(buffer, g.getBaseBranchString())
+	fmt.Fprint(buffer, g.getHEADTagsString())
+	if g.props.getBool(DisplayLocalBranches, false) {
+		fmt.Fprintf(buffer, " %s%d", g.props.getString(LocalBranchesIcon, "\uE725 "), g.LocalBranches)
+	}
 	displayStatus := g.props.getBool(DisplayStatus, true)
 	if !displayStatus {
 		return buffer.String()
 	}
+	fmt.Fprint(buffer, g.getBranchStatus())
+	fmt.Fprint(buffer, g.getSyncHints())
+	fmt.Fprint(buffer, g.getLastFetchString())
+	if g.props.getBool(DisplayStatusBar, false) {
+		fmt.Fprint(buffer, g.getStatusBar())
+	} else {
+		fmt.Fprint(buffer, g.getStatusDetails())
+	}
+	if g.props.getBool(DisplayStashCount, false) && g.repo.stashCount != "" {
+		fmt.Fprintf(buffer, " %s%s", g.props.getString(StashCountIcon, "\uF692 "), g.repo.stashCount)
+	}
+	if g.userMismatch() {
+		fmt.Fprintf(buffer, " %s", g.props.getString(UserMismatchIcon, "\uF071"))
+	}
+	if signatureIcon := g.getSignatureIcon(); signatureIcon != "" {
+		fmt.Fprintf(buffer, " %s", signatureIcon)
+	}
+	return buffer.String()
+}
+
+// getStatusDetails returns the staging and working area changes, separated by the status_separator_icon
+func (g *git) getStatusDetails() string {
+	buffer := new(bytes.Buffer)
+	if g.repo.staging.changed {
+		fmt.Fprint(buffer, g.getStatusDetailString(g.repo.staging, StagedForeground, StagingColor, LocalStagingIcon, " \uF046", defaultStagedForeground))
+	}
+	if g.repo.staging.changed && g.repo.working.changed {
+		fmt.Fprint(buffer, g.props.getString(StatusSeparatorIcon, " |"))
+	}
+	if g.repo.working.changed {
+		fmt.Fprint(buffer, g.getStatusDetailString(g.repo.working, UnstagedForeground, WorkingColor, LocalWorkingIcon, " \uF044", defaultUnstagedForeground))
+	}
+	return buffer.String()
+}
+
+// getStatusBar returns the number of staged, modified, untracked and conflicted files
+// as a compact bar like \u25CF3 \u271A2 \u20261, categories without files are left out
+func (g *git) getStatusBar() string {
+	staging := g.repo.staging
+	working := g.repo.working
+	conflicted := working.unmerged
+	if staging.unmerged > conflicted {
+		conflicted = staging.unmerged
+	}
+	staged := staging.added + staging.modified + staging.deleted
+	modified := working.added + working.modified + working.deleted
+	categories := []struct {
+		count       int
+		value       string
+		icon        Property
+		defaultIcon string
+		color       Property
+	}{
+		{staged, strconv.Itoa(staged), StatusBarStagedIcon, "\u25CF", StatusBarStagedColor},
+		{modified, strconv.Itoa(modified), StatusBarModifiedIcon, "\u271A", StatusBarModifiedColor},
+		{working.untracked, working.untrackedString(), StatusBarUntrackedIcon, "\u2026", StatusBarUntrackedColor},
+		{conflicted, strconv.Itoa(conflicted), StatusBarConflictedIcon, "\u2716", StatusBarConflictedColor},
+	}
+	buffer := new(bytes.Buffer)
+	for _, category := range categories {
+		if category.count == 0 {
+			continue
+		}
+		text := g.props.getString(category.icon, category.defaultIcon) + category.value
+		if color := g.props.getColor(category.color, ""); color != "" {
+			text = fmt.Sprintf("<%s>%s</>", color, text)
+		}
+		fmt.Fprintf(buffer, " %s", text)
+	}
+	return buffer.String()
+}
+
+// getBranchStatus returns the symbols describing the local branch compared to its upstream
+func (g *git) getBranchStatus() string {
+	if g.repo.upstream == "" {
+		return fmt.Sprintf(" %s", g.props.getString(BranchGoneIcon, "\u2262"))
+	}
+	if g.repo.ahead == 0 && g.repo.behind == 0 {
+		identicalIcon := g.props.getString(BranchIdenticalIcon, "\u2261")
+		return fmt.Sprintf(" %s", g.props.getString(SyncedIcon, identicalIcon))
+	}
+	if divergedIcon := g.props.getString(DivergedIcon, ""); g.repo.ahead > 0 && g.repo.behind > 0 && divergedIcon != "" {
+		return fmt.Sprintf(" %s", divergedIcon)
+	}
+	buffer := new(bytes.Buffer)
 	// if ahead, print with symbol
 	if g.repo.ahead > 0 {
 		fmt.Fprintf(buffer, " %s%d", g.props.getString(BranchAheadIcon, "\u2191"), g.repo.ahead)
@@ -144,34 +463,71 @@ func (g *git) string() string {
 	if g.repo.behind > 0 {
 		fmt.Fprintf(buffer, " %s%d", g.props.getString(BranchBehindIcon, "\u2193"), g.repo.behind)
 	}
-	if g.repo.behind == 0 && g.repo.ahead == 0 && g.repo.upstream != "" {
-		fmt.Fprintf(buffer, " %s", g.props.getString(BranchIdenticalIcon, "\u2261"))
-	} else if g.repo.upstream == "" {
-		fmt.Fprintf(buffer, " %s", g.props.getString(BranchGoneIcon, "\u2262"))
+	return buffer.String()
+}
+
+// setSyncState derives whether there's something to pull or push from the ahead and behind counts
+func (g *git) setSyncState() {
+	g.HasUpstreamChanges = g.repo.behind > 0
+	g.HasLocalChanges = g.repo.ahead > 0
+}
+
+// getSyncHints returns the pull_needed_icon when the upstream has commits to pull
+// and the push_needed_icon when there are local commits to push
+func (g *git) getSyncHints() string {
+	buffer := new(bytes.Buffer)
+	if pullIcon := g.props.getString(PullNeededIcon, ""); g.HasUpstreamChanges && pullIcon != "" {
+		fmt.Fprintf(buffer, " %s", pullIcon)
 	}
-	if g.repo.staging.changed {
-		fmt.Fprint(buffer, g.getStatusDetailString(g.repo.staging, StagingColor, LocalStagingIcon, " \uF046"))
+	if pushIcon := g.props.getString(PushNeededIcon, ""); g.HasLocalChanges && pushIcon != "" {
+		fmt.Fprintf(buffer, " %s", pushIcon)
 	}
-	if g.repo.staging.changed && g.repo.working.changed {
-		fmt.Fprint(buffer, g.props.getString(StatusSeparatorIcon, " |"))
+	return buffer.String()
+}
+
+// Dirty indicates there are changes in the working or staging area
+func (g *git) Dirty() bool {
+	if g.repo == nil {
+		return false
 	}
-	if g.repo.working.changed {
-		fmt.Fprint(buffer, g.getStatusDetailString(g.repo.working, WorkingColor, LocalWorkingIcon, " \uF044"))
+	return g.repo.working.changed || g.repo.staging.changed
+}
+
+// IsBare indicates the current folder is a bare repository
+func (g *git) IsBare() bool {
+	return g.isBare
+}
+
+// getBareString only displays the HEAD context,
+// a bare repository has no working or staging area
+func (g *git) getBareString() string {
+	bareIcon := g.props.getString(BareIcon, "\uF1C0 ")
+	ref := g.getGitCommandOutput("symbolic-ref", "--short", "HEAD")
+	if ref == "" {
+		g.setHEADDetails(true)
+		return fmt.Sprintf("%s%s", bareIcon, g.getPrettyHEADName())
 	}
-	if g.props.getBool(DisplayStashCount, false) && g.repo.stashCount != "" {
-		fmt.Fprintf(buffer, " %s%s", g.props.getString(StashCountIcon, "\uF692 "), g.repo.stashCount)
+	return fmt.Sprintf("%s%s%s", bareIcon, g.props.getString(BranchIcon, "\uE0A0"), ref)
+}
+
+// Untracked returns the number of untracked files, capped at max_untracked
+func (g *git) Untracked() string {
+	if g.repo == nil || g.repo.working.untracked == 0 {
+		return ""
 	}
-	return buffer.String()
+	return g.repo.working.untrackedString()
 }
 
 func (g *git) init(props *properties, env environmentInfo) {
 	g.props = props
 	g.env = env
+	g.now = time.Now
 }
 
-func (g *git) getStatusDetailString(status *gitStatus, color, icon Property, defaultIcon string) string {
+// getStatusDetailString colors the changes using the foreground property, the color property or the default color in that order
+func (g *git) getStatusDetailString(status *gitStatus, foreground, color, icon Property, defaultIcon, defaultColor string) string {
 	prefix := g.props.getString(icon, defaultIcon)
-	foregroundColor := g.props.getColor(color, g.props.foreground)
+	foregroundColor := g.props.getColor(foreground, g.props.getColor(color, defaultColor))
 	if !g.props.getBool(DisplayStatusDetail, true) {
 		return fmt.Sprintf("<%s>%s</>", foregroundColor, prefix)
 	}
@@ -180,7 +536,7 @@ func (g *git) getStatusDetailString(status *gitStatus, color, icon Property, def
 
 func (g *git) getUpstreamSymbol() string {
 	upstream := replaceAllString("/.*", g.repo.upstream, "")
-	url := g.getGitCommandOutput("remote", "get-url", upstream)
+	url := parseRemoteURL(g.getGitFileContents("config"), upstream)
 	if strings.Contains(url, "github") {
 		return g.props.getString(GithubIcon, "\uF408 ")
 	}
@@ -193,10 +549,33 @@ func (g *git) getUpstreamSymbol() string {
 	return g.props.getString(GitIcon, "\uE5FB ")
 }
 
+// parseRemoteURL returns the url of the remote in the section of the repository configuration: [remote "origin"]
+func parseRemoteURL(config, remote string) string {
+	section := fmt.Sprintf(`[remote "%s"]`, remote)
+	var inSection bool
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		}
+		index := strings.Index(line, "=")
+		if !inSection || index == -1 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:index]), "url") {
+			return strings.TrimSpace(line[index+1:])
+		}
+	}
+	return ""
+}
+
 func (g *git) setGitStatus() {
-	g.repo = &gitRepo{}
-	g.repo.root = g.getGitCommandOutput("rev-parse", "--show-toplevel")
-	output := g.getGitCommandOutput("status", "-unormal", "--short", "--branch")
+	g.repo = &gitRepo{root: g.root}
+	output := g.getStatusOutput()
+	if strings.HasPrefix(output, "# branch.") {
+		output, g.repo.commit, g.repo.stashCount = parsePorcelainV2(output)
+	}
 	splittedOutput := strings.Split(output, "\n")
 	g.repo.working = g.parseGitStats(splittedOutput, true)
 	g.repo.staging = g.parseGitStats(splittedOutput, false)
@@ -208,8 +587,440 @@ func (g *git) setGitStatus() {
 			g.repo.upstream = status["upstream"]
 		}
 	}
+	g.repo.local = status["local"]
+	g.setSyncState()
+	g.setUpstreamBranch(status)
+	g.setHEADDetails(status["local"] == "")
 	g.repo.HEAD = g.getGitHEADContext(status["local"])
-	g.repo.stashCount = g.getStashContext()
+	// the porcelain v2 status only counts the stash entries from git 2.35 on
+	if g.repo.stashCount == "" && g.props.getBool(DisplayStashCount, false) {
+		g.repo.stashCount = g.getStashContext()
+	}
+	if g.props.getBool(FetchStashList, false) {
+		g.Stash = g.getStashList()
+	}
+	g.repo.branchInfo = g.getBranchInfo(status["local"])
+	g.setUser()
+	g.setCompareCounts()
+	g.setLocalBranches()
+	g.setLastFetch()
+	g.setBaseBranch()
+	g.fetchInBackground()
+}
+
+// getStatusOutput returns the output of git status in the porcelain v2 format, which also holds the HEAD commit and the
+// number of stash entries. Versions of git which don't know it fall back to the short format, this is remembered for a day.
+// While another git process holds the index lock, the call is skipped and the status of the last prompt is used instead
+func (g *git) getStatusOutput() string {
+	cacheKey := fmt.Sprintf("git_status_%s", g.repo.root)
+	if g.props.getBool(DisplayIndexLock, false) {
+		g.IndexLocked = g.hasGitFile("index.lock")
+	}
+	if g.IndexLocked {
+		output, _, _ := g.env.cache().get(cacheKey)
+		return output
+	}
+	output := g.getStatusCommandOutput()
+	if !g.props.getBool(DisplayIndexLock, false) || output == "" {
+		return output
+	}
+	value := output
+	if len(value) > gitStatusCacheLimit {
+		value = ""
+	}
+	// only write the cache file when the status changed
+	if cached, _, found := g.env.cache().get(cacheKey); !found || cached != value {
+		g.env.cache().set(cacheKey, value)
+	}
+	return output
+}
+
+// getStatusCommandOutput runs git status, both formats always start with the branch, empty output means git failed
+func (g *git) getStatusCommandOutput() string {
+	if _, age, found := g.env.cache().get(gitShortStatusCacheKey); !found || age > gitShortStatusTTL {
+		if output := g.getGitCommandOutput("status", "-unormal", "--porcelain=2", "--branch", "--show-stash"); output != "" {
+			return output
+		}
+	}
+	output := g.getGitCommandOutput("status", "-unormal", "--short", "--branch")
+	if output != "" {
+		g.env.cache().set(gitShortStatusCacheKey, "true")
+	}
+	return output
+}
+
+// fetchInBackground starts a background fetch of the repository when the last one is older than the fetch_interval.
+// Prompts opening at the same time coordinate using a lock file, only one of them fetches
+func (g *git) fetchInBackground() {
+	if !g.props.getBool(FetchInBackground, false) || g.repo.root == "" {
+		return
+	}
+	key := fmt.Sprintf("%s:%s", gitFetchCacheKey, g.repo.root)
+	if _, age, found := g.env.cache().get(key); found && age.Seconds() < g.props.getFloat64(FetchInterval, 300) {
+		return
+	}
+	// mark the fetch as started, the following prompts don't start another one
+	g.env.cache().set(key, "")
+	_ = g.env.refreshCacheInBackground(key)
+}
+
+// gitFetch runs in the background process, when another process holds the lock
+// it is already fetching the repository and the result ends up in the same cache entry
+func gitFetch(env environmentInfo, root string, lock *fileLock) {
+	if root == "" || !lock.tryLock() {
+		return
+	}
+	defer lock.unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), gitFetchTimeout)
+	defer cancel()
+	_, _, _, _ = env.runCommandContext(ctx, "git", "-C", root, "fetch", "--quiet")
+	env.cache().set(fmt.Sprintf("%s:%s", gitFetchCacheKey, root), "")
+}
+
+func gitFetchLockPath(root string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(root))
+	return filepath.Join(cacheFolder(), fmt.Sprintf("%s_%x.lock", gitFetchCacheKey, hash.Sum64()))
+}
+
+// getNameMismatchIcon returns the name_mismatch_icon when the local branch name differs from its upstream
+func (g *git) getNameMismatchIcon() string {
+	icon := g.props.getString(NameMismatchIcon, "\u2260")
+	if !g.NameMismatch || icon == "" {
+		return ""
+	}
+	return fmt.Sprintf(" %s", icon)
+}
+
+// getBaseBranchString returns the base branch preceded by the base_branch_icon,
+// nothing is displayed when the base branch is checked out
+func (g *git) getBaseBranchString() string {
+	if !g.props.getBool(DisplayBaseBranch, false) || g.BaseBranch == "" || g.BaseBranch == g.repo.local {
+		return ""
+	}
+	return fmt.Sprintf("%s%s", g.props.getString(BaseBranchIcon, " \u2190 "), g.BaseBranch)
+}
+
+// setBaseBranch resolves the default branch of origin from refs/remotes/origin/HEAD,
+// a symbolic ref which is never packed: ref: refs/remotes/origin/main
+func (g *git) setBaseBranch() {
+	if !g.props.getBool(DisplayBaseBranch, false) {
+		return
+	}
+	ref := g.getGitFileContents("refs/remotes/origin/HEAD")
+	if !strings.HasPrefix(ref, "ref: refs/remotes/origin/") {
+		return
+	}
+	g.BaseBranch = strings.TrimPrefix(ref, "ref: refs/remotes/origin/")
+}
+
+// setUpstreamBranch compares the local branch name with the one of its upstream,
+// the upstream of a local wip branch can be origin/feature/x
+func (g *git) setUpstreamBranch(status map[string]string) {
+	upstream := status["upstream"]
+	if index := strings.Index(upstream, "/"); index != -1 {
+		upstream = upstream[index+1:]
+	}
+	g.UpstreamBranch = upstream
+	g.NameMismatch = upstream != "" && upstream != status["local"]
+}
+
+// setUser uses the cached user of the repository, it's looked up in the background by gitUser
+func (g *git) setUser() {
+	if !g.props.getBool(FetchUser, false) && g.props.getString(ExpectedEmail, "") == "" {
+		return
+	}
+	key := fmt.Sprintf("%s:%s", gitUserCacheKey, g.repo.root)
+	value, age, found := g.env.cache().get(key)
+	if user := strings.SplitN(value, "\n", 2); len(user) == 2 {
+		g.UserName, g.UserEmail = user[0], user[1]
+		g.userKnown = true
+	}
+	if found && age < gitUserTTL {
+		return
+	}
+	// mark the lookup as started, the following prompts keep using the known user
+	g.env.cache().set(key, value)
+	_ = g.env.refreshCacheInBackground(key)
+}
+
+// gitUser runs in the background process, it caches the user.name and the user.email of the repository
+// separated by a newline, both are empty when git isn't configured
+func gitUser(env environmentInfo, root string) {
+	output, _ := env.runCommand("git", "-C", root, "config", "--get-regexp", `^user\.(name|email)$`)
+	var name, email string
+	for _, line := range strings.Split(output, "\n") {
+		values := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(values) != 2 {
+			continue
+		}
+		switch values[0] {
+		case "user.name":
+			name = values[1]
+		case "user.email":
+			email = values[1]
+		}
+	}
+	env.cache().set(fmt.Sprintf("%s:%s", gitUserCacheKey, root), name+"\n"+email)
+}
+
+// setHEADDetails reads the commit, the tags and the signature of HEAD in a single git log call, only what's displayed:
+// the commit when HEAD is detached or compared and the status doesn't know it, the tags for display_head_tags and the
+// name of a detached HEAD, the signature for fetch_signature. Nothing is read when none of them is needed
+func (g *git) setHEADDetails(detached bool) {
+	var commit string
+	if g.repo != nil {
+		commit = g.repo.commit
+	}
+	displayTags := g.props.getBool(DisplayHEADTags, false)
+	fetchSignature := g.props.getBool(FetchSignature, false)
+	needsTags := displayTags || (detached && g.props.getBool(DisplayTag, true))
+	needsCommit := commit == "" && (detached || g.props.getString(CompareBranch, "") != "")
+	if !needsTags && !needsCommit && !fetchSignature {
+		return
+	}
+	format := "--format=%H%n%D"
+	if fetchSignature {
+		format += "%n%G?"
+	}
+	lines := strings.Split(g.getGitCommandOutput("log", "-1", "--decorate=short", format), "\n")
+	g.headCommit = lines[0]
+	if len(lines) > 1 {
+		g.headTags = parseTags(lines[1])
+	}
+	if displayTags {
+		g.Tags = g.headTags
+	}
+	if !fetchSignature {
+		return
+	}
+	var code string
+	if len(lines) > 2 {
+		code = lines[2]
+	}
+	g.SignatureStatus = parseSignatureStatus(code)
+	g.Signed = g.SignatureStatus != signatureNone
+}
+
+// setLocalBranches counts the branches in .git/refs/heads and the ones in .git/packed-refs,
+// a branch which was updated after it was packed is in both
+func (g *git) setLocalBranches() {
+	if !g.props.getBool(DisplayLocalBranches, false) {
+		return
+	}
+	branches := make(map[string]bool)
+	g.addLooseRefs(branches, "refs/heads")
+	for _, line := range strings.Split(g.getGitFileContents("packed-refs"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.HasPrefix(fields[1], "refs/heads/") {
+			branches[fields[1]] = true
+		}
+	}
+	g.LocalBranches = len(branches)
+}
+
+// addLooseRefs adds the refs in the folder of .git and its subfolders, feature/x is stored as refs/heads/feature/x
+func (g *git) addLooseRefs(refs map[string]bool, folder string) {
+	names, err := g.env.getDirEntries(fmt.Sprintf("%s/.git/%s", g.repo.root, folder), -1)
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		ref := fmt.Sprintf("%s/%s", folder, name)
+		if g.hasGitFolder(ref) {
+			g.addLooseRefs(refs, ref)
+			continue
+		}
+		refs[ref] = true
+	}
+}
+
+// parseTags returns the tags in the ref names of git log --format=%D, sorted like git tag does:
+// HEAD -> main, tag: v1.0.0, origin/main
+func parseTags(refs string) []string {
+	var tags []string
+	for _, ref := range strings.Split(refs, ",") {
+		ref = strings.TrimSpace(ref)
+		if strings.HasPrefix(ref, "tag: ") {
+			tags = append(tags, strings.TrimPrefix(ref, "tag: "))
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// getHEADTagsString returns the first tag pointing at HEAD, followed by the number of other ones: v1.0.0 +2
+func (g *git) getHEADTagsString() string {
+	if len(g.Tags) == 0 {
+		return ""
+	}
+	tags := fmt.Sprintf(" %s%s", g.props.getString(HEADTagsIcon, "\uF412"), g.Tags[0])
+	if len(g.Tags) > 1 {
+		tags += fmt.Sprintf(" +%d", len(g.Tags)-1)
+	}
+	return tags
+}
+
+// setLastFetch uses the modification time of FETCH_HEAD, written by every fetch and pull
+func (g *git) setLastFetch() {
+	if !g.props.getBool(DisplayLastFetch, false) {
+		return
+	}
+	g.LastFetch = ""
+	modTime, err := g.env.getModTime(fmt.Sprintf("%s/.git/FETCH_HEAD", g.repo.root))
+	if err != nil {
+		return
+	}
+	elapsed := g.now().Sub(modTime).Truncate(time.Second)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	g.lastFetchElapsed = elapsed
+	g.LastFetch = new(executiontime).formatDuration(elapsed.Milliseconds(), Austin)
+}
+
+// getLastFetchString returns the time since the last fetch, in the stale color once it exceeds the threshold
+func (g *git) getLastFetchString() string {
+	if g.LastFetch == "" {
+		return ""
+	}
+	lastFetch := g.LastFetch
+	threshold := g.props.getFloat64(LastFetchStaleThreshold, 0)
+	staleColor := g.props.getColor(LastFetchStaleColor, "")
+	if threshold > 0 && staleColor != "" && g.lastFetchElapsed.Seconds() >= threshold {
+		lastFetch = fmt.Sprintf("<%s>%s</>", staleColor, lastFetch)
+	}
+	return fmt.Sprintf(" %s%s", g.props.getString(LastFetchIcon, "\uF0ED "), lastFetch)
+}
+
+// defaultBranchColors are distinct colors readable on both dark and light backgrounds
+var defaultBranchColors = []string{"#e06c75", "#98c379", "#e5c07b", "#61afef", "#c678dd", "#56b6c2", "#d19a66", "#be5046"}
+
+// getBranchColor returns the color of the checked out branch, the hash of its name picks a color
+// of the branch_color_palette so a branch has the same color in every prompt. Empty when detached
+func (g *git) getBranchColor() string {
+	if !g.props.getBool(ColorBranchByName, false) || g.repo.local == "" {
+		return ""
+	}
+	colors := g.props.getStringArray(BranchColorPalette, defaultBranchColors)
+	if len(colors) == 0 {
+		colors = defaultBranchColors
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(g.repo.local))
+	return colors[hash.Sum32()%uint32(len(colors))]
+}
+
+// parseSignatureStatus maps the signature code of git log --format=%G? to a readable state
+func parseSignatureStatus(code string) string {
+	switch strings.TrimSpace(code) {
+	case "G":
+		return signatureGood
+	case "B":
+		return signatureBad
+	case "U":
+		return signatureUnknown
+	case "X":
+		return signatureExpired
+	case "Y":
+		return signatureExpiredKey
+	case "R":
+		return signatureRevokedKey
+	case "E":
+		return signatureMissingKey
+	default:
+		return signatureNone
+	}
+}
+
+func (g *git) getSignatureIcon() string {
+	switch g.SignatureStatus {
+	case "":
+		return ""
+	case signatureGood:
+		return g.props.getString(SignatureGoodIcon, "\uF00C")
+	case signatureBad:
+		return g.props.getString(SignatureBadIcon, "\uF00D")
+	case signatureNone:
+		return g.props.getString(SignatureNoneIcon, "")
+	default:
+		return g.props.getString(SignatureUntrustedIcon, "\uF128")
+	}
+}
+
+// setCompareCounts uses the cached counts of the compare_branch when they were counted for the HEAD commit,
+// they're counted in the background by gitCompare
+func (g *git) setCompareCounts() {
+	compareBranch := g.props.getString(CompareBranch, "")
+	if compareBranch == "" {
+		return
+	}
+	commit := g.repo.commit
+	if commit == "" {
+		commit = g.headCommit
+	}
+	if commit == "" {
+		return
+	}
+	key := fmt.Sprintf("%s:%s:%s", gitCompareCacheKey, compareBranch, g.repo.root)
+	value, age, found := g.env.cache().get(key)
+	counts := strings.Fields(value)
+	// the commit without counts is a count in progress
+	counted := len(counts) != 0 && counts[0] == commit
+	if counted && len(counts) == 3 {
+		g.CompareAhead, g.CompareBehind = parseLeftRightCount(strings.Join(counts[1:], " "))
+	}
+	if found && counted && age < gitCompareTTL {
+		return
+	}
+	if !counted {
+		value = commit
+	}
+	// mark the count as started, the following prompts wait for it
+	g.env.cache().set(key, value)
+	_ = g.env.refreshCacheInBackground(key)
+}
+
+// gitCompare runs in the background process, it caches the HEAD commit followed by the number of commits
+// HEAD is ahead and behind the branch. The argument holds the branch and the root: upstream/main:/path/to/repo
+func gitCompare(env environmentInfo, argument string) {
+	index := strings.Index(argument, ":")
+	if index == -1 {
+		return
+	}
+	branch, root := argument[:index], argument[index+1:]
+	commit, _ := env.runCommand("git", "-C", root, "rev-parse", "HEAD")
+	output, _ := env.runCommand("git", "-C", root, "rev-list", "--left-right", "--count", fmt.Sprintf("HEAD...%s", branch))
+	ahead, behind := parseLeftRightCount(output)
+	env.cache().set(fmt.Sprintf("%s:%s", gitCompareCacheKey, argument), fmt.Sprintf("%s %d %d", commit, ahead, behind))
+}
+
+// parseLeftRightCount parses the output of git rev-list --left-right --count HEAD...branch,
+// the commits only in HEAD followed by the ones only in branch: 2	3
+func parseLeftRightCount(output string) (int, int) {
+	counts := strings.Fields(output)
+	if len(counts) != 2 {
+		return 0, 0
+	}
+	ahead, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return 0, 0
+	}
+	behind, err := strconv.Atoi(counts[1])
+	if err != nil {
+		return 0, 0
+	}
+	return ahead, behind
+}
+
+// userMismatch indicates the repository is not configured with the expected email,
+// a missing user.email is also a mismatch. Nothing is reported until the user is known
+func (g *git) userMismatch() bool {
+	expected := g.props.getString(ExpectedEmail, "")
+	if expected == "" || !g.userKnown {
+		return false
+	}
+	return !strings.EqualFold(expected, g.UserEmail)
 }
 
 func (g *git) SetStatusColor() {
@@ -250,18 +1061,16 @@ func (g *git) getGitHEADContext(ref string) string {
 	if g.hasGitFolder("rebase-merge") {
 		origin := g.getGitRefFileSymbolicName("rebase-merge/orig-head")
 		onto := g.getGitRefFileSymbolicName("rebase-merge/onto")
-		step := g.getGitFileContents("rebase-merge/msgnum")
-		total := g.getGitFileContents("rebase-merge/end")
+		g.setRebaseProgress("rebase-merge/msgnum", "rebase-merge/end")
 		icon := g.props.getString(RebaseIcon, "\uE728 ")
-		return fmt.Sprintf("%s%s%s onto %s%s (%s/%s) at %s", icon, branchIcon, origin, branchIcon, onto, step, total, ref)
+		return fmt.Sprintf("%s%s%s onto %s%s (%d/%d) at %s", icon, branchIcon, origin, branchIcon, onto, g.RebaseStep, g.RebaseTotal, ref)
 	}
 	if g.hasGitFolder("rebase-apply") {
 		head := g.getGitFileContents("rebase-apply/head-name")
 		origin := strings.Replace(head, "refs/heads/", "", 1)
-		step := g.getGitFileContents("rebase-apply/next")
-		total := g.getGitFileContents("rebase-apply/last")
+		g.setRebaseProgress("rebase-apply/next", "rebase-apply/last")
 		icon := g.props.getString(RebaseIcon, "\uE728 ")
-		return fmt.Sprintf("%s%s%s (%s/%s) at %s", icon, branchIcon, origin, step, total, ref)
+		return fmt.Sprintf("%s%s%s (%d/%d) at %s", icon, branchIcon, origin, g.RebaseStep, g.RebaseTotal, ref)
 	}
 	// merge
 	if g.hasGitFile("MERGE_HEAD") {
@@ -278,6 +1087,13 @@ func (g *git) getGitHEADContext(ref string) string {
 	return ref
 }
 
+// setRebaseProgress reads the current step and the total number of steps of the rebase in progress,
+// rebase-merge is used by interactive rebases, rebase-apply by git am and regular rebases on older versions
+func (g *git) setRebaseProgress(stepFile, totalFile string) {
+	g.RebaseStep, _ = strconv.Atoi(g.getGitFileContents(stepFile))
+	g.RebaseTotal, _ = strconv.Atoi(g.getGitFileContents(totalFile))
+}
+
 func (g *git) hasGitFile(file string) bool {
 	files := fmt.Sprintf(".git/%s", file)
 	return g.env.hasFilesInDir(g.repo.root, files)
@@ -298,19 +1114,37 @@ func (g *git) getGitRefFileSymbolicName(refFile string) string {
 	return g.getGitCommandOutput("name-rev", "--name-only", "--exclude=tags/*", ref)
 }
 
+// getPrettyHEADName names a detached HEAD after its first tag or its commit, both are read by setHEADDetails
+// unless the porcelain v2 status already knows the commit
 func (g *git) getPrettyHEADName() string {
 	// check for tag
-	ref := g.getGitCommandOutput("describe", "--tags", "--exact-match")
-	if ref != "" {
-		return fmt.Sprintf("%s%s", g.props.getString(TagIcon, "\uF412"), ref)
+	if g.props.getBool(DisplayTag, true) && len(g.headTags) != 0 {
+		return fmt.Sprintf("%s%s", g.props.getString(TagIcon, "\uF412"), g.headTags[0])
+	}
+	ref := g.headCommit
+	if g.repo != nil && g.repo.commit != "" {
+		ref = g.repo.commit
 	}
-	// fallback to commit
-	ref = g.getGitCommandOutput("rev-parse", "--short", "HEAD")
+	ref = g.getShortHash(ref)
 	return fmt.Sprintf("%s%s", g.props.getString(CommitIcon, "\uF417"), ref)
 }
 
+// getShortHash truncates the hash to commit_hash_length characters, the full hash is kept when it's shorter
+func (g *git) getShortHash(hash string) string {
+	length := int(g.props.getFloat64(CommitHashLength, 7))
+	if length <= 0 {
+		length = 7
+	}
+	if length >= len(hash) {
+		return hash
+	}
+	return hash[:length]
+}
+
 func (g *git) parseGitStats(output []string, working bool) *gitStatus {
-	status := gitStatus{}
+	status := gitStatus{
+		maxUntracked: int(g.props.getFloat64(MaxUntracked, 0)),
+	}
 	if len(output) <= 1 {
 		return &status
 	}
@@ -324,7 +1158,8 @@ func (g *git) parseGitStats(output []string, working bool) *gitStatus {
 		}
 		switch code {
 		case "?":
-			if working {
+			// stop counting once the cap is exceeded
+			if working && (status.maxUntracked <= 0 || status.untracked <= status.maxUntracked) {
 				status.untracked++
 			}
 		case "D":
@@ -341,8 +1176,142 @@ func (g *git) parseGitStats(output []string, working bool) *gitStatus {
 	return &status
 }
 
+// getBranchInfo extracts the named capture groups of BranchInfoRegex from the branch name.
+// Without a BranchInfoTemplate, the captured values are displayed in order, separated by a space.
+func (g *git) getBranchInfo(branch string) string {
+	pattern := g.props.getString(BranchInfoRegex, "")
+	if pattern == "" || branch == "" {
+		return ""
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ""
+	}
+	match := re.FindStringSubmatch(branch)
+	if len(match) == 0 {
+		return ""
+	}
+	info := make(map[string]string)
+	var values []string
+	for i, name := range re.SubexpNames() {
+		if i == 0 || name == "" {
+			continue
+		}
+		info[name] = match[i]
+		if match[i] != "" {
+			values = append(values, match[i])
+		}
+	}
+	branchInfoTemplate := g.props.getString(BranchInfoTemplate, "")
+	if branchInfoTemplate == "" {
+		return strings.Join(values, " ")
+	}
+	template := &textTemplate{
+		Template: branchInfoTemplate,
+		Context:  info,
+	}
+	return template.render()
+}
+
+// getStashContext counts the stash entries, used when the status doesn't
 func (g *git) getStashContext() string {
-	return g.getGitCommandOutput("rev-list", "--walk-reflogs", "--count", "refs/stash")
+	if count := len(g.getStashList()); count != 0 {
+		return strconv.Itoa(count)
+	}
+	return ""
+}
+
+// getStashList reads the stash entries from the reflog of refs/stash, which git stash list displays
+func (g *git) getStashList() []*gitStash {
+	return parseStashList(g.getGitFileContents("logs/refs/stash"))
+}
+
+// parseStashList parses the reflog of refs/stash, the oldest entry first. An entry holds the old and the new commit,
+// the author and the time, followed by the message after a tab:
+// 0000000 1234567 Jan <jan@example.com> 1600000000 +0200	WIP on main: 1234567 commit message
+func parseStashList(reflog string) []*gitStash {
+	var messages []string
+	for _, line := range strings.Split(reflog, "\n") {
+		index := strings.Index(line, "\t")
+		if index == -1 {
+			continue
+		}
+		messages = append(messages, strings.TrimSpace(line[index+1:]))
+	}
+	var stash []*gitStash
+	for i := len(messages) - 1; i >= 0; i-- {
+		stash = append(stash, &gitStash{
+			Index:   len(stash),
+			Message: messages[i],
+		})
+	}
+	return stash
+}
+
+// parsePorcelainV2 converts the output of git status --porcelain=2 --branch --show-stash to the short format
+// understood by parseGitStatusInfo and parseGitStats. It also returns the HEAD commit and the number of stash entries,
+// which saves the calls to fetch them. An upstream without ahead and behind counts is gone
+func parsePorcelainV2(output string) (short, commit, stashCount string) {
+	var local, upstream string
+	var ahead, behind string
+	var entries []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "#":
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				if fields[2] != "(initial)" {
+					commit = fields[2]
+				}
+			case "branch.head":
+				if fields[2] != "(detached)" {
+					local = fields[2]
+				}
+			case "branch.upstream":
+				upstream = fields[2]
+			case "branch.ab":
+				if len(fields) == 4 {
+					ahead = strings.TrimPrefix(fields[2], "+")
+					behind = strings.TrimPrefix(fields[3], "-")
+				}
+			case "stash":
+				stashCount = fields[2]
+			}
+		case "1", "2", "u":
+			// XY holds the staging and working area codes, a dot is unchanged
+			entries = append(entries, strings.ReplaceAll(fields[1], ".", " ")+" "+fields[len(fields)-1])
+		case "?":
+			entries = append(entries, "?? "+fields[len(fields)-1])
+		}
+	}
+	head := "## HEAD (no branch)"
+	if local != "" {
+		head = "## " + local
+	}
+	if local != "" && upstream != "" {
+		head += "..." + upstream
+		var counts []string
+		if ahead != "" && ahead != "0" {
+			counts = append(counts, "ahead "+ahead)
+		}
+		if behind != "" && behind != "0" {
+			counts = append(counts, "behind "+behind)
+		}
+		switch {
+		case ahead == "":
+			head += " [gone]"
+		case len(counts) != 0:
+			head += fmt.Sprintf(" [%s]", strings.Join(counts, ", "))
+		}
+	}
+	return strings.Join(append([]string{head}, entries...), "\n"), commit, stashCount
 }
 
 func (g *git) parseGitStatusInfo(branchInfo string) map[string]string {
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 const (
 	changesColor = "#BD8BDE"
+	// stashReflog is .git/logs/refs/stash holding two entries, the latest one last
+	stashReflog = "0000000000000000000000000000000000000000 2cdf7fe84f31b6c9e41e249e24572cd58b646f80 Posh <posh@example.com> 1600000000 +0200\tOn main: older\n" +
+		"2cdf7fe84f31b6c9e41e249e24572cd58b646f80 958410cc04a203c7ebe2bd532e4130e598530511 Posh <posh@example.com> 1600000060 +0200\tOn main: latest\n"
 )
 
 func TestEnabledGitNotFound(t *testing.T) {
@@ -22,11 +34,77 @@ func TestEnabledGitNotFound(t *testing.T) {
 func TestEnabledInWorkingDirectory(t *testing.T) {
 	env := new(MockedEnvironment)
 	env.On("hasCommand", "git").Return(true)
-	env.On("runCommand", "git", []string{"rev-parse", "--is-inside-work-tree"}).Return("true", nil)
+	env.On("runCommand", "git", []string{"rev-parse", "--is-inside-work-tree", "--is-bare-repository", "--show-toplevel"}).Return("true\nfalse\n/dir", nil)
 	g := &git{
 		env: env,
 	}
 	assert.True(t, g.enabled())
+	assert.False(t, g.IsBare())
+	assert.Equal(t, "/dir", g.root)
+}
+
+func TestEnabledInBareRepository(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "git").Return(true)
+	env.On("runCommand", "git", []string{"rev-parse", "--is-inside-work-tree", "--is-bare-repository", "--show-toplevel"}).Return("false\ntrue", errors.New("this operation must be run in a work tree"))
+	g := &git{
+		env: env,
+	}
+	assert.True(t, g.enabled())
+	assert.True(t, g.IsBare())
+}
+
+func TestEnabledInGitDirectory(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "git").Return(true)
+	env.On("runCommand", "git", []string{"rev-parse", "--is-inside-work-tree", "--is-bare-repository", "--show-toplevel"}).Return("false\nfalse", errors.New("this operation must be run in a work tree"))
+	g := &git{
+		env: env,
+	}
+	assert.False(t, g.enabled())
+	assert.False(t, g.IsBare())
+}
+
+func TestEnabledOutsideRepository(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "git").Return(true)
+	env.On("runCommand", "git", []string{"rev-parse", "--is-inside-work-tree", "--is-bare-repository", "--show-toplevel"}).Return("", errors.New("not a git repository"))
+	g := &git{
+		env: env,
+	}
+	assert.False(t, g.enabled())
+	assert.False(t, g.IsBare())
+	env.AssertNumberOfCalls(t, "runCommand", 1)
+}
+
+func TestGetBareString(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Expected string
+		Branch   string
+		Commit   string
+	}{
+		{Case: "Branch", Expected: "bare main", Branch: "main"},
+		{Case: "Detached", Expected: "bare #1234567", Commit: "1234567890abcdef"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.mockGitCommand(tc.Branch, "symbolic-ref", "--short", "HEAD")
+		env.mockGitCommand(tc.Commit+"\n", "log", "-1", "--decorate=short", "--format=%H%n%D")
+		g := &git{
+			env:    env,
+			isBare: true,
+			props: &properties{
+				values: map[Property]interface{}{
+					BareIcon:   "bare ",
+					BranchIcon: "",
+					CommitIcon: "#",
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, g.string(), tc.Case)
+		env.AssertNotCalled(t, "runCommand", "git", []string{"-c", "core.quotepath=false", "-c", "color.status=false", "status", "-unormal", "--short", "--branch"})
+	}
 }
 
 func TestGetGitOutputForCommand(t *testing.T) {
@@ -74,8 +152,11 @@ func setupHEADContextEnv(context *detachedContext) *git {
 	env.On("getFileContent", "/.git/MERGE_HEAD").Return(context.mergeHEAD)
 	env.On("hasFilesInDir", "", ".git/CHERRY_PICK_HEAD").Return(context.cherryPick)
 	env.On("hasFilesInDir", "", ".git/MERGE_HEAD").Return(context.merge)
-	env.mockGitCommand(context.currentCommit, "rev-parse", "--short", "HEAD")
-	env.mockGitCommand(context.tagName, "describe", "--tags", "--exact-match")
+	var refs string
+	if context.tagName != "" {
+		refs = "tag: " + context.tagName
+	}
+	env.mockGitCommand(context.currentCommit+"\n"+refs, "log", "-1", "--decorate=short", "--format=%H%n%D")
 	env.mockGitCommand(context.origin, "name-rev", "--name-only", "--exclude=tags/*", context.origin)
 	env.mockGitCommand(context.onto, "name-rev", "--name-only", "--exclude=tags/*", context.onto)
 	env.mockGitCommand(context.cherryPickSHA, "name-rev", "--name-only", "--exclude=tags/*", context.cherryPickSHA)
@@ -95,11 +176,12 @@ func (m *MockedEnvironment) mockGitCommand(returnValue string, args ...string) {
 }
 
 func TestGetGitDetachedCommitHash(t *testing.T) {
-	want := "\uf417lalasha1"
+	want := "\uf417lalasha"
 	context := &detachedContext{
-		currentCommit: "lalasha1",
+		currentCommit: "lalasha1234",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
@@ -107,18 +189,19 @@ func TestGetGitDetachedCommitHash(t *testing.T) {
 func TestGetGitHEADContextTagName(t *testing.T) {
 	want := "\uf412lalasha1"
 	context := &detachedContext{
-		currentCommit: "whatever",
+		currentCommit: "whatever123",
 		tagName:       "lalasha1",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
 
 func TestGetGitHEADContextRebaseMerge(t *testing.T) {
-	want := "\ue728 \ue0a0cool-feature-bro onto \ue0a0main (2/3) at \uf417whatever"
+	want := "\ue728 \ue0a0cool-feature-bro onto \ue0a0main (2/3) at \uf417whateve"
 	context := &detachedContext{
-		currentCommit: "whatever",
+		currentCommit: "whatever123",
 		rebase:        "true",
 		rebaseMerge:   true,
 		origin:        "cool-feature-bro",
@@ -127,14 +210,15 @@ func TestGetGitHEADContextRebaseMerge(t *testing.T) {
 		total:         "3",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
 
 func TestGetGitHEADContextRebaseApply(t *testing.T) {
-	want := "\ue728 \ue0a0cool-feature-bro (2/3) at \uf417whatever"
+	want := "\ue728 \ue0a0cool-feature-bro (2/3) at \uf417whateve"
 	context := &detachedContext{
-		currentCommit: "whatever",
+		currentCommit: "whatever123",
 		rebase:        "true",
 		rebaseApply:   true,
 		origin:        "cool-feature-bro",
@@ -142,17 +226,19 @@ func TestGetGitHEADContextRebaseApply(t *testing.T) {
 		total:         "3",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
 
 func TestGetGitHEADContextRebaseUnknown(t *testing.T) {
-	want := "\uf417whatever"
+	want := "\uf417whateve"
 	context := &detachedContext{
-		currentCommit: "whatever",
+		currentCommit: "whatever123",
 		rebase:        "true",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
@@ -160,7 +246,7 @@ func TestGetGitHEADContextRebaseUnknown(t *testing.T) {
 func TestGetGitHEADContextCherryPickOnBranch(t *testing.T) {
 	want := "\ue29b pickme onto \ue0a0main"
 	context := &detachedContext{
-		currentCommit: "whatever",
+		currentCommit: "whatever123",
 		branchName:    "main",
 		cherryPick:    true,
 		cherryPickSHA: "pickme",
@@ -173,12 +259,13 @@ func TestGetGitHEADContextCherryPickOnBranch(t *testing.T) {
 func TestGetGitHEADContextCherryPickOnTag(t *testing.T) {
 	want := "\ue29b pickme onto \uf412v3.4.6"
 	context := &detachedContext{
-		currentCommit: "whatever",
+		currentCommit: "whatever123",
 		tagName:       "v3.4.6",
 		cherryPick:    true,
 		cherryPickSHA: "pickme",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
@@ -202,6 +289,7 @@ func TestGetGitHEADContextMergeTag(t *testing.T) {
 		mergeHEAD: "feat",
 	}
 	g := setupHEADContextEnv(context)
+	g.setHEADDetails(true)
 	got := g.getGitHEADContext("")
 	assert.Equal(t, want, got)
 }
@@ -209,9 +297,10 @@ func TestGetGitHEADContextMergeTag(t *testing.T) {
 func TestGetStashContextZeroEntries(t *testing.T) {
 	want := ""
 	env := new(MockedEnvironment)
-	env.On("runCommand", "git", []string{"-c", "core.quotepath=false", "-c", "color.status=false", "rev-list", "--walk-reflogs", "--count", "refs/stash"}).Return("", nil)
+	env.On("getFileContent", "/dir/.git/logs/refs/stash").Return("")
 	g := &git{
-		env: env,
+		env:  env,
+		repo: &gitRepo{root: "/dir"},
 	}
 	got := g.getStashContext()
 	assert.Equal(t, want, got)
@@ -220,9 +309,10 @@ func TestGetStashContextZeroEntries(t *testing.T) {
 func TestGetStashContextMultipleEntries(t *testing.T) {
 	want := "2"
 	env := new(MockedEnvironment)
-	env.On("runCommand", "git", []string{"-c", "core.quotepath=false", "-c", "color.status=false", "rev-list", "--walk-reflogs", "--count", "refs/stash"}).Return("2", nil)
+	env.On("getFileContent", "/dir/.git/logs/refs/stash").Return(stashReflog)
 	g := &git{
-		env: env,
+		env:  env,
+		repo: &gitRepo{root: "/dir"},
 	}
 	got := g.getStashContext()
 	assert.Equal(t, want, got)
@@ -376,7 +466,7 @@ func TestParseGitStatsInvalidLine(t *testing.T) {
 
 func bootstrapUpstreamTest(upstream string) *git {
 	env := &MockedEnvironment{}
-	env.On("runCommand", "git", []string{"-c", "core.quotepath=false", "-c", "color.status=false", "remote", "get-url", "origin"}).Return(upstream, nil)
+	env.On("getFileContent", "/.git/config").Return(fmt.Sprintf("[core]\n\tbare = false\n[remote \"fork\"]\n\turl = https://example.com/fork\n[remote \"origin\"]\n\turl = %s\n", upstream))
 	props := &properties{
 		values: map[Property]interface{}{
 			GithubIcon:    "GH",
@@ -419,6 +509,23 @@ func TestGetUpstreamSymbolGit(t *testing.T) {
 	assert.Equal(t, "G", upstreamIcon)
 }
 
+func TestParseRemoteURL(t *testing.T) {
+	config := "[core]\n\turl = https://example.com/core\n[remote \"origin\"]\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n\tURL = git@github.com:jandedobbeleer/oh-my-posh3.git\n" +
+		"[remote \"upstream\"]\r\n\turl=https://gitlab.com/upstream\r\n"
+	cases := []struct {
+		Case     string
+		Remote   string
+		Expected string
+	}{
+		{Case: "Origin", Remote: "origin", Expected: "git@github.com:jandedobbeleer/oh-my-posh3.git"},
+		{Case: "Without spaces", Remote: "upstream", Expected: "https://gitlab.com/upstream"},
+		{Case: "Unknown remote", Remote: "fork"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, parseRemoteURL(config, tc.Remote), tc.Case)
+	}
+}
+
 func TestGetStatusColorLocalChangesStaging(t *testing.T) {
 	expected := changesColor
 	repo := &gitRepo{
@@ -577,7 +684,20 @@ func TestSetStatusColorForeground(t *testing.T) {
 }
 
 func TestGetStatusDetailStringDefault(t *testing.T) {
-	expected := "<#111111>icon +1</>"
+	expected := "<red>icon +1</>"
+	status := &gitStatus{
+		changed: true,
+		added:   1,
+	}
+	g := &git{
+		props: &properties{
+			foreground: "#111111",
+		},
+	}
+	assert.Equal(t, expected, g.getStatusDetailString(status, UnstagedForeground, WorkingColor, LocalWorkingIcon, "icon", defaultUnstagedForeground))
+}
+
+func TestGetStatusDetailStringDefaultStaged(t *testing.T) {
 	status := &gitStatus{
 		changed: true,
 		added:   1,
@@ -587,11 +707,11 @@ func TestGetStatusDetailStringDefault(t *testing.T) {
 			foreground: "#111111",
 		},
 	}
-	assert.Equal(t, expected, g.getStatusDetailString(status, WorkingColor, LocalWorkingIcon, "icon"))
+	assert.Equal(t, "<green>icon +1</>", g.getStatusDetailString(status, StagedForeground, StagingColor, LocalStagingIcon, "icon", defaultStagedForeground))
 }
 
 func TestGetStatusDetailStringNoStatus(t *testing.T) {
-	expected := "<#111111>icon</>"
+	expected := "<red>icon</>"
 	status := &gitStatus{
 		changed: true,
 		added:   1,
@@ -604,7 +724,7 @@ func TestGetStatusDetailStringNoStatus(t *testing.T) {
 			foreground: "#111111",
 		},
 	}
-	assert.Equal(t, expected, g.getStatusDetailString(status, WorkingColor, LocalWorkingIcon, "icon"))
+	assert.Equal(t, expected, g.getStatusDetailString(status, UnstagedForeground, WorkingColor, LocalWorkingIcon, "icon", defaultUnstagedForeground))
 }
 
 func TestGetStatusDetailStringNoStatusColorOverride(t *testing.T) {
@@ -622,5 +742,1301 @@ func TestGetStatusDetailStringNoStatusColorOverride(t *testing.T) {
 			foreground: "#111111",
 		},
 	}
-	assert.Equal(t, expected, g.getStatusDetailString(status, WorkingColor, LocalWorkingIcon, "icon"))
+	assert.Equal(t, expected, g.getStatusDetailString(status, UnstagedForeground, WorkingColor, LocalWorkingIcon, "icon", defaultUnstagedForeground))
+}
+
+func TestGetBranchInfo(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Expected string
+		Branch   string
+		Regex    string
+		Template string
+	}{
+		{Case: "PR number", Expected: "#123", Branch: "pr/123", Regex: `^pr/(?P<PRNumber>\d+)$`, Template: "#{{.PRNumber}}"},
+		{Case: "Issue key", Expected: "PROJ-123", Branch: "feature/PROJ-123-cool-stuff", Regex: `(?P<IssueKey>[A-Z]+-\d+)`, Template: "{{.IssueKey}}"},
+		{Case: "Multiple groups", Expected: "feature PROJ-123", Branch: "feature/PROJ-123", Regex: `^(?P<Type>\w+)/(?P<IssueKey>[A-Z]+-\d+)`, Template: "{{.Type}} {{.IssueKey}}"},
+		{Case: "No template", Expected: "feature PROJ-123", Branch: "feature/PROJ-123", Regex: `^(?P<Type>\w+)/(?P<IssueKey>[A-Z]+-\d+)`},
+		{Case: "Optional group", Expected: "PR ", Branch: "main", Regex: `^(pr/(?P<PRNumber>\d+))?`, Template: "PR {{.PRNumber}}"},
+		{Case: "No match", Expected: "", Branch: "main", Regex: `^pr/(?P<PRNumber>\d+)$`, Template: "#{{.PRNumber}}"},
+		{Case: "Invalid regex", Expected: "", Branch: "pr/123", Regex: `^pr/(?P<PRNumber>\d+$`, Template: "#{{.PRNumber}}"},
+		{Case: "No regex", Expected: "", Branch: "pr/123", Template: "#{{.PRNumber}}"},
+		{Case: "Detached HEAD", Expected: "", Branch: "", Regex: `(?P<PRNumber>\d*)`, Template: "#{{.PRNumber}}"},
+	}
+	for _, tc := range cases {
+		g := &git{
+			props: &properties{
+				values: map[Property]interface{}{
+					BranchInfoRegex:    tc.Regex,
+					BranchInfoTemplate: tc.Template,
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, g.getBranchInfo(tc.Branch), tc.Case)
+	}
+}
+
+func TestGetGitHEADContextDisplayTag(t *testing.T) {
+	cases := []struct {
+		Case       string
+		Expected   string
+		DisplayTag bool
+		Branch     string
+		Tag        string
+	}{
+		{Case: "Tagged detached", Expected: "\uF412v3.4.6", DisplayTag: true, Tag: "v3.4.6"},
+		{Case: "Tagged detached disabled", Expected: "\uF417whateve", DisplayTag: false, Tag: "v3.4.6"},
+		{Case: "Untagged detached", Expected: "\uF417whateve", DisplayTag: true},
+		{Case: "Branch", Expected: "\uE0A0main", DisplayTag: true, Branch: "main", Tag: "v3.4.6"},
+	}
+	for _, tc := range cases {
+		context := &detachedContext{
+			currentCommit: "whatever123",
+			tagName:       tc.Tag,
+		}
+		g := setupHEADContextEnv(context)
+		g.props = &properties{
+			values: map[Property]interface{}{
+				DisplayTag: tc.DisplayTag,
+			},
+		}
+		g.setHEADDetails(tc.Branch == "")
+		assert.Equal(t, tc.Expected, g.getGitHEADContext(tc.Branch), tc.Case)
+	}
+}
+
+func TestGetStatusDetailStringStagedAndUnstagedForeground(t *testing.T) {
+	staging := &gitStatus{
+		changed: true,
+		added:   1,
+	}
+	working := &gitStatus{
+		changed:  true,
+		modified: 2,
+	}
+	g := &git{
+		props: &properties{
+			values: map[Property]interface{}{
+				StagedForeground:   "green",
+				UnstagedForeground: "red",
+				StagingColor:       "#123456",
+			},
+			foreground: "#111111",
+		},
+	}
+	staged := g.getStatusDetailString(staging, StagedForeground, StagingColor, LocalStagingIcon, "S", defaultStagedForeground)
+	unstaged := g.getStatusDetailString(working, UnstagedForeground, WorkingColor, LocalWorkingIcon, "W", defaultUnstagedForeground)
+	assert.Equal(t, "<green>S +1</>", staged)
+	assert.Equal(t, "<red>W ~2</>", unstaged)
+	renderer := &AnsiColor{
+		buffer: new(bytes.Buffer),
+	}
+	renderer.init("shell")
+	renderer.write("", "#111111", staged+" |"+unstaged)
+	rendered := renderer.string()
+	green := renderer.getAnsiFromColorString("green", false)
+	red := renderer.getAnsiFromColorString("red", false)
+	assert.NotEqual(t, green, red)
+	assert.Contains(t, rendered, fmt.Sprintf(renderer.formats.single, green, "S +1"))
+	assert.Contains(t, rendered, fmt.Sprintf(renderer.formats.single, red, "W ~2"))
+}
+
+func TestGitUser(t *testing.T) {
+	key := "git_user:/dev/repo"
+	cases := []struct {
+		Case            string
+		Cached          string
+		Age             time.Duration
+		ExpectedEmail   string
+		FetchUser       bool
+		ExpectedName    string
+		ExpectedUser    string
+		Mismatch        bool
+		ExpectedRefresh bool
+	}{
+		{Case: "Matching identity", Cached: "Posh\nposh@example.com", ExpectedEmail: "posh@example.com", ExpectedName: "Posh", ExpectedUser: "posh@example.com"},
+		{
+			Case:          "Matching identity case insensitive",
+			Cached:        "Posh\nPosh@Example.com",
+			ExpectedEmail: "posh@example.com",
+			ExpectedName:  "Posh",
+			ExpectedUser:  "Posh@Example.com",
+		},
+		{
+			Case:          "Mismatching identity",
+			Cached:        "Posh\nposh@work.com",
+			ExpectedEmail: "posh@example.com",
+			ExpectedName:  "Posh",
+			ExpectedUser:  "posh@work.com",
+			Mismatch:      true,
+		},
+		{Case: "Missing config", Cached: "\n", ExpectedEmail: "posh@example.com", Mismatch: true},
+		{Case: "Fetch without expectation", Cached: "Posh\nposh@work.com", FetchUser: true, ExpectedName: "Posh", ExpectedUser: "posh@work.com"},
+		{Case: "Unknown user", ExpectedEmail: "posh@example.com", ExpectedRefresh: true},
+		{Case: "Lookup started", Cached: "", ExpectedEmail: "posh@example.com"},
+		{
+			Case:            "Expired",
+			Cached:          "Posh\nposh@work.com",
+			Age:             2 * time.Minute,
+			ExpectedEmail:   "posh@example.com",
+			ExpectedName:    "Posh",
+			ExpectedUser:    "posh@work.com",
+			Mismatch:        true,
+			ExpectedRefresh: true,
+		},
+	}
+	for _, tc := range cases {
+		now := time.Now()
+		fc, cleanup := newTestFileCache(t, now.Add(-tc.Age))
+		if tc.Cached != "" || !tc.ExpectedRefresh {
+			fc.set(key, tc.Cached)
+		}
+		fc.now = func() time.Time { return now }
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("refreshCacheInBackground", key).Return(nil)
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo"},
+			props: &properties{
+				values: map[Property]interface{}{
+					ExpectedEmail: tc.ExpectedEmail,
+					FetchUser:     tc.FetchUser,
+				},
+			},
+		}
+		g.setUser()
+		assert.Equal(t, tc.ExpectedName, g.UserName, tc.Case)
+		assert.Equal(t, tc.ExpectedUser, g.UserEmail, tc.Case)
+		assert.Equal(t, tc.Mismatch, g.userMismatch(), tc.Case)
+		if tc.ExpectedRefresh {
+			env.AssertCalled(t, "refreshCacheInBackground", key)
+			// the following prompts keep the known user while the lookup runs
+			cached, age, _ := fc.get(key)
+			assert.Equal(t, tc.Cached, cached, tc.Case)
+			assert.Equal(t, time.Duration(0), age, tc.Case)
+		} else {
+			env.AssertNotCalled(t, "refreshCacheInBackground", key)
+		}
+		env.AssertNotCalled(t, "runCommand", "git", mock.Anything)
+		cleanup()
+	}
+}
+
+func TestGitUserNotFetched(t *testing.T) {
+	env := new(MockedEnvironment)
+	g := &git{
+		env:   env,
+		props: &properties{},
+	}
+	g.setUser()
+	assert.Empty(t, g.UserEmail)
+	assert.False(t, g.userMismatch())
+	env.AssertNotCalled(t, "cache", nil)
+	env.AssertNotCalled(t, "runCommand", "git", mock.Anything)
+}
+
+func TestGitUserLookup(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Output   string
+		Expected string
+	}{
+		{Case: "Configured", Output: "user.name Jan De Dobbeleer\nuser.email jan@example.com", Expected: "Jan De Dobbeleer\njan@example.com"},
+		{Case: "Last value wins", Output: "user.email jan@example.com\nuser.email jan@work.com", Expected: "\njan@work.com"},
+		{Case: "Not configured", Expected: "\n"},
+	}
+	for _, tc := range cases {
+		fc, cleanup := newTestFileCache(t, time.Now())
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("runCommand", "git", []string{"-C", "/dev/repo", "config", "--get-regexp", `^user\.(name|email)$`}).Return(tc.Output, nil)
+		refreshCache(env, "git_user:/dev/repo")
+		cached, _, found := fc.get("git_user:/dev/repo")
+		assert.True(t, found, tc.Case)
+		assert.Equal(t, tc.Expected, cached, tc.Case)
+		cleanup()
+	}
+}
+
+func TestParseGitStatsMaxUntracked(t *testing.T) {
+	cases := []struct {
+		Case      string
+		Untracked int
+		Max       float64
+		Expected  string
+		Status    string
+	}{
+		{Case: "No cap", Untracked: 150, Expected: "150", Status: " ?150"},
+		{Case: "Under cap", Untracked: 3, Max: 99, Expected: "3", Status: " ?3"},
+		{Case: "At cap", Untracked: 99, Max: 99, Expected: "99", Status: " ?99"},
+		{Case: "Over cap", Untracked: 150, Max: 99, Expected: "99+", Status: " ?99+"},
+		{Case: "None", Max: 99, Expected: ""},
+	}
+	for _, tc := range cases {
+		output := []string{"## amazing-feat"}
+		for i := 0; i < tc.Untracked; i++ {
+			output = append(output, fmt.Sprintf("?? file%d.go", i))
+		}
+		g := &git{
+			props: &properties{
+				values: map[Property]interface{}{
+					MaxUntracked: tc.Max,
+				},
+			},
+		}
+		g.repo = &gitRepo{
+			working: g.parseGitStats(output, true),
+		}
+		assert.Equal(t, tc.Expected, g.Untracked(), tc.Case)
+		if tc.Status == "" {
+			assert.Empty(t, g.repo.working.string("", "#123456"), tc.Case)
+			continue
+		}
+		assert.Equal(t, "<#123456>"+tc.Status+"</>", g.repo.working.string("", "#123456"), tc.Case)
+	}
+}
+
+func TestParseStashList(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Reflog   string
+		Expected []*gitStash
+	}{
+		{Case: "Empty", Reflog: ""},
+		{Case: "Single entry", Reflog: "0000000 1234567 Posh <posh@example.com> 1600000000 +0200\tWIP on main: 1234567 add feature\n", Expected: []*gitStash{
+			{Index: 0, Message: "WIP on main: 1234567 add feature"},
+		}},
+		{
+			Case: "Multiple entries",
+			Reflog: "0000000 1234567 Posh <posh@example.com> 1600000000 +0200\tOn main: \n" +
+				"1234567 2345678 Posh <posh@example.com> 1600000060 +0200\tWIP on feat: 7654321 fix: the thing\r\n" +
+				"2345678 3456789 Posh <posh@example.com> 1600000120 +0200\tOn main: try this",
+			Expected: []*gitStash{
+				{Index: 0, Message: "On main: try this"},
+				{Index: 1, Message: "WIP on feat: 7654321 fix: the thing"},
+				{Index: 2, Message: "On main:"},
+			},
+		},
+		{Case: "Invalid line", Reflog: "fatal: not a reflog", Expected: nil},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, parseStashList(tc.Reflog), tc.Case)
+	}
+}
+
+func TestGetStashList(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getFileContent", "/dir/.git/logs/refs/stash").Return(stashReflog)
+	g := &git{
+		env:  env,
+		repo: &gitRepo{root: "/dir"},
+	}
+	stash := g.getStashList()
+	assert.Len(t, stash, 2)
+	assert.Equal(t, &gitStash{Index: 0, Message: "On main: latest"}, stash[0])
+	env.AssertNotCalled(t, "runCommand", "git", mock.Anything)
+}
+
+func TestGetBranchStatus(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Ahead    int
+		Behind   int
+		Upstream string
+		Icons    map[Property]interface{}
+		Expected string
+	}{
+		{Case: "Synced", Upstream: "origin/main", Expected: " \u2261"},
+		{Case: "Synced custom icon", Upstream: "origin/main", Icons: map[Property]interface{}{SyncedIcon: "="}, Expected: " ="},
+		{Case: "Synced identical icon", Upstream: "origin/main", Icons: map[Property]interface{}{BranchIdenticalIcon: "i"}, Expected: " i"},
+		{Case: "Ahead", Ahead: 2, Upstream: "origin/main", Icons: map[Property]interface{}{BranchAheadIcon: "\u21E1"}, Expected: " \u21E12"},
+		{Case: "Behind", Behind: 1, Upstream: "origin/main", Icons: map[Property]interface{}{BranchBehindIcon: "\u21E3"}, Expected: " \u21E31"},
+		{
+			Case:     "Diverged",
+			Ahead:    2,
+			Behind:   1,
+			Upstream: "origin/main",
+			Icons:    map[Property]interface{}{BranchAheadIcon: "\u21E1", BranchBehindIcon: "\u21E3"},
+			Expected: " \u21E12 \u21E31",
+		},
+		{
+			Case:     "Diverged custom icon",
+			Ahead:    2,
+			Behind:   1,
+			Upstream: "origin/main",
+			Icons:    map[Property]interface{}{BranchAheadIcon: "\u21E1", BranchBehindIcon: "\u21E3", DivergedIcon: "\u26A1"},
+			Expected: " \u26A1",
+		},
+		{Case: "Ahead with diverged icon", Ahead: 2, Upstream: "origin/main", Icons: map[Property]interface{}{DivergedIcon: "\u26A1"}, Expected: " \u21912"},
+		{Case: "Gone", Icons: map[Property]interface{}{SyncedIcon: "="}, Expected: " \u2262"},
+	}
+	for _, tc := range cases {
+		g := &git{
+			repo: &gitRepo{
+				ahead:    tc.Ahead,
+				behind:   tc.Behind,
+				upstream: tc.Upstream,
+			},
+			props: &properties{
+				values: tc.Icons,
+			},
+		}
+		assert.Equal(t, tc.Expected, g.getBranchStatus(), tc.Case)
+	}
+}
+
+func TestParseLeftRightCount(t *testing.T) {
+	cases := []struct {
+		Case           string
+		Output         string
+		ExpectedAhead  int
+		ExpectedBehind int
+	}{
+		{Case: "Ahead and behind", Output: "2\t3", ExpectedAhead: 2, ExpectedBehind: 3},
+		{Case: "In sync", Output: "0\t0"},
+		{Case: "Only behind", Output: "0\t15\n", ExpectedBehind: 15},
+		{Case: "Unknown branch", Output: "fatal: ambiguous argument 'upstream/main': unknown revision"},
+		{Case: "Empty", Output: ""},
+	}
+	for _, tc := range cases {
+		ahead, behind := parseLeftRightCount(tc.Output)
+		assert.Equal(t, tc.ExpectedAhead, ahead, tc.Case)
+		assert.Equal(t, tc.ExpectedBehind, behind, tc.Case)
+	}
+}
+
+func TestSetCompareCounts(t *testing.T) {
+	key := "git_compare:upstream/main:/dev/repo"
+	cases := []struct {
+		Case            string
+		Cached          string
+		Age             time.Duration
+		ExpectedAhead   int
+		ExpectedBehind  int
+		ExpectedRefresh bool
+		ExpectedCached  string
+	}{
+		{Case: "Counted", Cached: "1234567890abcdef 4 1", ExpectedAhead: 4, ExpectedBehind: 1},
+		{Case: "Never counted", ExpectedRefresh: true, ExpectedCached: "1234567890abcdef"},
+		{Case: "Count started", Cached: "1234567890abcdef"},
+		{Case: "Other commit", Cached: "fedcba0987654321 4 1", ExpectedRefresh: true, ExpectedCached: "1234567890abcdef"},
+		{
+			Case:            "Expired",
+			Cached:          "1234567890abcdef 4 1",
+			Age:             2 * time.Minute,
+			ExpectedAhead:   4,
+			ExpectedBehind:  1,
+			ExpectedRefresh: true,
+			ExpectedCached:  "1234567890abcdef 4 1",
+		},
+	}
+	for _, tc := range cases {
+		now := time.Now()
+		fc, cleanup := newTestFileCache(t, now.Add(-tc.Age))
+		if tc.Cached != "" {
+			fc.set(key, tc.Cached)
+		}
+		fc.now = func() time.Time { return now }
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("refreshCacheInBackground", key).Return(nil)
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo", commit: "1234567890abcdef"},
+			props: &properties{
+				values: map[Property]interface{}{
+					CompareBranch: "upstream/main",
+				},
+			},
+		}
+		g.setCompareCounts()
+		assert.Equal(t, tc.ExpectedAhead, g.CompareAhead, tc.Case)
+		assert.Equal(t, tc.ExpectedBehind, g.CompareBehind, tc.Case)
+		if tc.ExpectedRefresh {
+			env.AssertCalled(t, "refreshCacheInBackground", key)
+			cached, age, _ := fc.get(key)
+			assert.Equal(t, tc.ExpectedCached, cached, tc.Case)
+			assert.Equal(t, time.Duration(0), age, tc.Case)
+		} else {
+			env.AssertNotCalled(t, "refreshCacheInBackground", key)
+		}
+		env.AssertNotCalled(t, "runCommand", "git", mock.Anything)
+		cleanup()
+	}
+}
+
+func TestGitCompare(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	env := new(MockedEnvironment)
+	env.On("cache", nil).Return(fc)
+	env.On("runCommand", "git", []string{"-C", "C:/dev/repo", "rev-parse", "HEAD"}).Return("1234567890abcdef", nil)
+	env.On("runCommand", "git", []string{"-C", "C:/dev/repo", "rev-list", "--left-right", "--count", "HEAD...upstream/main"}).Return("4\t1", nil)
+	refreshCache(env, "git_compare:upstream/main:C:/dev/repo")
+	cached, _, found := fc.get("git_compare:upstream/main:C:/dev/repo")
+	assert.True(t, found)
+	assert.Equal(t, "1234567890abcdef 4 1", cached)
+}
+
+func TestParseSignatureStatus(t *testing.T) {
+	cases := []struct {
+		Code     string
+		Expected string
+	}{
+		{Code: "G", Expected: signatureGood},
+		{Code: "B", Expected: signatureBad},
+		{Code: "U", Expected: signatureUnknown},
+		{Code: "X", Expected: signatureExpired},
+		{Code: "Y", Expected: signatureExpiredKey},
+		{Code: "R", Expected: signatureRevokedKey},
+		{Code: "E", Expected: signatureMissingKey},
+		{Code: "N", Expected: signatureNone},
+		{Code: "G\n", Expected: signatureGood},
+		{Code: "", Expected: signatureNone},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, parseSignatureStatus(tc.Code), tc.Code)
+	}
+}
+
+func TestSetSignature(t *testing.T) {
+	cases := []struct {
+		Case           string
+		Code           string
+		Disabled       bool
+		ExpectedSigned bool
+		ExpectedIcon   string
+	}{
+		{Case: "Good", Code: "G", ExpectedSigned: true, ExpectedIcon: "good"},
+		{Case: "Bad", Code: "B", ExpectedSigned: true, ExpectedIcon: "bad"},
+		{Case: "Expired key", Code: "Y", ExpectedSigned: true, ExpectedIcon: "untrusted"},
+		{Case: "Not signed", Code: "N", ExpectedIcon: "none"},
+		{Case: "Disabled", Code: "G", Disabled: true},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.mockGitCommand("1234567890abcdef\nHEAD -> main\n"+tc.Code, "log", "-1", "--decorate=short", "--format=%H%n%D%n%G?")
+		g := &git{
+			env:  env,
+			repo: &gitRepo{local: "main", commit: "1234567890abcdef"},
+			props: &properties{
+				values: map[Property]interface{}{
+					FetchSignature:         !tc.Disabled,
+					SignatureGoodIcon:      "good",
+					SignatureBadIcon:       "bad",
+					SignatureUntrustedIcon: "untrusted",
+					SignatureNoneIcon:      "none",
+				},
+			},
+		}
+		g.setHEADDetails(false)
+		assert.Equal(t, tc.ExpectedSigned, g.Signed, tc.Case)
+		assert.Equal(t, tc.ExpectedIcon, g.getSignatureIcon(), tc.Case)
+		expectedCalls := 1
+		if tc.Disabled {
+			expectedCalls = 0
+		}
+		env.AssertNumberOfCalls(t, "runCommand", expectedCalls)
+	}
+}
+
+func TestSetRebaseProgress(t *testing.T) {
+	cases := []struct {
+		Case          string
+		Folder        string
+		Files         map[string]string
+		StepFile      string
+		TotalFile     string
+		ExpectedStep  int
+		ExpectedTotal int
+	}{
+		{
+			Case:          "Rebase merge",
+			Folder:        "rebase-merge",
+			Files:         map[string]string{"msgnum": "2\n", "end": "5\n"},
+			StepFile:      "rebase-merge/msgnum",
+			TotalFile:     "rebase-merge/end",
+			ExpectedStep:  2,
+			ExpectedTotal: 5,
+		},
+		{
+			Case:          "Rebase apply",
+			Folder:        "rebase-apply",
+			Files:         map[string]string{"next": "1\n", "last": "3\n"},
+			StepFile:      "rebase-apply/next",
+			TotalFile:     "rebase-apply/last",
+			ExpectedStep:  1,
+			ExpectedTotal: 3,
+		},
+		{
+			Case:      "Missing files",
+			Folder:    "rebase-merge",
+			StepFile:  "rebase-merge/msgnum",
+			TotalFile: "rebase-merge/end",
+		},
+	}
+	for _, tc := range cases {
+		root, err := ioutil.TempDir("", "omp-git")
+		assert.NoError(t, err)
+		folder := filepath.Join(root, ".git", tc.Folder)
+		assert.NoError(t, os.MkdirAll(folder, 0755))
+		for name, content := range tc.Files {
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(folder, name), []byte(content), 0644))
+		}
+		g := &git{
+			env: &environment{},
+			repo: &gitRepo{
+				root: root,
+			},
+		}
+		assert.True(t, g.hasGitFolder(tc.Folder), tc.Case)
+		g.setRebaseProgress(tc.StepFile, tc.TotalFile)
+		assert.Equal(t, tc.ExpectedStep, g.RebaseStep, tc.Case)
+		assert.Equal(t, tc.ExpectedTotal, g.RebaseTotal, tc.Case)
+		os.RemoveAll(root)
+	}
+}
+
+func TestGetShortHash(t *testing.T) {
+	hash := "cf3b5a1d4e6ba8f9e15e1b3c2d8a4f7e6b9c0d12"
+	cases := []struct {
+		Case     string
+		Length   interface{}
+		Expected string
+	}{
+		{Case: "Default", Expected: "cf3b5a1"},
+		{Case: "Custom", Length: float64(4), Expected: "cf3b"},
+		{Case: "Beyond full hash", Length: float64(50), Expected: hash},
+		{Case: "Invalid", Length: float64(0), Expected: "cf3b5a1"},
+	}
+	for _, tc := range cases {
+		props := &properties{
+			values: map[Property]interface{}{},
+		}
+		if tc.Length != nil {
+			props.values[CommitHashLength] = tc.Length
+		}
+		g := &git{
+			props: props,
+		}
+		assert.Equal(t, tc.Expected, g.getShortHash(hash), tc.Case)
+	}
+}
+
+func TestSetBaseBranch(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Ref      string
+		Display  bool
+		Expected string
+	}{
+		{Case: "Resolved", Ref: "ref: refs/remotes/origin/main", Display: true, Expected: "main"},
+		{Case: "Nested", Ref: "ref: refs/remotes/origin/release/2.0", Display: true, Expected: "release/2.0"},
+		{Case: "Unresolved", Display: true},
+		{Case: "Not symbolic", Ref: "1234567890abcdef", Display: true},
+		{Case: "Disabled", Ref: "ref: refs/remotes/origin/main"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getFileContent", "/dev/repo/.git/refs/remotes/origin/HEAD").Return(tc.Ref + "\n")
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo"},
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayBaseBranch: tc.Display,
+				},
+			},
+		}
+		g.setBaseBranch()
+		assert.Equal(t, tc.Expected, g.BaseBranch, tc.Case)
+		env.AssertNotCalled(t, "runCommand", "git", mock.Anything)
+	}
+}
+
+func TestGetBaseBranchString(t *testing.T) {
+	cases := []struct {
+		Case       string
+		BaseBranch string
+		Local      string
+		Icon       interface{}
+		Expected   string
+	}{
+		{Case: "Feature branch", BaseBranch: "main", Local: "feature/x", Expected: " \u2190 main"},
+		{Case: "Custom icon", BaseBranch: "main", Local: "feature/x", Icon: " from ", Expected: " from main"},
+		{Case: "On the base branch", BaseBranch: "main", Local: "main"},
+		{Case: "Unresolved", Local: "feature/x"},
+	}
+	for _, tc := range cases {
+		props := &properties{
+			values: map[Property]interface{}{
+				DisplayBaseBranch: true,
+			},
+		}
+		if tc.Icon != nil {
+			props.values[BaseBranchIcon] = tc.Icon
+		}
+		g := &git{
+			props:      props,
+			repo:       &gitRepo{local: tc.Local},
+			BaseBranch: tc.BaseBranch,
+		}
+		assert.Equal(t, tc.Expected, g.getBaseBranchString(), tc.Case)
+	}
+}
+
+func TestGetStatusBar(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Staging  *gitStatus
+		Working  *gitStatus
+		Colors   bool
+		Expected string
+	}{
+		{Case: "Clean", Staging: &gitStatus{}, Working: &gitStatus{}},
+		{
+			Case:     "All categories",
+			Staging:  &gitStatus{added: 1, modified: 1, deleted: 1},
+			Working:  &gitStatus{modified: 2, untracked: 1, unmerged: 1},
+			Expected: " \u25CF3 \u271A2 \u20261 \u27161",
+		},
+		{Case: "Only staged", Staging: &gitStatus{added: 2}, Working: &gitStatus{}, Expected: " \u25CF2"},
+		{Case: "Only untracked", Staging: &gitStatus{}, Working: &gitStatus{untracked: 5}, Expected: " \u20265"},
+		{Case: "Untracked capped", Staging: &gitStatus{}, Working: &gitStatus{untracked: 100, maxUntracked: 99}, Expected: " \u202699+"},
+		{Case: "Modified and deleted", Staging: &gitStatus{}, Working: &gitStatus{modified: 1, deleted: 2}, Expected: " \u271A3"},
+		{
+			Case:     "Colors",
+			Staging:  &gitStatus{added: 1},
+			Working:  &gitStatus{untracked: 1},
+			Colors:   true,
+			Expected: " <green>\u25CF1</> \u20261",
+		},
+	}
+	for _, tc := range cases {
+		values := map[Property]interface{}{}
+		if tc.Colors {
+			values[StatusBarStagedColor] = "green"
+		}
+		g := &git{
+			props: &properties{
+				values: values,
+			},
+			repo: &gitRepo{
+				staging: tc.Staging,
+				working: tc.Working,
+			},
+		}
+		assert.Equal(t, tc.Expected, g.getStatusBar(), tc.Case)
+	}
+}
+
+func TestSetUpstreamBranch(t *testing.T) {
+	cases := []struct {
+		Case             string
+		BranchInfo       string
+		ExpectedUpstream string
+		ExpectedMismatch bool
+	}{
+		{Case: "Same name", BranchInfo: "## feature/x...origin/feature/x [ahead 1]", ExpectedUpstream: "feature/x"},
+		{Case: "Different name", BranchInfo: "## wip...origin/feature/x", ExpectedUpstream: "feature/x", ExpectedMismatch: true},
+		{Case: "Other remote", BranchInfo: "## main...upstream/main [behind 3]", ExpectedUpstream: "main"},
+		{Case: "Gone", BranchInfo: "## wip...origin/feature/x [gone]", ExpectedUpstream: "feature/x", ExpectedMismatch: true},
+		{Case: "No upstream", BranchInfo: "## wip"},
+	}
+	for _, tc := range cases {
+		g := &git{}
+		g.setUpstreamBranch(g.parseGitStatusInfo(tc.BranchInfo))
+		assert.Equal(t, tc.ExpectedUpstream, g.UpstreamBranch, tc.Case)
+		assert.Equal(t, tc.ExpectedMismatch, g.NameMismatch, tc.Case)
+	}
+}
+
+func TestGetNameMismatchIcon(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Mismatch bool
+		Icon     interface{}
+		Expected string
+	}{
+		{Case: "Mismatch", Mismatch: true, Expected: " \u2260"},
+		{Case: "Custom icon", Mismatch: true, Icon: "!=", Expected: " !="},
+		{Case: "Disabled", Mismatch: true, Icon: ""},
+		{Case: "Match"},
+	}
+	for _, tc := range cases {
+		values := map[Property]interface{}{}
+		if tc.Icon != nil {
+			values[NameMismatchIcon] = tc.Icon
+		}
+		g := &git{
+			props:        &properties{values: values},
+			NameMismatch: tc.Mismatch,
+		}
+		assert.Equal(t, tc.Expected, g.getNameMismatchIcon(), tc.Case)
+	}
+}
+
+func TestFetchInBackground(t *testing.T) {
+	key := "git_fetch:/dev/repo"
+	cases := []struct {
+		Case          string
+		Enabled       bool
+		LastFetch     time.Duration
+		Fetched       bool
+		ExpectedFetch bool
+	}{
+		{Case: "Never fetched", Enabled: true, ExpectedFetch: true},
+		{Case: "Recently fetched", Enabled: true, Fetched: true, LastFetch: time.Minute},
+		{Case: "Interval passed", Enabled: true, Fetched: true, LastFetch: 10 * time.Minute, ExpectedFetch: true},
+		{Case: "Disabled"},
+	}
+	for _, tc := range cases {
+		now := time.Now()
+		fc, cleanup := newTestFileCache(t, now.Add(-tc.LastFetch))
+		if tc.Fetched {
+			fc.set(key, "")
+		}
+		fc.now = func() time.Time { return now }
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("refreshCacheInBackground", key).Return(nil)
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo"},
+			props: &properties{
+				values: map[Property]interface{}{
+					FetchInBackground: tc.Enabled,
+				},
+			},
+		}
+		g.fetchInBackground()
+		if tc.ExpectedFetch {
+			env.AssertCalled(t, "refreshCacheInBackground", key)
+			// the following prompts see the fetch started
+			_, age, found := fc.get(key)
+			assert.True(t, found, tc.Case)
+			assert.Equal(t, time.Duration(0), age, tc.Case)
+		} else {
+			env.AssertNotCalled(t, "refreshCacheInBackground", key)
+		}
+		cleanup()
+	}
+}
+
+func TestGitFetchLock(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	lock, cleanupLock := newTestFileLock(t)
+	defer cleanupLock()
+	fetchArgs := []string{"-C", "/dev/repo", "fetch", "--quiet"}
+	env := new(MockedEnvironment)
+	env.On("cache", nil).Return(fc)
+	env.On("runCommandContext", "git", fetchArgs).Return("", "", 0, nil)
+	// another render is fetching
+	other := newFileLock(lock.path, lock.timeout)
+	assert.True(t, other.tryLock())
+	gitFetch(env, "/dev/repo", lock)
+	env.AssertNotCalled(t, "runCommandContext", "git", fetchArgs)
+	other.unlock()
+	gitFetch(env, "/dev/repo", lock)
+	env.AssertNumberOfCalls(t, "runCommandContext", 1)
+	_, _, found := fc.get("git_fetch:/dev/repo")
+	assert.True(t, found)
+	// the lock is released once done
+	assert.True(t, other.tryLock())
+}
+
+func TestSetLocalBranches(t *testing.T) {
+	packedRefs := "# pack-refs with: peeled fully-peeled sorted\n" +
+		"1234567890abcdef refs/heads/main\n" +
+		"1234567890abcdef refs/heads/release/1.0\n" +
+		"2345678901abcdef refs/remotes/origin/main\n" +
+		"3456789012abcdef refs/tags/v1.0.0\n" +
+		"^1234567890abcdef\n"
+	cases := []struct {
+		Case       string
+		Loose      map[string][]string
+		PackedRefs string
+		Disabled   bool
+		Expected   int
+	}{
+		{Case: "No branches"},
+		{Case: "Loose", Loose: map[string][]string{"refs/heads": {"main", "feature"}, "refs/heads/feature": {"x", "y"}}, Expected: 3},
+		{Case: "Packed", PackedRefs: packedRefs, Expected: 2},
+		{Case: "Loose and packed", Loose: map[string][]string{"refs/heads": {"main", "develop"}}, PackedRefs: packedRefs, Expected: 3},
+		{Case: "Disabled", Loose: map[string][]string{"refs/heads": {"main"}}, Disabled: true},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getFileContent", "/dev/repo/.git/packed-refs").Return(tc.PackedRefs)
+		for folder, names := range tc.Loose {
+			env.On("getDirEntries", "/dev/repo/.git/"+folder, -1).Return(names, nil)
+			env.On("hasFolder", "/dev/repo/.git/"+folder).Return(true)
+		}
+		env.On("getDirEntries", mock.Anything, -1).Return([]string{}, errors.New("no such folder"))
+		env.On("hasFolder", mock.Anything).Return(false)
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo"},
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayLocalBranches: !tc.Disabled,
+				},
+			},
+		}
+		g.setLocalBranches()
+		assert.Equal(t, tc.Expected, g.LocalBranches, tc.Case)
+		env.AssertNotCalled(t, "runCommand", "git", mock.Anything)
+	}
+}
+
+func TestSetLastFetch(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		Case     string
+		ModTime  time.Time
+		Err      error
+		Disabled bool
+		Expected string
+	}{
+		{Case: "Minutes ago", ModTime: now.Add(-5*time.Minute - 12*time.Second - 300*time.Millisecond), Expected: "5m 12s"},
+		{Case: "Days ago", ModTime: now.Add(-50 * time.Hour), Expected: "2d 2h 0m 0s"},
+		{Case: "Just now", ModTime: now, Expected: "0ms"},
+		{Case: "Clock skew", ModTime: now.Add(time.Minute), Expected: "0ms"},
+		{Case: "Never fetched", Err: errors.New("no such file or directory")},
+		{Case: "Disabled", ModTime: now, Disabled: true},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getModTime", "/dir/.git/FETCH_HEAD").Return(tc.ModTime, tc.Err)
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dir"},
+			now:  func() time.Time { return now },
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayLastFetch: !tc.Disabled,
+				},
+			},
+		}
+		g.setLastFetch()
+		assert.Equal(t, tc.Expected, g.LastFetch, tc.Case)
+	}
+}
+
+func TestGetLastFetchString(t *testing.T) {
+	cases := []struct {
+		Case      string
+		LastFetch string
+		Elapsed   time.Duration
+		Threshold interface{}
+		Expected  string
+	}{
+		{Case: "Fresh", LastFetch: "5m 0s", Elapsed: 5 * time.Minute, Threshold: float64(3600), Expected: " F 5m 0s"},
+		{Case: "Stale", LastFetch: "2h 0m 0s", Elapsed: 2 * time.Hour, Threshold: float64(3600), Expected: " F <#ff0000>2h 0m 0s</>"},
+		{Case: "No threshold", LastFetch: "2h 0m 0s", Elapsed: 2 * time.Hour, Expected: " F 2h 0m 0s"},
+		{Case: "Never fetched", Threshold: float64(3600)},
+	}
+	for _, tc := range cases {
+		values := map[Property]interface{}{
+			LastFetchIcon:       "F ",
+			LastFetchStaleColor: "#ff0000",
+		}
+		if tc.Threshold != nil {
+			values[LastFetchStaleThreshold] = tc.Threshold
+		}
+		g := &git{
+			LastFetch:        tc.LastFetch,
+			lastFetchElapsed: tc.Elapsed,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, g.getLastFetchString(), tc.Case)
+	}
+}
+
+func TestGetStatusOutputIndexLock(t *testing.T) {
+	statusArgs := []string{"-c", "core.quotepath=false", "-c", "color.status=false", "status", "-unormal", "--porcelain=2", "--branch", "--show-stash"}
+	cases := []struct {
+		Case           string
+		Locked         bool
+		Cached         string
+		Disabled       bool
+		Expected       string
+		ExpectedLocked bool
+		ExpectedCached string
+	}{
+		{Case: "Not locked", Expected: "# branch.head main", ExpectedCached: "# branch.head main"},
+		{Case: "Not locked, cache refreshed", Cached: "# branch.head develop", Expected: "# branch.head main", ExpectedCached: "# branch.head main"},
+		{Case: "Locked with cached status", Locked: true, Cached: "# branch.head develop", Expected: "# branch.head develop", ExpectedLocked: true, ExpectedCached: "# branch.head develop"},
+		{Case: "Locked without cached status", Locked: true, ExpectedLocked: true},
+		{Case: "Disabled", Locked: true, Disabled: true, Expected: "# branch.head main"},
+	}
+	for _, tc := range cases {
+		fc, cleanup := newTestFileCache(t, time.Now())
+		if tc.Cached != "" {
+			fc.set("git_status_/dev/repo", tc.Cached)
+		}
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("hasFilesInDir", "/dev/repo", ".git/index.lock").Return(tc.Locked)
+		env.mockGitCommand("# branch.head main", "status", "-unormal", "--porcelain=2", "--branch", "--show-stash")
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo"},
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayIndexLock: !tc.Disabled,
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, g.getStatusOutput(), tc.Case)
+		assert.Equal(t, tc.ExpectedLocked, g.IndexLocked, tc.Case)
+		if tc.Locked && !tc.Disabled {
+			env.AssertNotCalled(t, "runCommand", "git", statusArgs)
+		}
+		if !tc.Disabled {
+			cached, _, _ := fc.get("git_status_/dev/repo")
+			assert.Equal(t, tc.ExpectedCached, cached, tc.Case)
+		}
+		cleanup()
+	}
+}
+
+func TestGetStatusOutputCacheWrites(t *testing.T) {
+	large := "# branch.head main\n" + strings.Repeat("? untracked.txt\n", gitStatusCacheLimit/15)
+	cases := []struct {
+		Case            string
+		Status          string
+		Cached          string
+		ExpectedCached  string
+		ExpectedWritten bool
+	}{
+		{Case: "Unchanged", Status: "# branch.head main", Cached: "# branch.head main", ExpectedCached: "# branch.head main"},
+		{Case: "Changed", Status: "# branch.head main", Cached: "# branch.head develop", ExpectedCached: "# branch.head main", ExpectedWritten: true},
+		{Case: "Too large", Status: large, Cached: "# branch.head main", ExpectedWritten: true},
+		{Case: "Too large, already empty", Status: large, Cached: ""},
+	}
+	for _, tc := range cases {
+		now := time.Now()
+		fc, cleanup := newTestFileCache(t, now.Add(-time.Hour))
+		fc.set("git_status_/dev/repo", tc.Cached)
+		fc.now = func() time.Time { return now }
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("hasFilesInDir", "/dev/repo", ".git/index.lock").Return(false)
+		env.mockGitCommand(tc.Status, "status", "-unormal", "--porcelain=2", "--branch", "--show-stash")
+		g := &git{
+			env:  env,
+			repo: &gitRepo{root: "/dev/repo"},
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayIndexLock: true,
+				},
+			},
+		}
+		assert.Equal(t, tc.Status, g.getStatusOutput(), tc.Case)
+		cached, age, _ := fc.get("git_status_/dev/repo")
+		assert.Equal(t, tc.ExpectedCached, cached, tc.Case)
+		assert.Equal(t, tc.ExpectedWritten, age == 0, tc.Case)
+		cleanup()
+	}
+}
+
+func TestSetHEADTags(t *testing.T) {
+	cases := []struct {
+		Case           string
+		Refs           string
+		Disabled       bool
+		ExpectedTags   []string
+		ExpectedString string
+	}{
+		{Case: "No tags", Refs: "HEAD -> main, origin/main"},
+		{Case: "One tag", Refs: "HEAD -> main, tag: v1.0.0", ExpectedTags: []string{"v1.0.0"}, ExpectedString: " T v1.0.0"},
+		{
+			Case:           "Multiple tags",
+			Refs:           "HEAD -> main, tag: v1.0.0, origin/main, tag: stable, tag: latest",
+			ExpectedTags:   []string{"latest", "stable", "v1.0.0"},
+			ExpectedString: " T latest +2",
+		},
+		{Case: "Branch named like a tag", Refs: "HEAD -> tag, origin/tag"},
+		{Case: "Disabled", Refs: "HEAD -> main, tag: v1.0.0", Disabled: true},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.mockGitCommand("1234567890abcdef\n"+tc.Refs, "log", "-1", "--decorate=short", "--format=%H%n%D")
+		g := &git{
+			env:  env,
+			repo: &gitRepo{local: "main", commit: "1234567890abcdef"},
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayHEADTags: !tc.Disabled,
+					HEADTagsIcon:    "T ",
+				},
+			},
+		}
+		g.setHEADDetails(false)
+		assert.Equal(t, tc.ExpectedTags, g.Tags, tc.Case)
+		assert.Equal(t, tc.ExpectedString, g.getHEADTagsString(), tc.Case)
+	}
+}
+
+func TestGetBranchColor(t *testing.T) {
+	branchColor := func(local string, values map[Property]interface{}) string {
+		g := &git{
+			props: &properties{values: values},
+			repo:  &gitRepo{local: local},
+		}
+		return g.getBranchColor()
+	}
+	enabled := map[Property]interface{}{ColorBranchByName: true}
+	assert.Equal(t, branchColor("feature/x", enabled), branchColor("feature/x", enabled), "same branch, same color")
+	assert.Contains(t, defaultBranchColors, branchColor("main", enabled))
+	colors := make(map[string]bool)
+	for _, local := range []string{"main", "develop", "feature/x", "feature/y", "bugfix/1234", "release/1.0"} {
+		colors[branchColor(local, enabled)] = true
+	}
+	assert.Greater(t, len(colors), 1, "different branches mostly get different colors")
+	assert.Empty(t, branchColor("main", map[Property]interface{}{}), "disabled")
+	assert.Empty(t, branchColor("", enabled), "detached")
+	palette := map[Property]interface{}{
+		ColorBranchByName:  true,
+		BranchColorPalette: []interface{}{"#ff0000", "#00ff00"},
+	}
+	for _, local := range []string{"main", "develop", "feature/x"} {
+		assert.Contains(t, []string{"#ff0000", "#00ff00"}, branchColor(local, palette), local)
+	}
+	empty := map[Property]interface{}{
+		ColorBranchByName:  true,
+		BranchColorPalette: []interface{}{},
+	}
+	assert.Contains(t, defaultBranchColors, branchColor("main", empty), "an empty palette uses the default colors")
+}
+
+func TestGetSyncHints(t *testing.T) {
+	cases := []struct {
+		Case               string
+		Ahead              int
+		Behind             int
+		HasUpstreamChanges bool
+		HasLocalChanges    bool
+		Expected           string
+	}{
+		{Case: "Synced"},
+		{Case: "Ahead", Ahead: 2, HasLocalChanges: true, Expected: " push"},
+		{Case: "Behind", Behind: 1, HasUpstreamChanges: true, Expected: " pull"},
+		{Case: "Ahead and behind", Ahead: 2, Behind: 1, HasUpstreamChanges: true, HasLocalChanges: true, Expected: " pull push"},
+	}
+	for _, tc := range cases {
+		g := &git{
+			repo: &gitRepo{
+				ahead:  tc.Ahead,
+				behind: tc.Behind,
+			},
+			props: &properties{
+				values: map[Property]interface{}{
+					PullNeededIcon: "pull",
+					PushNeededIcon: "push",
+				},
+			},
+		}
+		g.setSyncState()
+		assert.Equal(t, tc.HasUpstreamChanges, g.HasUpstreamChanges, tc.Case)
+		assert.Equal(t, tc.HasLocalChanges, g.HasLocalChanges, tc.Case)
+		assert.Equal(t, tc.Expected, g.getSyncHints(), tc.Case)
+		g.props = &properties{}
+		assert.Empty(t, g.getSyncHints(), tc.Case)
+	}
+}
+
+func TestParsePorcelainV2(t *testing.T) {
+	cases := []struct {
+		Case       string
+		Output     string
+		Short      string
+		Commit     string
+		StashCount string
+	}{
+		{
+			Case:   "Synced",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +0 -0",
+			Short:  "## main...origin/main",
+			Commit: "1234567890abcdef",
+		},
+		{
+			Case:   "Ahead and behind",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +2 -1",
+			Short:  "## main...origin/main [ahead 2, behind 1]",
+			Commit: "1234567890abcdef",
+		},
+		{
+			Case:   "Behind",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +0 -3",
+			Short:  "## main...origin/main [behind 3]",
+			Commit: "1234567890abcdef",
+		},
+		{
+			Case:   "Gone",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head feature\n# branch.upstream origin/feature",
+			Short:  "## feature...origin/feature [gone]",
+			Commit: "1234567890abcdef",
+		},
+		{
+			Case:   "No upstream",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head feature",
+			Short:  "## feature",
+			Commit: "1234567890abcdef",
+		},
+		{
+			Case:   "Detached",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head (detached)",
+			Short:  "## HEAD (no branch)",
+			Commit: "1234567890abcdef",
+		},
+		{
+			Case:   "Initial commit",
+			Output: "# branch.oid (initial)\n# branch.head main",
+			Short:  "## main",
+		},
+		{
+			Case: "Changes and stash",
+			Output: "# branch.oid 1234567890abcdef\n# branch.head main\n# stash 3\n" +
+				"1 .M N... 100644 100644 100644 abc abc main.go\n" +
+				"1 A. N... 000000 100644 100644 000 abc new.go\n" +
+				"2 R. N... 100644 100644 100644 abc abc R100 renamed.go\told.go\n" +
+				"u UU N... 100644 100644 100644 100644 abc abc abc conflict.go\n" +
+				"? untracked.go\n" +
+				"! ignored.go",
+			Short:      "## main\n M main.go\nA  new.go\nR  old.go\nUU conflict.go\n?? untracked.go",
+			Commit:     "1234567890abcdef",
+			StashCount: "3",
+		},
+	}
+	for _, tc := range cases {
+		short, commit, stashCount := parsePorcelainV2(tc.Output)
+		assert.Equal(t, tc.Short, short, tc.Case)
+		assert.Equal(t, tc.Commit, commit, tc.Case)
+		assert.Equal(t, tc.StashCount, stashCount, tc.Case)
+	}
+}
+
+func TestSetGitStatusPorcelainV2(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	env := new(MockedEnvironment)
+	env.On("cache", nil).Return(fc)
+	env.On("hasFolder", mock.Anything).Return(false)
+	env.On("hasFilesInDir", mock.Anything, mock.Anything).Return(false)
+	env.mockGitCommand("# branch.oid 1234567890abcdef\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +2 -1\n# stash 2\n1 .M N... 100644 100644 100644 abc abc main.go",
+		"status", "-unormal", "--porcelain=2", "--branch", "--show-stash")
+	g := &git{
+		env:  env,
+		root: "/dir",
+		props: &properties{
+			values: map[Property]interface{}{
+				DisplayStashCount: true,
+			},
+		},
+	}
+	g.setGitStatus()
+	assert.Equal(t, "main", g.repo.local)
+	assert.Equal(t, "origin/main", g.repo.upstream)
+	assert.Equal(t, 2, g.repo.ahead)
+	assert.Equal(t, 1, g.repo.behind)
+	assert.Equal(t, "2", g.repo.stashCount)
+	assert.Equal(t, 1, g.repo.working.modified)
+	assert.True(t, g.Dirty())
+}
+
+func TestGitCommandCount(t *testing.T) {
+	porcelainV2 := "# branch.oid 1234567890abcdef\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +0 -0\n1 .M N... 100644 100644 100644 abc abc main.go"
+	detached := "# branch.oid 1234567890abcdef\n# branch.head (detached)"
+	allFeatures := map[Property]interface{}{
+		DisplayStashCount:    true,
+		FetchStashList:       true,
+		DisplayHEADTags:      true,
+		FetchSignature:       true,
+		FetchUser:            true,
+		ExpectedEmail:        "posh@example.com",
+		CompareBranch:        "upstream/main",
+		DisplayBaseBranch:    true,
+		DisplayLocalBranches: true,
+		DisplayUpstreamIcon:  true,
+	}
+	cases := []struct {
+		Case        string
+		Status      string
+		ShortStatus string
+		Props       map[Property]interface{}
+		Prompts     int
+		Expected    int
+	}{
+		{Case: "Default", Status: porcelainV2, Expected: 2},
+		{Case: "Stash count", Status: porcelainV2 + "\n# stash 2", Props: map[Property]interface{}{DisplayStashCount: true}, Expected: 2},
+		{Case: "Stash count, git before 2.35 or no stash", Status: porcelainV2, Props: map[Property]interface{}{DisplayStashCount: true}, Expected: 2},
+		{Case: "Stash list", Status: porcelainV2, Props: map[Property]interface{}{FetchStashList: true}, Expected: 2},
+		{Case: "Detached", Status: detached, Props: map[Property]interface{}{DisplayTag: false}, Expected: 2},
+		{Case: "Detached at a tag", Status: detached, Expected: 3},
+		{Case: "Upstream icon", Status: porcelainV2, Props: map[Property]interface{}{DisplayUpstreamIcon: true}, Expected: 2},
+		{Case: "HEAD tags", Status: porcelainV2, Props: map[Property]interface{}{DisplayHEADTags: true}, Expected: 3},
+		{Case: "Signature", Status: porcelainV2, Props: map[Property]interface{}{FetchSignature: true}, Expected: 3},
+		{Case: "User", Status: porcelainV2, Props: map[Property]interface{}{FetchUser: true, ExpectedEmail: "posh@example.com"}, Expected: 2},
+		{Case: "Compare branch", Status: porcelainV2, Props: map[Property]interface{}{CompareBranch: "upstream/main"}, Expected: 2},
+		{Case: "Base branch", Status: porcelainV2, Props: map[Property]interface{}{DisplayBaseBranch: true}, Expected: 2},
+		{Case: "Local branches", Status: porcelainV2, Props: map[Property]interface{}{DisplayLocalBranches: true}, Expected: 2},
+		{
+			Case:     "User, signature, tags and stash",
+			Status:   porcelainV2 + "\n# stash 2",
+			Props:    map[Property]interface{}{FetchUser: true, FetchSignature: true, DisplayHEADTags: true, DisplayStashCount: true, FetchStashList: true},
+			Expected: 3,
+		},
+		{Case: "Detached with signature and tags", Status: detached, Props: map[Property]interface{}{FetchSignature: true, DisplayHEADTags: true}, Expected: 3},
+		{Case: "All features", Status: porcelainV2, Props: allFeatures, Expected: 3},
+		{Case: "All features detached", Status: detached, Props: allFeatures, Expected: 3},
+		{Case: "All features, several prompts", Status: porcelainV2, Props: allFeatures, Prompts: 3, Expected: 9},
+		{Case: "Short status fallback", ShortStatus: "## main...origin/main\n M main.go", Expected: 3},
+		{Case: "Short status fallback remembered", ShortStatus: "## main...origin/main\n M main.go", Prompts: 2, Expected: 5},
+		{Case: "Short status fallback stash count", ShortStatus: "## main...origin/main", Props: map[Property]interface{}{DisplayStashCount: true}, Expected: 3},
+		// the first prompt also tries the porcelain v2 format
+		{Case: "Short status fallback all features", ShortStatus: "## HEAD (no branch)", Props: allFeatures, Prompts: 2, Expected: 7},
+	}
+	for _, tc := range cases {
+		fc, cleanup := newTestFileCache(t, time.Now())
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("hasCommand", "git").Return(true)
+		env.On("hasFolder", mock.Anything).Return(false)
+		env.On("hasFilesInDir", mock.Anything, mock.Anything).Return(false)
+		env.On("getFileContent", mock.Anything).Return("")
+		env.On("getDirEntries", mock.Anything, -1).Return([]string{"main"}, nil)
+		env.On("refreshCacheInBackground", mock.Anything).Return(nil)
+		env.On("runCommand", "git", []string{"rev-parse", "--is-inside-work-tree", "--is-bare-repository", "--show-toplevel"}).Return("true\nfalse\n/dir", nil)
+		env.mockGitCommand(tc.Status, "status", "-unormal", "--porcelain=2", "--branch", "--show-stash")
+		env.mockGitCommand(tc.ShortStatus, "status", "-unormal", "--short", "--branch")
+		env.mockGitCommand("1234567890abcdef\nHEAD, tag: v1.0.0", "log", "-1", "--decorate=short", "--format=%H%n%D")
+		env.mockGitCommand("1234567890abcdef\nHEAD, tag: v1.0.0\nG", "log", "-1", "--decorate=short", "--format=%H%n%D%n%G?")
+		prompts := tc.Prompts
+		if prompts == 0 {
+			prompts = 1
+		}
+		for i := 0; i < prompts; i++ {
+			g := &git{
+				env:   env,
+				props: &properties{values: tc.Props},
+			}
+			if g.enabled() {
+				_ = g.string()
+			}
+		}
+		var count int
+		for _, call := range env.Calls {
+			if (call.Method == "runCommand" || call.Method == "runCommandContext") && call.Arguments.String(0) == "git" {
+				count++
+			}
+		}
+		assert.Equal(t, tc.Expected, count, tc.Case)
+		cleanup()
+	}
 }
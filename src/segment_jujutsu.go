@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+)
+
+type jujutsu struct {
+	props *properties
+	env   environmentInfo
+	// ChangeId is the short change ID of the working copy
+	ChangeId string //nolint:golint // exposed to templates as .ChangeId
+	// Bookmark holds the bookmarks pointing to the working copy, comma separated
+	Bookmark string
+	// Dirty indicates the working copy has changes
+	Dirty bool
+}
+
+const (
+	// jjLogTemplate prints the change ID, the bookmarks and whether the working copy change is empty, tab separated
+	jjLogTemplate = `change_id.short() ++ "\t" ++ bookmarks.join(",") ++ "\t" ++ if(empty, "clean", "dirty")`
+)
+
+func (jj *jujutsu) enabled() bool {
+	if !jj.env.hasCommand("jj") || !jj.inWorkspace() {
+		return false
+	}
+	output, err := jj.env.runCommand("jj", "log", "--no-graph", "--color", "never", "-r", "@", "-T", jjLogTemplate)
+	if err != nil {
+		return false
+	}
+	return jj.parseLog(output)
+}
+
+func (jj *jujutsu) string() string {
+	template := &textTemplate{
+		Template: jj.props.getString(SegmentTemplate, "{{ if .Bookmark }}\uE0A0{{ .Bookmark }} {{ end }}{{ .ChangeId }}{{ if .Dirty }} \uF044{{ end }}"),
+		Context:  jj,
+	}
+	return template.render()
+}
+
+func (jj *jujutsu) init(props *properties, env environmentInfo) {
+	jj.props = props
+	jj.env = env
+}
+
+// inWorkspace looks for the .jj folder in the working directory or one of its parents
+func (jj *jujutsu) inWorkspace() bool {
+	separator := jj.env.getPathSeperator()
+	return walkUpFolders(jj.env.getcwd(), func(folder string) bool {
+		return jj.env.hasFolder(strings.TrimSuffix(folder, separator) + separator + ".jj")
+	})
+}
+
+// parseLog parses the output of jj log using the jjLogTemplate:
+// kxqvopwn	main,feature	dirty
+func (jj *jujutsu) parseLog(output string) bool {
+	values := strings.Split(strings.TrimSpace(output), "\t")
+	if len(values) != 3 || values[0] == "" {
+		return false
+	}
+	jj.ChangeId = values[0]
+	jj.Bookmark = values[1]
+	jj.Dirty = values[2] == "dirty"
+	return true
+}
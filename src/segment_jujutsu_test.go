@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jujutsuArgs struct {
+	hasJJ     bool
+	workspace string
+	output    string
+	err       error
+}
+
+func bootStrapJujutsuTest(args *jujutsuArgs) *jujutsu {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "jj").Return(args.hasJJ)
+	env.On("getPathSeperator", nil).Return("/")
+	env.On("getcwd", nil).Return("/home/jan/repo/src/app")
+	for _, folder := range []string{"/home/jan/repo/src/app", "/home/jan/repo/src", "/home/jan/repo", "/home/jan", "/home"} {
+		env.On("hasFolder", folder+"/.jj").Return(folder == args.workspace)
+	}
+	env.On("hasFolder", "/.jj").Return(args.workspace == "/")
+	env.On("runCommand", "jj", []string{"log", "--no-graph", "--color", "never", "-r", "@", "-T", jjLogTemplate}).Return(args.output, args.err)
+	return &jujutsu{
+		env:   env,
+		props: &properties{},
+	}
+}
+
+func TestJujutsuEnabled(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Args     *jujutsuArgs
+		Expected bool
+	}{
+		{Case: "Workspace root", Args: &jujutsuArgs{hasJJ: true, workspace: "/home/jan/repo/src/app", output: "kxqvopwn\t\tclean"}, Expected: true},
+		{Case: "Nested folder", Args: &jujutsuArgs{hasJJ: true, workspace: "/home/jan/repo", output: "kxqvopwn\tmain\tdirty"}, Expected: true},
+		{Case: "Outside workspace", Args: &jujutsuArgs{hasJJ: true, output: "kxqvopwn\tmain\tdirty"}},
+		{Case: "No jj executable", Args: &jujutsuArgs{workspace: "/home/jan/repo"}},
+		{Case: "Command fails", Args: &jujutsuArgs{hasJJ: true, workspace: "/home/jan/repo", err: errors.New("no repo")}},
+		{Case: "Unexpected output", Args: &jujutsuArgs{hasJJ: true, workspace: "/home/jan/repo", output: "Error: There is no jj repo in \".\""}},
+	}
+	for _, tc := range cases {
+		jj := bootStrapJujutsuTest(tc.Args)
+		assert.Equal(t, tc.Expected, jj.enabled(), tc.Case)
+	}
+}
+
+func TestJujutsuParseLog(t *testing.T) {
+	cases := []struct {
+		Case             string
+		Output           string
+		ExpectedChangeID string
+		ExpectedBookmark string
+		ExpectedDirty    bool
+		ExpectedString   string
+	}{
+		{
+			Case:             "Clean without bookmark",
+			Output:           "kxqvopwn\t\tclean\n",
+			ExpectedChangeID: "kxqvopwn",
+			ExpectedString:   "kxqvopwn",
+		},
+		{
+			Case:             "Dirty with bookmark",
+			Output:           "kxqvopwn\tmain\tdirty",
+			ExpectedChangeID: "kxqvopwn",
+			ExpectedBookmark: "main",
+			ExpectedDirty:    true,
+			ExpectedString:   "\uE0A0main kxqvopwn \uF044",
+		},
+		{
+			Case:             "Multiple bookmarks",
+			Output:           "zzmnqlto\tmain,feature*\tclean",
+			ExpectedChangeID: "zzmnqlto",
+			ExpectedBookmark: "main,feature*",
+			ExpectedString:   "\uE0A0main,feature* zzmnqlto",
+		},
+	}
+	for _, tc := range cases {
+		jj := &jujutsu{
+			props: &properties{},
+		}
+		assert.True(t, jj.parseLog(tc.Output), tc.Case)
+		assert.Equal(t, tc.ExpectedChangeID, jj.ChangeId, tc.Case)
+		assert.Equal(t, tc.ExpectedBookmark, jj.Bookmark, tc.Case)
+		assert.Equal(t, tc.ExpectedDirty, jj.Dirty, tc.Case)
+		assert.Equal(t, tc.ExpectedString, jj.string(), tc.Case)
+	}
+}
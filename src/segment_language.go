@@ -19,6 +19,8 @@ const (
 	DisplayModeContext string = "context"
 	// DisplayModeNever hides the segment
 	DisplayModeNever string = "never"
+	// CaseSensitiveGlobs matches the file-detection globs case sensitive or not
+	CaseSensitiveGlobs Property = "case_sensitive_globs"
 )
 
 func (l *language) string() string {
@@ -46,8 +48,9 @@ func (l *language) enabled() bool {
 }
 
 func (l *language) isInContext() bool {
+	caseSensitive := l.caseSensitiveGlobs()
 	for i, extension := range l.extensions {
-		if l.env.hasFiles(extension) {
+		if l.env.hasFiles(extension, caseSensitive) {
 			break
 		}
 		if i == len(l.extensions)-1 {
@@ -58,6 +61,17 @@ func (l *language) isInContext() bool {
 	return true
 }
 
+// caseSensitiveGlobs follows the file system of the platform unless configured
+// globally or for the segment, Windows and macOS are case insensitive by default
+func (l *language) caseSensitiveGlobs() bool {
+	if l.props.caseSensitiveGlobs != nil {
+		return l.props.getBool(CaseSensitiveGlobs, *l.props.caseSensitiveGlobs)
+	}
+	goos := l.env.getRuntimeGOOS()
+	caseSensitive := goos != windowsPlatform && goos != darwinPlatform
+	return l.props.getBool(CaseSensitiveGlobs, caseSensitive)
+}
+
 func (l *language) getVersion() bool {
 	var executable string
 	for i, command := range l.commands {
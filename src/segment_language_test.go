@@ -35,12 +35,13 @@ func (l *languageArgs) hasvalue(value string, list []string) bool {
 
 func bootStrapLanguageTest(args *languageArgs) *language {
 	env := new(MockedEnvironment)
+	env.On("getRuntimeGOOS", nil).Return("linux")
 	for _, command := range args.commands {
 		env.On("hasCommand", command).Return(args.hasvalue(command, args.enabledCommands))
 		env.On("runCommand", command, []string{args.versionParam}).Return(args.version, nil)
 	}
 	for _, extension := range args.extensions {
-		env.On("hasFiles", extension).Return(args.hasvalue(extension, args.enabledExtensions))
+		env.On("hasFiles", extension, true).Return(args.hasvalue(extension, args.enabledExtensions))
 	}
 	props := &properties{
 		values: map[Property]interface{}{
@@ -172,3 +173,40 @@ func TestLanguageEnabledNoVersion(t *testing.T) {
 	assert.True(t, lang.enabled())
 	assert.Equal(t, "", lang.string(), "unicorn is available and uni and corn files are found")
 }
+
+func TestLanguageCaseSensitiveGlobs(t *testing.T) {
+	enabled := true
+	disabled := false
+	cases := []struct {
+		Case     string
+		Expected bool
+		GOOS     string
+		Global   *bool
+		Segment  interface{}
+	}{
+		{Case: "linux", Expected: true, GOOS: "linux"},
+		{Case: "windows", Expected: false, GOOS: windowsPlatform},
+		{Case: "darwin", Expected: false, GOOS: darwinPlatform},
+		{Case: "global overrides platform", Expected: true, GOOS: windowsPlatform, Global: &enabled},
+		{Case: "segment overrides platform", Expected: false, GOOS: "linux", Segment: false},
+		{Case: "segment overrides global", Expected: true, GOOS: "linux", Global: &disabled, Segment: true},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getRuntimeGOOS", nil).Return(tc.GOOS)
+		env.On("hasFiles", uni, tc.Expected).Return(true)
+		props := &properties{
+			values:             map[Property]interface{}{},
+			caseSensitiveGlobs: tc.Global,
+		}
+		if tc.Segment != nil {
+			props.values[CaseSensitiveGlobs] = tc.Segment
+		}
+		lang := &language{
+			props:      props,
+			env:        env,
+			extensions: []string{uni},
+		}
+		assert.True(t, lang.isInContext(), tc.Case)
+	}
+}
@@ -3,14 +3,18 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 type path struct {
-	props *properties
-	env   environmentInfo
+	props   *properties
+	env     environmentInfo
+	ignored bool
 }
 
 const (
@@ -28,16 +32,100 @@ const (
 	AgnosterFull string = "agnoster_full"
 	// AgnosterShort displays the folder names with one folder_separator_icon, regardless of depth
 	AgnosterShort string = "agnoster_short"
+	// AgnosterLeft displays the root and the first max_depth folder names, the deeper folders collapse into the folder_icon
+	AgnosterLeft string = "agnoster_left"
 	// Short displays a shorter path
 	Short string = "short"
 	// Full displays the full path
 	Full string = "full"
 	// Folder displays the current folder
 	Folder string = "folder"
+	// Letter displays the first letter of every parent folder and the full current folder
+	Letter string = "letter"
 	// MappedLocations allows overriding certain location with an icon
 	MappedLocations Property = "mapped_locations"
 	// MappedLocationsEnabled enables overriding certain locations with an icon
 	MappedLocationsEnabled Property = "mapped_locations_enabled"
+	// BaseForeground the foreground color to use for the current folder
+	BaseForeground Property = "base_foreground"
+	// BaseBackground the background color to use for the current folder
+	BaseBackground Property = "base_background"
+	// NotExistIcon indicates the current working directory no longer exists
+	NotExistIcon Property = "not_exist_icon"
+	// FolderSeparatorIconInHome the folder separator to use when inside $HOME
+	FolderSeparatorIconInHome Property = "folder_separator_icon_in_home"
+	// SubmoduleIcon displayed in front of the path when inside a git submodule, disabled when empty
+	SubmoduleIcon Property = "submodule_icon"
+	// LowSpaceThreshold free space (GB) of the volume below which the low space warning is displayed
+	LowSpaceThreshold Property = "low_space_threshold"
+	// LowSpaceTemplate the template of the low space warning appended to the path
+	LowSpaceTemplate Property = "low_space_template"
+	// RelativeTo root folders, environment variables are expanded, the full path is displayed relative to the first matching root
+	RelativeTo Property = "relative_to"
+	// RelativeToIcon replaces the matching root folder
+	RelativeToIcon Property = "relative_to_icon"
+	// DetectionIgnoreFolders glob patterns of folders in which the path does no IO and displays the full path
+	DetectionIgnoreFolders Property = "detection_ignore_folders"
+	// DisplayIgnored highlights the path when git ignores the current folder, requires a git call
+	DisplayIgnored Property = "display_ignored"
+	// IgnoredIcon displayed in front of the path when git ignores the current folder
+	IgnoredIcon Property = "ignored_icon"
+	// IgnoredForeground the foreground color to use for the current folder when git ignores it
+	IgnoredForeground Property = "ignored_foreground"
+	// DisplayPermissions appends the permissions of the current folder to the path
+	DisplayPermissions Property = "display_permissions"
+	// PermissionsStyle displays the permissions as rwx or octal
+	PermissionsStyle Property = "permissions_style"
+	// OwnerOnly only displays the permissions of the owner
+	OwnerOnly Property = "owner_only"
+	// ReadOnlyIcon the permissions indicator on Windows when the current folder is read-only
+	ReadOnlyIcon Property = "read_only_icon"
+	// DisplayMountBoundary shows the MountBoundaryIcon when the current folder is on another file system than its parent
+	DisplayMountBoundary Property = "display_mount_boundary"
+	// MountBoundaryIcon displayed in front of the path when the current folder is a mount point
+	MountBoundaryIcon Property = "mount_boundary_icon"
+	// FilesystemRootIcon displayed in front of paths starting at the filesystem root, only by the agnoster styles
+	FilesystemRootIcon Property = "filesystem_root_icon"
+	// DisplayEntryCount appends the number of entries of the current folder to the path
+	DisplayEntryCount Property = "display_entry_count"
+	// IncludeHidden counts the hidden entries, starting with a dot, as well
+	IncludeHidden Property = "include_hidden"
+	// MaxEntries the maximum number of entries to list, displayed as 1000+ when exceeded
+	MaxEntries Property = "max_entries"
+	// EntryCountTemplate the template of the entry count appended to the path
+	EntryCountTemplate Property = "entry_count_template"
+	// PathTemplates a list of match globs and templates, the template of the first match is rendered instead of the style
+	PathTemplates Property = "path_templates"
+	// DisplayHash appends a short hash of the full working directory to the path
+	DisplayHash Property = "display_hash"
+	// HashLength the number of hexadecimal characters of the hash to display
+	HashLength Property = "hash_length"
+	// HashTemplate the template of the hash appended to the path
+	HashTemplate Property = "hash_template"
+	// CollapseRepeats collapses consecutive identical folder names into one with a multiplier: src/src becomes src\u00D72
+	CollapseRepeats Property = "collapse_repeats"
+	// RepeatIcon separates the folder name from the number of repeats
+	RepeatIcon Property = "repeat_icon"
+	// EnvRoots names of environment variables holding root folders, displayed as $NAME when the path starts with their value
+	EnvRoots Property = "env_roots"
+	// DisplayVCSIcon prefixes the path with the icon of the version control system of the repository the folder is in
+	DisplayVCSIcon Property = "display_vcs_icon"
+	// VCSIcons the icons per version control system: git, hg, svn and jj, an empty icon disables the detection
+	VCSIcons Property = "vcs_icons"
+	// MaxDepth the number of folders after the root displayed in full by the agnoster_left style
+	MaxDepth Property = "max_depth"
+	// ForwardSlashes displays the backslashes of a Windows path as forward slashes in the full, short and folder styles
+	ForwardSlashes Property = "forward_slashes"
+	// TrailingSeparator appends the folder separator icon to the path in the full and short styles: ~/projects/
+	TrailingSeparator Property = "trailing_separator"
+	// AnchorEnv the environment variable the shell sets to the folder a task started in, the path is displayed relative to it
+	AnchorEnv Property = "anchor_env"
+	// AnchorIcon replaces the anchor folder in the path
+	AnchorIcon Property = "anchor_icon"
+	// Rwx displays the permissions like ls: rwxr-xr-x
+	Rwx string = "rwx"
+	// Octal displays the permissions like chmod: 755
+	Octal string = "octal"
 )
 
 func (pt *path) enabled() bool {
@@ -45,13 +133,30 @@ func (pt *path) enabled() bool {
 }
 
 func (pt *path) string() string {
+	if pt.ignoreDetection() {
+		return pt.getFullPath()
+	}
+	if pt.cwdExists() {
+		pt.ignored = pt.isGitIgnored()
+		return pt.getMountBoundaryIcon() + pt.getVCSIcon() + pt.getSubmoduleIcon() + pt.getIgnoredIcon() + pt.getPathOutput() + pt.getPermissions() + pt.getEntryCount() + pt.getHash() + pt.getLowSpaceWarning()
+	}
+	notExistIcon := pt.props.getString(NotExistIcon, "\uF071 ")
+	if pt.env.getcwd() == "" {
+		return notExistIcon
+	}
+	return notExistIcon + pt.getPathOutput()
+}
+
+func (pt *path) getStyledPath() string {
 	switch style := pt.props.getString(Style, Agnoster); style {
 	case Agnoster:
-		return pt.getAgnosterPath()
+		return pt.withRootIcon(pt.getAgnosterPath())
 	case AgnosterFull:
-		return pt.getAgnosterFullPath()
+		return pt.withRootIcon(pt.getAgnosterFullPath())
 	case AgnosterShort:
-		return pt.getAgnosterShortPath()
+		return pt.withRootIcon(pt.getAgnosterShortPath())
+	case AgnosterLeft:
+		return pt.withRootIcon(pt.getAgnosterLeftPath())
 	case Short:
 		// "short" is a duplicate of "full", just here for backwards compatibility
 		fallthrough
@@ -59,26 +164,400 @@ func (pt *path) string() string {
 		return pt.getFullPath()
 	case Folder:
 		return pt.getFolderPath()
+	case Letter:
+		return pt.getLetterPath()
 	default:
 		return fmt.Sprintf("Path style: %s is not available", style)
 	}
 }
 
+// withRootIcon prefixes the path with the filesystem_root_icon when it starts at the filesystem root,
+// making /etc/nginx distinct from a location in $HOME or one of the mapped locations.
+// The other styles keep the leading separator, which tells them apart already
+func (pt *path) withRootIcon(styledPath string) string {
+	rootIcon := pt.props.getString(FilesystemRootIcon, "")
+	separator := pt.env.getPathSeperator()
+	if rootIcon == "" || !strings.HasPrefix(pt.getPwd(), separator) {
+		return styledPath
+	}
+	if styledPath == "" {
+		return rootIcon
+	}
+	return rootIcon + pt.getFolderSeparator() + styledPath
+}
+
 func (pt *path) init(props *properties, env environmentInfo) {
 	pt.props = props
 	pt.env = env
 }
 
+// cwdExists checks if the working directory is still present on disk,
+// locations which are not part of the file system are assumed to exist
+func (pt *path) cwdExists() bool {
+	cwd := strings.TrimPrefix(pt.env.getcwd(), "Microsoft.PowerShell.Core\\FileSystem::")
+	if cwd == "" {
+		return false
+	}
+	if strings.HasPrefix(cwd, "HKCU:") || strings.HasPrefix(cwd, "HKLM:") {
+		return true
+	}
+	return pt.env.hasFolder(cwd)
+}
+
+func (pt *path) getSubmoduleIcon() string {
+	submoduleIcon := pt.props.getString(SubmoduleIcon, "")
+	if submoduleIcon == "" || !pt.inSubmodule() {
+		return ""
+	}
+	return submoduleIcon
+}
+
+func (pt *path) getMountBoundaryIcon() string {
+	if !pt.props.getBool(DisplayMountBoundary, false) || !pt.crossesMountBoundary() {
+		return ""
+	}
+	return pt.props.getString(MountBoundaryIcon, "\uF0A0 ")
+}
+
+// crossesMountBoundary compares the device of the working directory with the one of its parent,
+// Windows has no device ids and compares the drive or UNC share with the one of $HOME instead
+func (pt *path) crossesMountBoundary() bool {
+	cwd := pt.env.getcwd()
+	if pt.env.getRuntimeGOOS() == windowsPlatform {
+		return !strings.EqualFold(windowsVolume(cwd), windowsVolume(pt.env.homeDir()))
+	}
+	parent := filepath.Dir(cwd)
+	if parent == cwd {
+		return false
+	}
+	device, err := pt.env.getDeviceID(cwd)
+	if err != nil {
+		return false
+	}
+	parentDevice, err := pt.env.getDeviceID(parent)
+	if err != nil {
+		return false
+	}
+	return device != parentDevice
+}
+
+// windowsVolume returns the drive (C:) or UNC share (\\server\share) of a Windows path,
+// filepath.VolumeName only understands the paths of the platform it runs on
+func windowsVolume(path string) string {
+	path = strings.TrimPrefix(path, "Microsoft.PowerShell.Core\\FileSystem::")
+	if strings.HasPrefix(path, `\\`) {
+		parts := strings.SplitN(path[2:], `\`, 3)
+		if len(parts) < 2 {
+			return path
+		}
+		return `\\` + parts[0] + `\` + parts[1]
+	}
+	if len(path) >= 2 && path[1] == ':' {
+		return path[:2]
+	}
+	return ""
+}
+
+func (pt *path) getIgnoredIcon() string {
+	if !pt.ignored {
+		return ""
+	}
+	return pt.props.getString(IgnoredIcon, "\uF070 ")
+}
+
+// isGitIgnored asks git whether the working directory is ignored,
+// outside of a repository git prints nothing to stdout
+func (pt *path) isGitIgnored() bool {
+	if !pt.props.getBool(DisplayIgnored, false) || !pt.env.hasCommand("git") {
+		return false
+	}
+	output, err := pt.env.runCommand("git", "check-ignore", pt.env.getcwd())
+	return err == nil && strings.TrimSpace(output) != ""
+}
+
+// getPermissions returns the permission bits of the working directory, Windows
+// has no permission bits and only indicates the folder is read-only
+func (pt *path) getPermissions() string {
+	if !pt.props.getBool(DisplayPermissions, false) {
+		return ""
+	}
+	mode, err := pt.env.getFileMode(pt.env.getcwd())
+	if err != nil {
+		return ""
+	}
+	perm := mode.Perm()
+	if pt.env.getRuntimeGOOS() == windowsPlatform {
+		if perm&0200 != 0 {
+			return ""
+		}
+		return " " + pt.props.getString(ReadOnlyIcon, "\uF023")
+	}
+	ownerOnly := pt.props.getBool(OwnerOnly, true)
+	if pt.props.getString(PermissionsStyle, Rwx) == Octal {
+		if ownerOnly {
+			return fmt.Sprintf(" %o", perm>>6)
+		}
+		return fmt.Sprintf(" %03o", perm)
+	}
+	// FileMode.String() starts with the type, d for a folder
+	rwx := perm.String()[1:]
+	if ownerOnly {
+		return " " + rwx[:3]
+	}
+	return " " + rwx
+}
+
+// getLowSpaceWarning returns the low space template when the free space on the volume
+// of the working directory drops below the threshold
+func (pt *path) getLowSpaceWarning() string {
+	threshold := pt.props.getFloat64(LowSpaceThreshold, 0)
+	if threshold <= 0 {
+		return ""
+	}
+	free, err := pt.env.getFreeSpace(pt.env.getcwd())
+	if err != nil || float64(free) >= threshold*1024*1024*1024 {
+		return ""
+	}
+	template := &textTemplate{
+		Template: pt.props.getString(LowSpaceTemplate, " ({{ .FreeSpace }} free)"),
+		Context: struct {
+			FreeSpace string
+			FreeBytes uint64
+		}{
+			FreeSpace: formatBytes(free),
+			FreeBytes: free,
+		},
+	}
+	return template.render()
+}
+
+// getEntryCount returns the number of entries of the current folder, the listing stops at max_entries
+// to keep large folders from slowing down the prompt
+func (pt *path) getEntryCount() string {
+	if !pt.props.getBool(DisplayEntryCount, false) {
+		return ""
+	}
+	maxEntries := int(pt.props.getFloat64(MaxEntries, 1000))
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	// one more than the maximum tells whether it's exceeded
+	names, err := pt.env.getDirEntries(pt.env.getcwd(), maxEntries+1)
+	if err != nil {
+		return ""
+	}
+	includeHidden := pt.props.getBool(IncludeHidden, false)
+	var entries int
+	for _, name := range names {
+		if includeHidden || !strings.HasPrefix(name, ".") {
+			entries++
+		}
+	}
+	count := strconv.Itoa(entries)
+	if len(names) > maxEntries {
+		entries = maxEntries
+		count = fmt.Sprintf("%d+", maxEntries)
+	}
+	template := &textTemplate{
+		Template: pt.props.getString(EntryCountTemplate, " ({{ .Count }})"),
+		Context: struct {
+			Count   string
+			Entries int
+		}{
+			Count:   count,
+			Entries: entries,
+		},
+	}
+	return template.render()
+}
+
+// getHash returns a short fnv hash of the full working directory, the same folder always renders the same hash
+func (pt *path) getHash() string {
+	if !pt.props.getBool(DisplayHash, false) {
+		return ""
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(pt.env.getcwd()))
+	sum := fmt.Sprintf("%016x", hash.Sum64())
+	length := int(pt.props.getFloat64(HashLength, 8))
+	if length <= 0 {
+		length = 8
+	}
+	if length > len(sum) {
+		length = len(sum)
+	}
+	template := &textTemplate{
+		Template: pt.props.getString(HashTemplate, " #{{ .Hash }}"),
+		Context: struct {
+			Hash string
+		}{
+			Hash: sum[:length],
+		},
+	}
+	return template.render()
+}
+
+// vcsMarkers are the folders marking the root of a repository, in order of precedence within the same folder:
+// a jj repository colocated with git has both
+var vcsMarkers = []struct {
+	name   string
+	folder string
+}{
+	{name: "jj", folder: ".jj"},
+	{name: "git", folder: ".git"},
+	{name: "hg", folder: ".hg"},
+	{name: "svn", folder: ".svn"},
+}
+
+// getVCSIcon returns the icon of the version control system of the closest repository,
+// looking for its marker folder in the working directory or one of its parents
+func (pt *path) getVCSIcon() string {
+	if !pt.props.getBool(DisplayVCSIcon, false) {
+		return ""
+	}
+	icons := map[string]string{
+		"git": "\uE702 ",
+		"hg":  "\uF0C3 ",
+		"svn": "\uF126 ",
+		"jj":  "jj ",
+	}
+	for name, icon := range pt.props.getKeyValueMap(VCSIcons, map[string]string{}) {
+		icons[name] = icon
+	}
+	separator := pt.env.getPathSeperator()
+	var vcsIcon string
+	walkUpFolders(pt.env.getcwd(), func(folder string) bool {
+		for _, marker := range vcsMarkers {
+			icon := icons[marker.name]
+			if icon != "" && pt.env.hasFolder(strings.TrimSuffix(folder, separator)+separator+marker.folder) {
+				vcsIcon = icon
+				return true
+			}
+		}
+		return false
+	})
+	return vcsIcon
+}
+
+// inSubmodule checks if the enclosing .git of the working directory is a file
+// pointing into the modules folder of the parent repository: gitdir: ../.git/modules/name
+func (pt *path) inSubmodule() bool {
+	separator := pt.env.getPathSeperator()
+	var submodule bool
+	walkUpFolders(pt.env.getcwd(), func(folder string) bool {
+		dotGit := strings.TrimSuffix(folder, separator) + separator + ".git"
+		if content := pt.env.getFileContent(dotGit); content != "" {
+			gitDir := strings.ReplaceAll(content, "\\", "/")
+			submodule = strings.HasPrefix(gitDir, "gitdir:") && strings.Contains(gitDir, "/modules/")
+			return true
+		}
+		// the enclosing repository is a regular one
+		return pt.env.hasFolder(dotGit)
+	})
+	return submodule
+}
+
+// ignoreDetection checks if the working directory, or one of its parents,
+// matches a glob pattern in detection_ignore_folders, e.g. /mnt/* or ~/network/*
+func (pt *path) ignoreDetection() bool {
+	patterns := pt.props.getStringArray(DetectionIgnoreFolders, []string{})
+	if len(patterns) == 0 {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pt.matchesFolder(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFolder indicates the glob pattern matches the current folder or one of its parents,
+// a leading ~ is replaced by $HOME
+func (pt *path) matchesFolder(pattern string) bool {
+	cwd := strings.TrimPrefix(pt.env.getcwd(), "Microsoft.PowerShell.Core\\FileSystem::")
+	if strings.HasPrefix(pattern, "~") {
+		pattern = pt.env.homeDir() + pattern[1:]
+	}
+	return walkUpFolders(cwd, func(folder string) bool {
+		matched, err := filepath.Match(pattern, folder)
+		return err == nil && matched
+	})
+}
+
+// pathTemplateContext is available in the path_templates templates
+type pathTemplateContext struct {
+	Path   string
+	Folder string
+	Pwd    string
+	Env    map[string]string
+}
+
+// getPathOutput renders the template of the first path_templates entry matching the current folder,
+// without a match the style decides
+func (pt *path) getPathOutput() string {
+	styledPath := pt.getStyledPath()
+	text, ok := pt.getPathTemplate()
+	if !ok {
+		return styledPath
+	}
+	template := &textTemplate{
+		Template: text,
+		Context: &pathTemplateContext{
+			Path:   styledPath,
+			Folder: base(pt.getPwd(), pt.env),
+			Pwd:    pt.getPwd(),
+			Env:    pt.env.environ(),
+		},
+	}
+	return template.render()
+}
+
+// getPathTemplate returns the template of the first path_templates entry, in order of declaration,
+// whose match glob matches the current folder
+func (pt *path) getPathTemplate() (string, bool) {
+	if pt.props == nil {
+		return "", false
+	}
+	entries, ok := pt.props.values[PathTemplates].([]interface{})
+	if !ok {
+		return "", false
+	}
+	for _, entry := range entries {
+		values, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		match, _ := values["match"].(string)
+		text, _ := values["template"].(string)
+		if match == "" || !pt.matchesFolder(match) {
+			continue
+		}
+		return text, true
+	}
+	return "", false
+}
+
+func (pt *path) getFolderSeparator() string {
+	folderSeparator := pt.props.getString(FolderSeparatorIcon, pt.env.getPathSeperator())
+	cwd := strings.TrimPrefix(pt.env.getcwd(), "Microsoft.PowerShell.Core\\FileSystem::")
+	if pt.inHomeDir(cwd) {
+		return pt.props.getString(FolderSeparatorIconInHome, folderSeparator)
+	}
+	return folderSeparator
+}
+
 func (pt *path) getAgnosterPath() string {
 	buffer := new(bytes.Buffer)
 	pwd := pt.getPwd()
 	buffer.WriteString(pt.rootLocation())
 	pathDepth := pt.pathDepth(pwd)
+	folderSeparator := pt.getFolderSeparator()
 	for i := 1; i < pathDepth; i++ {
-		buffer.WriteString(fmt.Sprintf("%s%s", pt.props.getString(FolderSeparatorIcon, pt.env.getPathSeperator()), pt.props.getString(FolderIcon, "..")))
+		buffer.WriteString(fmt.Sprintf("%s%s", folderSeparator, pt.props.getString(FolderIcon, "..")))
 	}
 	if pathDepth > 0 {
-		buffer.WriteString(fmt.Sprintf("%s%s", pt.props.getString(FolderSeparatorIcon, pt.env.getPathSeperator()), base(pwd, pt.env)))
+		buffer.WriteString(fmt.Sprintf("%s%s", folderSeparator, pt.colorizeBase(base(pwd, pt.env))))
 	}
 	return buffer.String()
 }
@@ -86,16 +565,14 @@ func (pt *path) getAgnosterPath() string {
 func (pt *path) getAgnosterFullPath() string {
 	pwd := pt.getPwd()
 	pathSeparator := pt.env.getPathSeperator()
-	folderSeparator := pt.props.getString(FolderSeparatorIcon, pathSeparator)
-	if string(pwd[0]) == pathSeparator {
-		pwd = pwd[1:]
-	}
-	return strings.ReplaceAll(pwd, pathSeparator, folderSeparator)
+	folderSeparator := pt.getFolderSeparator()
+	pwd = strings.TrimPrefix(pwd, pathSeparator)
+	parent, base := splitBase(pwd, pathSeparator)
+	return strings.ReplaceAll(parent, pathSeparator, folderSeparator) + pt.colorizeBase(base)
 }
 
 func (pt *path) getAgnosterShortPath() string {
-	pathSeparator := pt.env.getPathSeperator()
-	folderSeparator := pt.props.getString(FolderSeparatorIcon, pathSeparator)
+	folderSeparator := pt.getFolderSeparator()
 	folderIcon := pt.props.getString(FolderIcon, "..")
 	root := pt.rootLocation()
 	pwd := pt.getPwd()
@@ -105,28 +582,184 @@ func (pt *path) getAgnosterShortPath() string {
 		return root
 	}
 	if pathDepth == 1 {
-		return fmt.Sprintf("%s%s%s", root, folderSeparator, base)
+		return fmt.Sprintf("%s%s%s", root, folderSeparator, pt.colorizeBase(base))
+	}
+	return fmt.Sprintf("%s%s%s%s%s", root, folderSeparator, folderIcon, folderSeparator, pt.colorizeBase(base))
+}
+
+// getAgnosterLeftPath keeps the ancestors and collapses the deep end: ~ > src > github > .. > ..
+func (pt *path) getAgnosterLeftPath() string {
+	folderSeparator := pt.getFolderSeparator()
+	folderIcon := pt.props.getString(FolderIcon, "..")
+	maxDepth := int(pt.props.getFloat64(MaxDepth, 1))
+	if maxDepth < 1 {
+		maxDepth = 1
+	}
+	var folders []string
+	for _, folder := range strings.Split(pt.getPwd(), pt.env.getPathSeperator()) {
+		if folder != "" {
+			folders = append(folders, folder)
+		}
 	}
-	return fmt.Sprintf("%s%s%s%s%s", root, folderSeparator, folderIcon, folderSeparator, base)
+	if len(folders) <= 1 {
+		return pt.rootLocation()
+	}
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(folders[0])
+	last := len(folders) - 1
+	for i := 1; i <= last; i++ {
+		folder := folders[i]
+		if i > maxDepth {
+			folder = folderIcon
+		}
+		if i == last {
+			folder = pt.colorizeBase(folder)
+		}
+		buffer.WriteString(fmt.Sprintf("%s%s", folderSeparator, folder))
+	}
+	return buffer.String()
 }
 
 func (pt *path) getFullPath() string {
-	return pt.getPwd()
+	pwd := pt.getPwd()
+	// the anchor the shell set takes precedence over the static relative_to roots
+	_, anchored := pt.getAnchoredPath(pt.env.getcwd())
+	if relativePath, ok := pt.getRelativePath(); ok && !anchored {
+		pwd = pt.collapseRepeats(relativePath)
+	}
+	parent, base := splitBase(pwd, pt.env.getPathSeperator())
+	return pt.withForwardSlashes(parent) + pt.colorizeBase(base) + pt.getTrailingSeparator(pwd)
+}
+
+// getTrailingSeparator returns the folder separator icon to end the path with,
+// nothing when the path already ends with a separator like the root / or C:\
+func (pt *path) getTrailingSeparator(pwd string) string {
+	if !pt.props.getBool(TrailingSeparator, false) {
+		return ""
+	}
+	folderSeparator := pt.getFolderSeparator()
+	if strings.HasSuffix(pwd, pt.env.getPathSeperator()) || strings.HasSuffix(pwd, folderSeparator) {
+		return ""
+	}
+	return pt.withForwardSlashes(folderSeparator)
+}
+
+// withForwardSlashes replaces the backslashes of a Windows path with forward slashes: C:\Users becomes C:/Users
+func (pt *path) withForwardSlashes(text string) string {
+	if !pt.props.getBool(ForwardSlashes, false) {
+		return text
+	}
+	return strings.ReplaceAll(text, `\`, "/")
+}
+
+// getRelativePath returns the working directory relative to the first matching relative_to root,
+// prefixed with the relative_to_icon: $GOPATH/src/github.com/org/repo becomes .../org/repo
+func (pt *path) getRelativePath() (string, bool) {
+	roots := pt.props.getStringArray(RelativeTo, []string{})
+	if len(roots) == 0 {
+		return "", false
+	}
+	separator := pt.env.getPathSeperator()
+	cwd := strings.TrimPrefix(pt.env.getcwd(), "Microsoft.PowerShell.Core\\FileSystem::")
+	icon := pt.props.getString(RelativeToIcon, "...")
+	for _, root := range roots {
+		root = os.Expand(root, pt.env.getenv)
+		if strings.HasPrefix(root, "~") {
+			root = pt.env.homeDir() + root[1:]
+		}
+		root = strings.TrimSuffix(root, separator)
+		if root == "" {
+			continue
+		}
+		if cwd == root {
+			return icon, true
+		}
+		if strings.HasPrefix(cwd, root+separator) {
+			return icon + cwd[len(root):], true
+		}
+	}
+	return "", false
 }
 
 func (pt *path) getFolderPath() string {
 	pwd := pt.getPwd()
-	return base(pwd, pt.env)
+	return pt.withForwardSlashes(base(pwd, pt.env))
+}
+
+func (pt *path) getLetterPath() string {
+	pathSeparator := pt.env.getPathSeperator()
+	folderSeparator := pt.getFolderSeparator()
+	splitted := strings.Split(pt.getPwd(), pathSeparator)
+	for i := 0; i < len(splitted)-1; i++ {
+		splitted[i] = firstLetter(splitted[i])
+	}
+	last := len(splitted) - 1
+	splitted[last] = pt.colorizeBase(splitted[last])
+	return strings.Join(splitted, folderSeparator)
 }
 
 func (pt *path) getPwd() string {
 	pwd := pt.env.getcwd()
 
+	if anchoredPath, ok := pt.getAnchoredPath(pwd); ok {
+		return pt.collapseRepeats(anchoredPath)
+	}
+
 	if pt.props.getBool(MappedLocationsEnabled, true) {
 		pwd = pt.replaceMappedLocations(pwd)
 	}
 
-	return pwd
+	return pt.collapseRepeats(pwd)
+}
+
+// getAnchoredPath returns the working directory relative to the folder in the anchor_env environment variable,
+// prefixed with the anchor_icon. Outside of the anchor, or when the shell didn't set it, the path is displayed as usual
+func (pt *path) getAnchoredPath(pwd string) (string, bool) {
+	name := pt.props.getString(AnchorEnv, "")
+	if name == "" {
+		return "", false
+	}
+	separator := pt.env.getPathSeperator()
+	anchor := strings.TrimSuffix(pt.env.getenv(name), separator)
+	if anchor == "" {
+		return "", false
+	}
+	pwd = strings.TrimPrefix(pwd, "Microsoft.PowerShell.Core\\FileSystem::")
+	icon := pt.props.getString(AnchorIcon, "\uF13D")
+	if pwd == anchor {
+		return icon, true
+	}
+	if strings.HasPrefix(pwd, anchor+separator) {
+		return icon + pwd[len(anchor):], true
+	}
+	return "", false
+}
+
+// collapseRepeats replaces consecutive identical folder names with the name and the number of repeats,
+// app/app/src becomes app\u00D72/src while app/src/app is left untouched
+func (pt *path) collapseRepeats(pwd string) string {
+	if !pt.props.getBool(CollapseRepeats, false) {
+		return pwd
+	}
+	separator := pt.env.getPathSeperator()
+	repeatIcon := pt.props.getString(RepeatIcon, "\u00D7")
+	var folders []string
+	repeats := 1
+	for i, folder := range strings.Split(pwd, separator) {
+		if i > 0 && folder != "" && folder == folders[len(folders)-1] {
+			repeats++
+			continue
+		}
+		if repeats > 1 {
+			folders[len(folders)-1] += fmt.Sprintf("%s%d", repeatIcon, repeats)
+			repeats = 1
+		}
+		folders = append(folders, folder)
+	}
+	if repeats > 1 {
+		folders[len(folders)-1] += fmt.Sprintf("%s%d", repeatIcon, repeats)
+	}
+	return strings.Join(folders, separator)
 }
 
 func (pt *path) replaceMappedLocations(pwd string) string {
@@ -140,6 +773,18 @@ func (pt *path) replaceMappedLocations(pwd string) string {
 		pt.env.homeDir(): pt.props.getString(HomeIcon, "~"),
 	}
 
+	// folders stored in environment variables map back to their symbolic name: $PROJECTS
+	separator := pt.env.getPathSeperator()
+	envRoots := make(map[string]bool)
+	for _, name := range pt.props.getStringArray(EnvRoots, []string{}) {
+		root := strings.TrimSuffix(pt.env.getenv(name), separator)
+		if root == "" {
+			continue
+		}
+		mappedLocations[root] = "$" + name
+		envRoots[root] = true
+	}
+
 	// merge custom locations with mapped locations
 	// mapped locations can override predefined locations
 	keyValues := pt.props.getKeyValueMap(MappedLocations, make(map[string]string))
@@ -159,13 +804,35 @@ func (pt *path) replaceMappedLocations(pwd string) string {
 	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
 
 	for _, value := range keys {
-		if strings.HasPrefix(pwd, value) {
-			return strings.Replace(pwd, value, mappedLocations[value], 1)
+		if !strings.HasPrefix(pwd, value) {
+			continue
+		}
+		// an environment root only matches whole folders, /src isn't the root of /srcfoo
+		if envRoots[value] && pwd != value && !strings.HasPrefix(pwd, value+separator) {
+			continue
 		}
+		return strings.Replace(pwd, value, mappedLocations[value], 1)
 	}
 	return pwd
 }
 
+// colorizeBase wraps the current folder name in the base colors when set,
+// the unset color is inherited from the segment
+func (pt *path) colorizeBase(base string) string {
+	foreground := pt.props.getColor(BaseForeground, "")
+	if pt.ignored {
+		foreground = pt.props.getColor(IgnoredForeground, foreground)
+	}
+	background := pt.props.getColor(BaseBackground, "")
+	if base == "" || (foreground == "" && background == "") {
+		return base
+	}
+	if background == "" {
+		return fmt.Sprintf("<%s>%s</>", foreground, base)
+	}
+	return fmt.Sprintf("<%s,%s>%s</>", foreground, background, base)
+}
+
 func (pt *path) inHomeDir(pwd string) bool {
 	return strings.HasPrefix(pwd, pt.env.homeDir())
 }
@@ -190,6 +857,34 @@ func (pt *path) pathDepth(pwd string) int {
 	return depth - 1
 }
 
+// splitBase splits the path after the last separator,
+// returning the parent including the separator and the last element
+func splitBase(path, separator string) (string, string) {
+	i := strings.LastIndex(path, separator)
+	if i < 0 {
+		return "", path
+	}
+	return path[:i+len(separator)], path[i+len(separator):]
+}
+
+// firstLetter returns the first rune of a folder name,
+// dotfolders keep the leading dot: .config becomes .c
+func firstLetter(folder string) string {
+	// keep drive letters like C: intact
+	if strings.HasSuffix(folder, ":") {
+		return folder
+	}
+	prefix := ""
+	if strings.HasPrefix(folder, ".") && len(folder) > 1 {
+		prefix = "."
+		folder = folder[1:]
+	}
+	for _, letter := range folder {
+		return prefix + string(letter)
+	}
+	return prefix
+}
+
 // Base returns the last element of path.
 // Trailing path separators are removed before extracting the last element.
 // If the path is empty, Base returns ".".
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/distatus/battery"
+	"github.com/shirou/gopsutil/cpu"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -27,8 +32,8 @@ func (env *MockedEnvironment) homeDir() string {
 	return args.String(0)
 }
 
-func (env *MockedEnvironment) hasFiles(pattern string) bool {
-	args := env.Called(pattern)
+func (env *MockedEnvironment) hasFiles(pattern string, caseSensitive bool) bool {
+	args := env.Called(pattern, caseSensitive)
 	return args.Bool(0)
 }
 
@@ -82,6 +87,11 @@ func (env *MockedEnvironment) runCommand(command string, args ...string) (string
 	return arguments.String(0), arguments.Error(1)
 }
 
+func (env *MockedEnvironment) runCommandContext(ctx context.Context, command string, args ...string) (string, string, int, error) {
+	arguments := env.Called(command, args)
+	return arguments.String(0), arguments.String(1), arguments.Int(2), arguments.Error(3)
+}
+
 func (env *MockedEnvironment) runShellCommand(shell, command string) string {
 	args := env.Called(shell, command)
 	return args.String(0)
@@ -112,6 +122,11 @@ func (env *MockedEnvironment) getBatteryInfo() (*battery.Battery, error) {
 	return args.Get(0).(*battery.Battery), args.Error(1)
 }
 
+func (env *MockedEnvironment) getCPUTimes() ([]cpu.TimesStat, error) {
+	args := env.Called(nil)
+	return args.Get(0).([]cpu.TimesStat), args.Error(1)
+}
+
 func (env *MockedEnvironment) getShellName() string {
 	args := env.Called(nil)
 	return args.String(0)
@@ -127,6 +142,46 @@ func (env *MockedEnvironment) doGet(url string) ([]byte, error) {
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (env *MockedEnvironment) cache() cache {
+	args := env.Called(nil)
+	return args.Get(0).(cache)
+}
+
+func (env *MockedEnvironment) environ() map[string]string {
+	args := env.Called(nil)
+	return args.Get(0).(map[string]string)
+}
+
+func (env *MockedEnvironment) getFreeSpace(path string) (uint64, error) {
+	args := env.Called(path)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (env *MockedEnvironment) getDeviceID(path string) (uint64, error) {
+	args := env.Called(path)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (env *MockedEnvironment) getDirEntries(dir string, limit int) ([]string, error) {
+	args := env.Called(dir, limit)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (env *MockedEnvironment) getFileMode(path string) (os.FileMode, error) {
+	args := env.Called(path)
+	return args.Get(0).(os.FileMode), args.Error(1)
+}
+
+func (env *MockedEnvironment) getModTime(path string) (time.Time, error) {
+	args := env.Called(path)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (env *MockedEnvironment) refreshCacheInBackground(key string) error {
+	args := env.Called(key)
+	return args.Error(0)
+}
+
 const (
 	homeBill        = "/home/bill"
 	homeJan         = "/usr/home/jan"
@@ -611,3 +666,1135 @@ func TestGetPwd(t *testing.T) {
 		assert.Equal(t, tc.Expected, got)
 	}
 }
+
+func TestColorizeBase(t *testing.T) {
+	cases := []struct {
+		Case           string
+		BaseForeground string
+		BaseBackground string
+		Base           string
+		Expected       string
+	}{
+		{Case: "No colors", Base: "man", Expected: "man"},
+		{Case: "Foreground", BaseForeground: "#ff0000", Base: "man", Expected: "<#ff0000>man</>"},
+		{Case: "Background", BaseBackground: "#00ff00", Base: "man", Expected: "<,#00ff00>man</>"},
+		{Case: "Both", BaseForeground: "red", BaseBackground: "#00ff00", Base: "man", Expected: "<red,#00ff00>man</>"},
+		{Case: "Invalid color", BaseForeground: "garbage", Base: "man", Expected: "man"},
+		{Case: "Empty base", BaseForeground: "#ff0000", Base: "", Expected: ""},
+	}
+	for _, tc := range cases {
+		values := map[Property]interface{}{}
+		if tc.BaseForeground != "" {
+			values[BaseForeground] = tc.BaseForeground
+		}
+		if tc.BaseBackground != "" {
+			values[BaseBackground] = tc.BaseBackground
+		}
+		path := &path{
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.colorizeBase(tc.Base), tc.Case)
+	}
+}
+
+func TestBaseColorStyles(t *testing.T) {
+	cases := []struct {
+		Style    string
+		Expected string
+	}{
+		{Style: Agnoster, Expected: "usr > .. > .. > <#ff0000>man</>"},
+		{Style: AgnosterFull, Expected: "usr > location > whatever > <#ff0000>man</>"},
+		{Style: AgnosterShort, Expected: "usr > .. > <#ff0000>man</>"},
+		{Style: Full, Expected: "/usr/location/whatever/<#ff0000>man</>"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return("/usr/location/whatever/man")
+		env.On("hasFolder", "/usr/location/whatever/man").Return(true)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					Style:               tc.Style,
+					FolderSeparatorIcon: " > ",
+					BaseForeground:      "#ff0000",
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.string(), tc.Style)
+	}
+}
+
+func TestSplitBase(t *testing.T) {
+	cases := []struct {
+		Path      string
+		Separator string
+		Parent    string
+		Base      string
+	}{
+		{Path: "/usr/location", Separator: "/", Parent: "/usr/", Base: "location"},
+		{Path: "C:\\Program Files", Separator: "\\", Parent: "C:\\", Base: "Program Files"},
+		{Path: "~", Separator: "/", Parent: "", Base: "~"},
+		{Path: "/", Separator: "/", Parent: "/", Base: ""},
+	}
+	for _, tc := range cases {
+		parent, base := splitBase(tc.Path, tc.Separator)
+		assert.Equal(t, tc.Parent, parent, tc.Path)
+		assert.Equal(t, tc.Base, base, tc.Path)
+	}
+}
+
+func TestPathNotExist(t *testing.T) {
+	cases := []struct {
+		Case         string
+		Expected     string
+		Pwd          string
+		Exists       bool
+		NotExistIcon string
+	}{
+		{Case: "Existing folder", Expected: "/usr/location", Pwd: "/usr/location", Exists: true},
+		{Case: "Removed folder", Expected: "\uF071 /usr/location", Pwd: "/usr/location", Exists: false},
+		{Case: "Removed folder custom icon", Expected: "gone /usr/location", Pwd: "/usr/location", NotExistIcon: "gone "},
+		{Case: "Unknown folder", Expected: "\uF071 ", Pwd: ""},
+		{Case: "Unknown folder custom icon", Expected: "gone", Pwd: "", NotExistIcon: "gone"},
+		{Case: "Windows registry", Expected: "\uE0B1\\Software", Pwd: "HKCU:\\Software"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("hasFolder", tc.Pwd).Return(tc.Exists)
+		values := map[Property]interface{}{
+			Style: Full,
+		}
+		if tc.NotExistIcon != "" {
+			values[NotExistIcon] = tc.NotExistIcon
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.string(), tc.Case)
+	}
+}
+
+func TestFolderSeparatorIconInHome(t *testing.T) {
+	cases := []struct {
+		Style    string
+		Pwd      string
+		Expected string
+	}{
+		{Style: Agnoster, Pwd: "/usr/home/whatever/man", Expected: "~ > .. > man"},
+		{Style: Agnoster, Pwd: "/usr/location/whatever/man", Expected: "usr/../../man"},
+		{Style: AgnosterShort, Pwd: "/usr/home/whatever/man", Expected: "~ > .. > man"},
+		{Style: AgnosterShort, Pwd: "/usr/location/whatever/man", Expected: "usr/../man"},
+		{Style: AgnosterFull, Pwd: "/usr/home/whatever/man", Expected: "~ > whatever > man"},
+		{Style: AgnosterFull, Pwd: "/usr/location/whatever/man", Expected: "usr/location/whatever/man"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("hasFolder", tc.Pwd).Return(true)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					Style:                     tc.Style,
+					FolderSeparatorIconInHome: " > ",
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.string(), tc.Style+" "+tc.Pwd)
+	}
+}
+
+func TestGetLetterPath(t *testing.T) {
+	cases := []struct {
+		Case          string
+		Pwd           string
+		Separator     string
+		PathSeparator string
+		Expected      string
+	}{
+		{Case: "Home", Pwd: "/usr/home", PathSeparator: "/", Expected: "~"},
+		{Case: "Inside home", Pwd: "/usr/home/src/github/foo/bar", Separator: " ", PathSeparator: "/", Expected: "~ s g f bar"},
+		{Case: "Outside home", Pwd: "/usr/local/bin", PathSeparator: "/", Expected: "/u/l/bin"},
+		{Case: "Dotfolder", Pwd: "/usr/home/.config/nvim", PathSeparator: "/", Expected: "~/.c/nvim"},
+		{Case: "Dotfolder base", Pwd: "/usr/home/.config", PathSeparator: "/", Expected: "~/.config"},
+		{Case: "Unicode", Pwd: "/usr/home/\u00E9t\u00E9/\u65E5\u672C\u8A9E/bar", PathSeparator: "/", Expected: "~/\u00E9/\u65E5/bar"},
+		{Case: "Windows", Pwd: "C:\\Users\\posh\\Documents", PathSeparator: "\\", Expected: "C:\\U\\p\\Documents"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return(tc.PathSeparator)
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		values := map[Property]interface{}{}
+		if tc.Separator != "" {
+			values[FolderSeparatorIcon] = tc.Separator
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getLetterPath(), tc.Case)
+	}
+}
+
+func TestDetectionIgnoreFolders(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Pwd      string
+		Patterns []interface{}
+		Ignored  bool
+	}{
+		{Case: "No patterns", Pwd: "/mnt/share/project"},
+		{Case: "Exact match", Pwd: "/mnt/share", Patterns: []interface{}{"/mnt/share"}, Ignored: true},
+		{Case: "Glob match", Pwd: "/mnt/share", Patterns: []interface{}{"/mnt/*"}, Ignored: true},
+		{Case: "Glob match parent", Pwd: "/mnt/share/project/src", Patterns: []interface{}{"/mnt/*"}, Ignored: true},
+		{Case: "Home glob match", Pwd: "/usr/home/network/drive", Patterns: []interface{}{"~/net*"}, Ignored: true},
+		{Case: "No match", Pwd: "/usr/home/projects", Patterns: []interface{}{"/mnt/*", "~/network"}},
+		{Case: "Invalid pattern", Pwd: "/mnt/share", Patterns: []interface{}{"/mnt/[a"}},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("hasFolder", tc.Pwd).Return(true)
+		values := map[Property]interface{}{
+			Style: Folder,
+		}
+		if tc.Patterns != nil {
+			values[DetectionIgnoreFolders] = tc.Patterns
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Ignored, path.ignoreDetection(), tc.Case)
+		_ = path.string()
+		if tc.Ignored {
+			env.AssertNotCalled(t, "hasFolder", tc.Pwd)
+			continue
+		}
+		env.AssertCalled(t, "hasFolder", tc.Pwd)
+	}
+}
+
+func TestDetectionIgnoreFoldersFullPath(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("/")
+	env.On("homeDir", nil).Return("/usr/home")
+	env.On("getcwd", nil).Return("/mnt/share/project")
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				Style:                  Folder,
+				DetectionIgnoreFolders: []interface{}{"/mnt/*"},
+			},
+		},
+	}
+	assert.Equal(t, "/mnt/share/project", path.string())
+}
+
+func TestSubmoduleIcon(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Icon     string
+		Pwd      string
+		DotGit   string
+		Expected string
+	}{
+		{Case: "Submodule root", Icon: "S ", Pwd: "/usr/home/repo/sub", DotGit: "/usr/home/repo/sub/.git", Expected: "S "},
+		{Case: "Inside submodule", Icon: "S ", Pwd: "/usr/home/repo/sub/src", DotGit: "/usr/home/repo/sub/.git", Expected: "S "},
+		{Case: "Worktree", Icon: "S ", Pwd: "/usr/home/worktree", DotGit: "/usr/home/worktree/.git", Expected: ""},
+		{Case: "Normal repo", Icon: "S ", Pwd: "/usr/home/repo/src", Expected: ""},
+		{Case: "Disabled", Pwd: "/usr/home/repo/sub", DotGit: "/usr/home/repo/sub/.git", Expected: ""},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("getFileContent", "/usr/home/repo/sub/.git").Return("gitdir: ../.git/modules/sub\n")
+		env.On("getFileContent", "/usr/home/worktree/.git").Return("gitdir: /usr/home/repo/.git/worktrees/worktree\n")
+		env.On("getFileContent", mock.Anything).Return("")
+		env.On("hasFolder", "/usr/home/repo/.git").Return(true)
+		env.On("hasFolder", mock.Anything).Return(false)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					SubmoduleIcon: tc.Icon,
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getSubmoduleIcon(), tc.Case)
+	}
+}
+
+func TestSubmoduleIconOutsideRepository(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("/")
+	env.On("getcwd", nil).Return("/usr/home")
+	env.On("getFileContent", mock.Anything).Return("")
+	env.On("hasFolder", mock.Anything).Return(false)
+	path := &path{
+		env: env,
+	}
+	assert.False(t, path.inSubmodule())
+}
+
+func TestLowSpaceWarning(t *testing.T) {
+	cases := []struct {
+		Case      string
+		Free      uint64
+		Err       error
+		Threshold float64
+		Template  string
+		Expected  string
+	}{
+		{Case: "Below threshold", Free: 2254857830, Threshold: 5, Expected: " (2.1GB free)"},
+		{Case: "Above threshold", Free: 10 * 1024 * 1024 * 1024, Threshold: 5, Expected: ""},
+		{Case: "Disabled", Free: 1024, Expected: ""},
+		{Case: "Custom template", Free: 1024, Threshold: 1, Template: " \uF0A0 {{ .FreeSpace }}", Expected: " \uF0A0 1.0KB"},
+		{Case: "Unable to read", Err: errors.New("no such file"), Threshold: 5, Expected: ""},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getcwd", nil).Return("/usr/home")
+		env.On("getFreeSpace", "/usr/home").Return(tc.Free, tc.Err)
+		values := map[Property]interface{}{
+			LowSpaceThreshold: tc.Threshold,
+		}
+		if tc.Template != "" {
+			values[LowSpaceTemplate] = tc.Template
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getLowSpaceWarning(), tc.Case)
+	}
+}
+
+func TestLowSpaceWarningAppendedToPath(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("/")
+	env.On("homeDir", nil).Return("/usr/home")
+	env.On("getcwd", nil).Return("/usr/home/projects")
+	env.On("hasFolder", "/usr/home/projects").Return(true)
+	env.On("getFreeSpace", "/usr/home/projects").Return(uint64(1024*1024), nil)
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				Style:             Folder,
+				LowSpaceThreshold: float64(1),
+			},
+		},
+	}
+	assert.Equal(t, "projects (1.0MB free)", path.string())
+}
+
+func TestGetFullPathRelativeTo(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Pwd      string
+		Roots    []interface{}
+		Icon     string
+		Expected string
+	}{
+		{Case: "GOPATH", Pwd: "/go/src/github.com/org/repo/pkg", Roots: []interface{}{"$GOPATH/src/github.com"}, Expected: ".../org/repo/pkg"},
+		{Case: "GOPATH braces", Pwd: "/go/src/github.com/org/repo", Roots: []interface{}{"${GOPATH}/src/github.com/"}, Expected: ".../org/repo"},
+		{Case: "Root itself", Pwd: "/go/src/github.com", Roots: []interface{}{"$GOPATH/src/github.com"}, Expected: "..."},
+		{Case: "First matching root", Pwd: "/work/project/src", Roots: []interface{}{"$GOPATH/src", "$WORKSPACE", "/work"}, Icon: "W", Expected: "W/src"},
+		{Case: "Unset variable", Pwd: "/usr/local/bin", Roots: []interface{}{"$UNSET"}, Expected: "/usr/local/bin"},
+		{Case: "Partial folder name", Pwd: "/go/src/github.company/repo", Roots: []interface{}{"$GOPATH/src/github.com"}, Expected: "/go/src/github.company/repo"},
+		{Case: "No match", Pwd: "/usr/home/projects", Roots: []interface{}{"$GOPATH/src"}, Expected: "~/projects"},
+		{Case: "No roots", Pwd: "/go/src/github.com/org", Expected: "/go/src/github.com/org"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("getenv", "GOPATH").Return("/go")
+		env.On("getenv", "WORKSPACE").Return("/work/project")
+		env.On("getenv", "UNSET").Return("")
+		values := map[Property]interface{}{}
+		if tc.Roots != nil {
+			values[RelativeTo] = tc.Roots
+		}
+		if tc.Icon != "" {
+			values[RelativeToIcon] = tc.Icon
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getFullPath(), tc.Case)
+	}
+}
+
+func TestGitIgnoredPath(t *testing.T) {
+	cases := []struct {
+		Case           string
+		DisplayIgnored bool
+		HasGit         bool
+		CheckIgnore    string
+		Expected       string
+	}{
+		{Case: "Ignored", DisplayIgnored: true, HasGit: true, CheckIgnore: "/usr/home/project/build", Expected: "\uF070 ~/project/<#ff0000>build</>"},
+		{Case: "Not ignored", DisplayIgnored: true, HasGit: true, Expected: "~/project/build"},
+		{Case: "Disabled", HasGit: true, CheckIgnore: "/usr/home/project/build", Expected: "~/project/build"},
+		{Case: "No git", DisplayIgnored: true, CheckIgnore: "/usr/home/project/build", Expected: "~/project/build"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return("/usr/home/project/build")
+		env.On("hasFolder", "/usr/home/project/build").Return(true)
+		env.On("hasCommand", "git").Return(tc.HasGit)
+		env.On("runCommand", "git", []string{"check-ignore", "/usr/home/project/build"}).Return(tc.CheckIgnore, nil)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					Style:             Full,
+					DisplayIgnored:    tc.DisplayIgnored,
+					IgnoredForeground: "#ff0000",
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.string(), tc.Case)
+	}
+}
+
+func TestGetPermissions(t *testing.T) {
+	cases := []struct {
+		Case      string
+		GOOS      string
+		Mode      os.FileMode
+		Err       error
+		Style     string
+		OwnerOnly bool
+		Disabled  bool
+		Expected  string
+	}{
+		{Case: "Owner rwx", Mode: os.ModeDir | 0755, OwnerOnly: true, Expected: " rwx"},
+		{Case: "Owner read-only", Mode: os.ModeDir | 0555, OwnerOnly: true, Expected: " r-x"},
+		{Case: "All rwx", Mode: os.ModeDir | 0750, Expected: " rwxr-x---"},
+		{Case: "Owner octal", Mode: os.ModeDir | 0644, Style: Octal, OwnerOnly: true, Expected: " 6"},
+		{Case: "All octal", Mode: os.ModeDir | 0755, Style: Octal, Expected: " 755"},
+		{Case: "All octal leading zero", Mode: os.ModeDir | 0070, Style: Octal, Expected: " 070"},
+		{Case: "Disabled", Mode: os.ModeDir | 0755, Disabled: true, Expected: ""},
+		{Case: "Unable to stat", Err: errors.New("no such file"), Expected: ""},
+		{Case: "Windows writable", GOOS: windowsPlatform, Mode: os.ModeDir | 0777, Expected: ""},
+		{Case: "Windows read-only", GOOS: windowsPlatform, Mode: os.ModeDir | 0555, Expected: " \uF023"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getcwd", nil).Return("/usr/home")
+		env.On("getRuntimeGOOS", nil).Return(tc.GOOS)
+		env.On("getFileMode", "/usr/home").Return(tc.Mode, tc.Err)
+		values := map[Property]interface{}{
+			DisplayPermissions: !tc.Disabled,
+			OwnerOnly:          tc.OwnerOnly,
+		}
+		if tc.Style != "" {
+			values[PermissionsStyle] = tc.Style
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getPermissions(), tc.Case)
+	}
+}
+
+func TestCrossesMountBoundary(t *testing.T) {
+	cases := []struct {
+		Case         string
+		GOOS         string
+		Pwd          string
+		Home         string
+		Device       uint64
+		ParentDevice uint64
+		Err          error
+		Expected     bool
+	}{
+		{Case: "Same device", Pwd: "/mnt/share", Device: 2049, ParentDevice: 2049},
+		{Case: "Mount point", Pwd: "/mnt/share", Device: 51, ParentDevice: 2049, Expected: true},
+		{Case: "Root", Pwd: "/", Device: 2049, ParentDevice: 51},
+		{Case: "Unable to stat", Pwd: "/mnt/share", Err: errors.New("no such file")},
+		{Case: "Windows same drive", GOOS: windowsPlatform, Pwd: "C:\\Users\\jan\\code", Home: "C:\\Users\\jan"},
+		{Case: "Windows other drive", GOOS: windowsPlatform, Pwd: "D:\\code", Home: "C:\\Users\\jan", Expected: true},
+		{Case: "Windows drive casing", GOOS: windowsPlatform, Pwd: "c:\\code", Home: "C:\\Users\\jan"},
+		{Case: "Windows UNC share", GOOS: windowsPlatform, Pwd: "\\\\server\\share\\code", Home: "C:\\Users\\jan", Expected: true},
+		{
+			Case:     "Windows PowerShell provider",
+			GOOS:     windowsPlatform,
+			Pwd:      "Microsoft.PowerShell.Core\\FileSystem::\\\\server\\share",
+			Home:     "\\\\server\\share\\jan",
+			Expected: false,
+		},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("homeDir", nil).Return(tc.Home)
+		env.On("getRuntimeGOOS", nil).Return(tc.GOOS)
+		env.On("getDeviceID", tc.Pwd).Return(tc.Device, tc.Err)
+		env.On("getDeviceID", "/mnt").Return(tc.ParentDevice, tc.Err)
+		env.On("getDeviceID", "/").Return(tc.ParentDevice, tc.Err)
+		path := &path{
+			env:   env,
+			props: &properties{},
+		}
+		assert.Equal(t, tc.Expected, path.crossesMountBoundary(), tc.Case)
+	}
+}
+
+func TestMountBoundaryIcon(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getcwd", nil).Return("/mnt/share")
+	env.On("getRuntimeGOOS", nil).Return("linux")
+	env.On("getDeviceID", "/mnt/share").Return(uint64(51), nil)
+	env.On("getDeviceID", "/mnt").Return(uint64(2049), nil)
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				DisplayMountBoundary: true,
+				MountBoundaryIcon:    "M ",
+			},
+		},
+	}
+	assert.Equal(t, "M ", path.getMountBoundaryIcon())
+	path.props.values[DisplayMountBoundary] = false
+	assert.Equal(t, "", path.getMountBoundaryIcon())
+}
+
+func TestGetAgnosterLeftPath(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Pwd      string
+		MaxDepth interface{}
+		Expected string
+	}{
+		{Case: "Deep", Pwd: "/usr/home/src/github/oh-my-posh/src", MaxDepth: float64(2), Expected: "~ > src > github > .. > .."},
+		{Case: "Default depth", Pwd: "/usr/home/src/github/oh-my-posh", Expected: "~ > src > .. > .."},
+		{Case: "Shallow", Pwd: "/usr/home/src/github", MaxDepth: float64(3), Expected: "~ > src > github"},
+		{Case: "Exact depth", Pwd: "/usr/home/src/github", MaxDepth: float64(2), Expected: "~ > src > github"},
+		{Case: "Outside home", Pwd: "/usr/location/whatever/man", Expected: "usr > location > .. > .."},
+		{Case: "Home", Pwd: "/usr/home", Expected: "~"},
+		{Case: "Root", Pwd: "/", Expected: ""},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		props := &properties{
+			values: map[Property]interface{}{
+				FolderSeparatorIcon: " > ",
+				Style:               AgnosterLeft,
+			},
+		}
+		if tc.MaxDepth != nil {
+			props.values[MaxDepth] = tc.MaxDepth
+		}
+		path := &path{
+			env:   env,
+			props: props,
+		}
+		assert.Equal(t, tc.Expected, path.getStyledPath(), tc.Case)
+	}
+}
+
+func TestGetAgnosterLeftPathWindows(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("\\")
+	env.On("homeDir", nil).Return(homeBillWindows)
+	env.On("getcwd", nil).Return("C:\\Program Files\\Go\\src\\runtime")
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				FolderSeparatorIcon: " > ",
+				BaseForeground:      "#ff0000",
+			},
+		},
+	}
+	assert.Equal(t, "C: > Program Files > .. > .. > <#ff0000>..</>", path.getAgnosterLeftPath())
+}
+
+func TestGetPathOutputPathTemplates(t *testing.T) {
+	infra := map[string]interface{}{"match": "~/infra", "template": "\uF270 {{ .Env.AWS_PROFILE }} {{ .Path }}"}
+	cases := []struct {
+		Case      string
+		Pwd       string
+		Templates []interface{}
+		Expected  string
+	}{
+		{Case: "Match", Pwd: "/usr/home/infra", Templates: []interface{}{infra}, Expected: "\uF270 prod infra"},
+		{Case: "Match parent", Pwd: "/usr/home/infra/network", Templates: []interface{}{infra}, Expected: "\uF270 prod network"},
+		{Case: "No match", Pwd: "/usr/home/code", Templates: []interface{}{infra}, Expected: "code"},
+		{
+			Case: "First match wins",
+			Pwd:  "/usr/home/infra/network",
+			Templates: []interface{}{
+				map[string]interface{}{"match": "~/infra/network", "template": "network {{ .Folder }}"},
+				infra,
+			},
+			Expected: "network network",
+		},
+		{
+			Case: "Glob",
+			Pwd:  "/usr/home/infra/network",
+			Templates: []interface{}{
+				map[string]interface{}{"match": "~/*/network", "template": "{{ .Pwd }}"},
+				infra,
+			},
+			Expected: "~/infra/network",
+		},
+		{Case: "Invalid entries", Pwd: "/usr/home/infra", Templates: []interface{}{"~/infra", map[string]interface{}{"template": "empty"}}, Expected: "infra"},
+		{Case: "No templates", Pwd: "/usr/home/infra", Expected: "infra"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("environ", nil).Return(map[string]string{"AWS_PROFILE": "prod"})
+		props := &properties{
+			values: map[Property]interface{}{
+				Style: Folder,
+			},
+		}
+		if tc.Templates != nil {
+			props.values[PathTemplates] = tc.Templates
+		}
+		path := &path{
+			env:   env,
+			props: props,
+		}
+		assert.Equal(t, tc.Expected, path.getPathOutput(), tc.Case)
+	}
+}
+
+func TestGetEntryCount(t *testing.T) {
+	cases := []struct {
+		Case          string
+		Entries       []string
+		Err           error
+		IncludeHidden bool
+		MaxEntries    interface{}
+		Template      interface{}
+		Expected      string
+	}{
+		{Case: "Visible entries", Entries: []string{"src", "go.mod", "README.md"}, Expected: " (3)"},
+		{Case: "Hidden excluded", Entries: []string{".git", "src", ".gitignore", "go.mod"}, Expected: " (2)"},
+		{Case: "Hidden included", Entries: []string{".git", "src", ".gitignore", "go.mod"}, IncludeHidden: true, Expected: " (4)"},
+		{Case: "Empty", Entries: []string{}, Expected: " (0)"},
+		{Case: "Only hidden", Entries: []string{".git"}, Expected: " (0)"},
+		{Case: "Capped", Entries: []string{"a", "b", "c"}, MaxEntries: float64(2), Expected: " (2+)"},
+		{Case: "Template", Entries: []string{"a", "b"}, Template: " {{ .Entries }} entries", Expected: " 2 entries"},
+		{Case: "Error", Entries: []string{}, Err: errors.New("permission denied"), Expected: ""},
+	}
+	for _, tc := range cases {
+		maxEntries := 1000
+		values := map[Property]interface{}{
+			DisplayEntryCount: true,
+			IncludeHidden:     tc.IncludeHidden,
+		}
+		if tc.MaxEntries != nil {
+			values[MaxEntries] = tc.MaxEntries
+			maxEntries = int(tc.MaxEntries.(float64))
+		}
+		if tc.Template != nil {
+			values[EntryCountTemplate] = tc.Template
+		}
+		env := new(MockedEnvironment)
+		env.On("getcwd", nil).Return("/usr/home/code")
+		env.On("getDirEntries", "/usr/home/code", maxEntries+1).Return(tc.Entries, tc.Err)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getEntryCount(), tc.Case)
+	}
+}
+
+func TestGetEntryCountDisabled(t *testing.T) {
+	env := new(MockedEnvironment)
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{},
+		},
+	}
+	assert.Empty(t, path.getEntryCount())
+	env.AssertNotCalled(t, "getDirEntries", mock.Anything, mock.Anything)
+}
+
+func TestGetStyledPathRootIcon(t *testing.T) {
+	cases := []struct {
+		Case      string
+		Pwd       string
+		Separator string
+		Home      string
+		Style     string
+		RootIcon  interface{}
+		Expected  string
+	}{
+		{Case: "Absolute root", Pwd: "/etc/nginx", Style: AgnosterFull, RootIcon: "/", Expected: "/ > etc > nginx"},
+		{Case: "Absolute root agnoster", Pwd: "/etc/nginx/sites", Style: Agnoster, RootIcon: "/", Expected: "/ > etc > .. > sites"},
+		{Case: "Filesystem root", Pwd: "/", Style: Agnoster, RootIcon: "/", Expected: "/"},
+		{Case: "Home", Pwd: "/usr/home/code", Style: AgnosterFull, RootIcon: "/", Expected: "~ > code"},
+		{Case: "No root icon", Pwd: "/etc/nginx", Style: AgnosterFull, Expected: "etc > nginx"},
+		{Case: "Other style", Pwd: "/etc/nginx", Style: Full, RootIcon: "/", Expected: "/etc/nginx"},
+		{Case: "Letter style", Pwd: "/etc/nginx", Style: Letter, RootIcon: "/", Expected: " > e > nginx"},
+		{Case: "Agnoster short", Pwd: "/etc/nginx", Style: AgnosterShort, RootIcon: "/", Expected: "/ > etc > nginx"},
+		{Case: "Agnoster left", Pwd: "/etc/nginx/sites", Style: AgnosterLeft, RootIcon: "/", Expected: "/ > etc > nginx > .."},
+		{Case: "Registry", Pwd: "HKLM:\\SOFTWARE\\Microsoft", Separator: "\\", Home: homeBillWindows, Style: AgnosterFull, RootIcon: "/", Expected: "\uE0B1 > SOFTWARE > Microsoft"},
+		{Case: "Windows drive", Pwd: "C:\\Windows\\System32", Separator: "\\", Home: homeBillWindows, Style: AgnosterFull, RootIcon: "/", Expected: "C: > Windows > System32"},
+	}
+	for _, tc := range cases {
+		separator := tc.Separator
+		if separator == "" {
+			separator = "/"
+		}
+		home := tc.Home
+		if home == "" {
+			home = "/usr/home"
+		}
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return(separator)
+		env.On("homeDir", nil).Return(home)
+		env.On("getcwd", nil).Return(tc.Pwd)
+		values := map[Property]interface{}{
+			FolderSeparatorIcon: " > ",
+			Style:               tc.Style,
+		}
+		if tc.RootIcon != nil {
+			values[FilesystemRootIcon] = tc.RootIcon
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getStyledPath(), tc.Case)
+	}
+}
+
+func TestGetHash(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Pwd      string
+		Length   interface{}
+		Template interface{}
+		Expected string
+	}{
+		{Case: "Default length", Pwd: "/usr/home/code", Expected: " #ca59d87d"},
+		{Case: "Custom length", Pwd: "/usr/home/code", Length: float64(4), Expected: " #ca59"},
+		{Case: "Length beyond hash", Pwd: "/usr/home/code", Length: float64(40), Expected: " #ca59d87dc6d4603e"},
+		{Case: "Invalid length", Pwd: "/usr/home/code", Length: float64(0), Expected: " #ca59d87d"},
+		{Case: "Template", Pwd: "/usr/home/code", Template: " [{{ .Hash }}]", Expected: " [ca59d87d]"},
+	}
+	for _, tc := range cases {
+		values := map[Property]interface{}{
+			DisplayHash: true,
+		}
+		if tc.Length != nil {
+			values[HashLength] = tc.Length
+		}
+		if tc.Template != nil {
+			values[HashTemplate] = tc.Template
+		}
+		env := new(MockedEnvironment)
+		env.On("getcwd", nil).Return(tc.Pwd)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: values,
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getHash(), tc.Case)
+	}
+}
+
+func TestGetHashStability(t *testing.T) {
+	hashFor := func(pwd string) string {
+		env := new(MockedEnvironment)
+		env.On("getcwd", nil).Return(pwd)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayHash: true,
+				},
+			},
+		}
+		return path.getHash()
+	}
+	assert.Equal(t, hashFor("/usr/home/code"), hashFor("/usr/home/code"), "the same path yields the same hash")
+	assert.NotEqual(t, hashFor("/usr/home/code"), hashFor("/usr/home/docs"), "different paths yield different hashes")
+}
+
+func TestGetHashDisabled(t *testing.T) {
+	path := &path{
+		env: new(MockedEnvironment),
+		props: &properties{
+			values: map[Property]interface{}{},
+		},
+	}
+	assert.Equal(t, "", path.getHash())
+}
+
+func TestCollapseRepeats(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Pwd      string
+		Style    string
+		Expected string
+	}{
+		{Case: "Consecutive", Pwd: "/usr/location/app/app/src", Style: Full, Expected: "/usr/location/app\u00D72/src"},
+		{Case: "Triple", Pwd: "/usr/location/src/src/src/main", Style: Full, Expected: "/usr/location/src\u00D73/main"},
+		{Case: "Non-adjacent", Pwd: "/usr/location/src/main/src", Style: Full, Expected: "/usr/location/src/main/src"},
+		{Case: "Base", Pwd: "/usr/location/app/src/src", Style: Full, Expected: "/usr/location/app/src\u00D72"},
+		{Case: "Home", Pwd: "/usr/home/app/app", Style: Full, Expected: "~/app\u00D72"},
+		{Case: "Agnoster full", Pwd: "/usr/location/app/app/src", Style: AgnosterFull, Expected: "usr > location > app\u00D72 > src"},
+		{Case: "Agnoster base", Pwd: "/usr/location/src/src", Style: Agnoster, Expected: "usr > .. > src\u00D72"},
+		{Case: "Folder", Pwd: "/usr/location/src/src", Style: Folder, Expected: "src\u00D72"},
+		{Case: "Letter", Pwd: "/usr/location/app/app/src", Style: Letter, Expected: " > u > l > a > src"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					FolderSeparatorIcon: " > ",
+					Style:               tc.Style,
+					CollapseRepeats:     true,
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getStyledPath(), tc.Case)
+	}
+}
+
+func TestCollapseRepeatsDisabled(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("/")
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{},
+		},
+	}
+	assert.Equal(t, "/usr/app/app", path.collapseRepeats("/usr/app/app"))
+}
+
+func TestCollapseRepeatsIcon(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("\\")
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				CollapseRepeats: true,
+				RepeatIcon:      " x",
+			},
+		},
+	}
+	assert.Equal(t, "C:\\app x2\\src", path.collapseRepeats("C:\\app\\app\\src"))
+}
+
+func TestGetPwdEnvRoots(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Pwd      string
+		Expected string
+	}{
+		{Case: "Matching root", Pwd: "/work/projects/oh-my-posh", Expected: "$PROJECTS/oh-my-posh"},
+		{Case: "Root itself", Pwd: "/work/projects", Expected: "$PROJECTS"},
+		{Case: "Longest match", Pwd: "/work/projects/go/src/app", Expected: "$GOSRC/app"},
+		{Case: "Longer than home", Pwd: "/usr/home/notes/today", Expected: "$NOTES/today"},
+		{Case: "Home", Pwd: "/usr/home/code", Expected: "~/code"},
+		{Case: "Unset variable", Pwd: "/opt/tools", Expected: "/opt/tools"},
+		{Case: "No match", Pwd: "/var/log", Expected: "/var/log"},
+		{Case: "Sibling sharing the prefix", Pwd: "/work/projectsfoo/app", Expected: "/work/projectsfoo/app"},
+		{Case: "Sibling of a nested root", Pwd: "/work/projects/go/srcfoo", Expected: "$PROJECTS/go/srcfoo"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("getenv", "PROJECTS").Return("/work/projects")
+		env.On("getenv", "GOSRC").Return("/work/projects/go/src/")
+		env.On("getenv", "NOTES").Return("/usr/home/notes")
+		env.On("getenv", "TOOLS").Return("")
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					EnvRoots: []string{"PROJECTS", "GOSRC", "NOTES", "TOOLS"},
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getPwd(), tc.Case)
+	}
+}
+
+func TestGetVCSIcon(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Markers  []string
+		Icons    map[string]string
+		Expected string
+	}{
+		{Case: "Git", Markers: []string{"/usr/home/repo/.git"}, Expected: "git"},
+		{Case: "Mercurial", Markers: []string{"/usr/home/repo/.hg"}, Expected: "hg"},
+		{Case: "Subversion", Markers: []string{"/usr/home/repo/.svn"}, Expected: "svn"},
+		{Case: "Jujutsu", Markers: []string{"/usr/home/repo/.jj"}, Expected: "jj"},
+		{Case: "In the working directory", Markers: []string{"/usr/home/repo/src/.hg"}, Expected: "hg"},
+		{Case: "Nested, closest wins", Markers: []string{"/usr/home/.git", "/usr/home/repo/.hg"}, Expected: "hg"},
+		{Case: "Colocated jj and git", Markers: []string{"/usr/home/repo/.git", "/usr/home/repo/.jj"}, Expected: "jj"},
+		{Case: "Disabled icon", Markers: []string{"/usr/home/.git", "/usr/home/repo/.jj"}, Icons: map[string]string{"jj": ""}, Expected: "git"},
+		{Case: "Filesystem root", Markers: []string{"/.svn"}, Expected: "svn"},
+		{Case: "No repository", Expected: ""},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("getcwd", nil).Return("/usr/home/repo/src")
+		for _, marker := range tc.Markers {
+			env.On("hasFolder", marker).Return(true)
+		}
+		env.On("hasFolder", mock.Anything).Return(false)
+		icons := map[string]string{"git": "git", "hg": "hg", "svn": "svn", "jj": "jj"}
+		for name, icon := range tc.Icons {
+			icons[name] = icon
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					DisplayVCSIcon: true,
+					VCSIcons:       icons,
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getVCSIcon(), tc.Case)
+	}
+}
+
+func TestGetVCSIconDefaults(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("/")
+	env.On("getcwd", nil).Return("/usr/home/repo")
+	env.On("hasFolder", "/usr/home/repo/.git").Return(true)
+	env.On("hasFolder", mock.Anything).Return(false)
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				DisplayVCSIcon: true,
+				VCSIcons:       map[string]string{"hg": "hg"},
+			},
+		},
+	}
+	assert.Equal(t, "\uE702 ", path.getVCSIcon(), "unset icons keep their default")
+}
+
+func TestForwardSlashes(t *testing.T) {
+	cases := []struct {
+		Case           string
+		Pwd            string
+		Style          string
+		ForwardSlashes bool
+		Expected       string
+	}{
+		{Case: "Drive full", Pwd: "C:\\Users\\jan\\projects", Style: Full, ForwardSlashes: true, Expected: "C:/Users/jan/projects"},
+		{Case: "Drive short", Pwd: "C:\\Users\\jan\\projects", Style: Short, ForwardSlashes: true, Expected: "C:/Users/jan/projects"},
+		{Case: "Drive root", Pwd: "C:\\", Style: Full, ForwardSlashes: true, Expected: "C:/"},
+		{Case: "Drive disabled", Pwd: "C:\\Users\\jan\\projects", Style: Full, Expected: "C:\\Users\\jan\\projects"},
+		{Case: "UNC full", Pwd: "\\\\server\\share\\projects", Style: Full, ForwardSlashes: true, Expected: "//server/share/projects"},
+		{Case: "UNC disabled", Pwd: "\\\\server\\share\\projects", Style: Full, Expected: "\\\\server\\share\\projects"},
+		{Case: "Home", Pwd: "C:\\Users\\posh\\projects", Style: Full, ForwardSlashes: true, Expected: "~/projects"},
+		{Case: "Agnoster keeps its separator", Pwd: "C:\\Users\\jan\\projects", Style: Agnoster, ForwardSlashes: true, Expected: "C: > .. > .. > projects"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("\\")
+		env.On("homeDir", nil).Return("C:\\Users\\posh")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					Style:               tc.Style,
+					ForwardSlashes:      tc.ForwardSlashes,
+					FolderSeparatorIcon: " > ",
+					FolderIcon:          "..",
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getStyledPath(), tc.Case)
+	}
+}
+
+func TestGetFullPathTrailingSeparator(t *testing.T) {
+	cases := []struct {
+		Case              string
+		Pwd               string
+		PathSeparator     string
+		TrailingSeparator bool
+		ForwardSlashes    bool
+		FolderSeparator   string
+		Expected          string
+	}{
+		{Case: "Folder", Pwd: "/usr/home/projects", PathSeparator: "/", TrailingSeparator: true, Expected: "~/projects/"},
+		{Case: "Home", Pwd: "/usr/home", PathSeparator: "/", TrailingSeparator: true, Expected: "~/"},
+		{Case: "Root", Pwd: "/", PathSeparator: "/", TrailingSeparator: true, Expected: "/"},
+		{Case: "Existing trailing separator", Pwd: "/usr/projects/", PathSeparator: "/", TrailingSeparator: true, Expected: "/usr/projects/"},
+		{Case: "Disabled", Pwd: "/usr/home/projects", PathSeparator: "/", Expected: "~/projects"},
+		{Case: "Separator icon", Pwd: "/usr/projects", PathSeparator: "/", TrailingSeparator: true, FolderSeparator: " > ", Expected: "/usr/projects > "},
+		{Case: "Windows drive", Pwd: "C:\\projects", PathSeparator: "\\", TrailingSeparator: true, Expected: "C:\\projects\\"},
+		{Case: "Windows drive root", Pwd: "C:\\", PathSeparator: "\\", TrailingSeparator: true, Expected: "C:\\"},
+		{Case: "Forward slashes", Pwd: "C:\\projects", PathSeparator: "\\", TrailingSeparator: true, ForwardSlashes: true, Expected: "C:/projects/"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return(tc.PathSeparator)
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		props := &properties{
+			values: map[Property]interface{}{
+				TrailingSeparator: tc.TrailingSeparator,
+				ForwardSlashes:    tc.ForwardSlashes,
+			},
+		}
+		if tc.FolderSeparator != "" {
+			props.values[FolderSeparatorIcon] = tc.FolderSeparator
+		}
+		path := &path{
+			env:   env,
+			props: props,
+		}
+		assert.Equal(t, tc.Expected, path.getFullPath(), tc.Case)
+	}
+}
+
+func TestGetPwdAnchor(t *testing.T) {
+	cases := []struct {
+		Case       string
+		Pwd        string
+		AnchorEnv  string
+		Anchor     string
+		AnchorIcon interface{}
+		Style      string
+		Expected   string
+	}{
+		{Case: "Anchored", Pwd: "/work/project/src/pkg", AnchorEnv: "POSH_ANCHOR", Anchor: "/work/project", Style: Full, Expected: "@/src/pkg"},
+		{Case: "Anchor itself", Pwd: "/work/project", AnchorEnv: "POSH_ANCHOR", Anchor: "/work/project/", Style: Full, Expected: "@"},
+		{Case: "Anchored agnoster", Pwd: "/work/project/src/pkg", AnchorEnv: "POSH_ANCHOR", Anchor: "/work/project", Style: Agnoster, Expected: "@ > .. > pkg"},
+		{Case: "Anchored folder", Pwd: "/work/project/src", AnchorEnv: "POSH_ANCHOR", Anchor: "/work/project", Style: Folder, Expected: "src"},
+		{Case: "Outside of the anchor", Pwd: "/work/projects", AnchorEnv: "POSH_ANCHOR", Anchor: "/work/project", Style: Full, Expected: "/work/projects"},
+		{Case: "Unanchored", Pwd: "/work/project/src", AnchorEnv: "POSH_ANCHOR", Style: Full, Expected: "/work/project/src"},
+		{Case: "Disabled", Pwd: "/work/project/src", Style: Full, Expected: "/work/project/src"},
+		{Case: "Inside home", Pwd: "/usr/home/project/src", AnchorEnv: "POSH_ANCHOR", Anchor: "/usr/home/project", Style: Full, Expected: "@/src"},
+	}
+	// without an anchor icon, the anchor itself renders an empty path, the folder style keeps its "."
+	for _, style := range []string{Agnoster, AgnosterFull, AgnosterShort, AgnosterLeft, Full, Folder, Letter} {
+		expected := ""
+		if style == Folder {
+			expected = "."
+		}
+		cases = append(cases, struct {
+			Case       string
+			Pwd        string
+			AnchorEnv  string
+			Anchor     string
+			AnchorIcon interface{}
+			Style      string
+			Expected   string
+		}{Case: "Anchor itself without icon " + style, Pwd: "/work/project", AnchorEnv: "POSH_ANCHOR", Anchor: "/work/project", AnchorIcon: "", Style: style, Expected: expected})
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getPathSeperator", nil).Return("/")
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getcwd", nil).Return(tc.Pwd)
+		env.On("getenv", "POSH_ANCHOR").Return(tc.Anchor)
+		anchorIcon := tc.AnchorIcon
+		if anchorIcon == nil {
+			anchorIcon = "@"
+		}
+		path := &path{
+			env: env,
+			props: &properties{
+				values: map[Property]interface{}{
+					Style:               tc.Style,
+					AnchorEnv:           tc.AnchorEnv,
+					AnchorIcon:          anchorIcon,
+					FolderSeparatorIcon: " > ",
+					FolderIcon:          "..",
+				},
+			},
+		}
+		assert.Equal(t, tc.Expected, path.getStyledPath(), tc.Case)
+	}
+}
+
+func TestGetFullPathAnchorPrecedence(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getPathSeperator", nil).Return("/")
+	env.On("homeDir", nil).Return("/usr/home")
+	env.On("getcwd", nil).Return("/go/src/github.com/org/repo")
+	env.On("getenv", "POSH_ANCHOR").Return("/go/src/github.com/org")
+	env.On("getenv", "GOPATH").Return("/go")
+	path := &path{
+		env: env,
+		props: &properties{
+			values: map[Property]interface{}{
+				AnchorEnv:  "POSH_ANCHOR",
+				AnchorIcon: "@",
+				RelativeTo: []interface{}{"$GOPATH/src"},
+			},
+		},
+	}
+	assert.Equal(t, "@/repo", path.getFullPath())
+}
@@ -18,7 +18,8 @@ func bootStrapPythonTest(args *pythonArgs) *python {
 	env := new(MockedEnvironment)
 	env.On("hasCommand", "python").Return(true)
 	env.On("runCommand", "python", []string{"--version"}).Return("Python 3.8.4", nil)
-	env.On("hasFiles", "*.py").Return(true)
+	env.On("hasFiles", "*.py", true).Return(true)
+	env.On("getRuntimeGOOS", nil).Return("linux")
 	env.On("getenv", "VIRTUAL_ENV").Return(args.virtualEnvName)
 	env.On("getenv", "CONDA_ENV_PATH").Return(args.condaEnvName)
 	env.On("getenv", "CONDA_DEFAULT_ENV").Return(args.condaDefaultName)
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+type sysinfo struct {
+	props *properties
+	env   environmentInfo
+	// CPU is the usage of all cores combined in percent
+	CPU float64
+	// PerCore is the usage of every core in percent
+	PerCore []float64
+}
+
+const (
+	procStat          = "/proc/stat"
+	cpuSampleCacheKey = "sysinfo_cpu"
+)
+
+// cpuSample holds the cumulative time a cpu spent in total and idle since boot
+type cpuSample struct {
+	total float64
+	idle  float64
+}
+
+func (s *sysinfo) enabled() bool {
+	currentTotal, currentCores := s.getSamples()
+	if len(currentCores) == 0 {
+		return false
+	}
+	// the usage is the difference between two samples, the previous one
+	// is taken from the cache and compared to the current one.
+	// Without a previous sample, the usage since boot is displayed
+	previous, _, _ := s.env.cache().get(cpuSampleCacheKey)
+	s.env.cache().set(cpuSampleCacheKey, formatSamples(currentTotal, currentCores))
+	previousTotal, previousCores := parseSamples(previous)
+	if len(previousCores) != len(currentCores) {
+		previousTotal = cpuSample{}
+		previousCores = make([]cpuSample, len(currentCores))
+	}
+	s.CPU = cpuUsage(previousTotal, currentTotal)
+	s.PerCore = make([]float64, len(currentCores))
+	for i, core := range currentCores {
+		s.PerCore[i] = cpuUsage(previousCores[i], core)
+	}
+	return true
+}
+
+// getSamples reads the combined and per core cpu times, from /proc/stat on Linux
+// and host_processor_info on macOS
+func (s *sysinfo) getSamples() (cpuSample, []cpuSample) {
+	if s.env.getRuntimeGOOS() != darwinPlatform {
+		return parseProcStat(s.env.getFileContent(procStat))
+	}
+	times, err := s.env.getCPUTimes()
+	if err != nil {
+		return cpuSample{}, nil
+	}
+	var total cpuSample
+	cores := make([]cpuSample, len(times))
+	for i, stat := range times {
+		cores[i] = cpuSample{total: stat.Total(), idle: stat.Idle + stat.Iowait}
+		total.total += cores[i].total
+		total.idle += cores[i].idle
+	}
+	return total, cores
+}
+
+func (s *sysinfo) string() string {
+	template := &textTemplate{
+		Template: s.props.getString(SegmentTemplate, "{{ printf \"%.0f\" .CPU }}%"),
+		Context:  s,
+	}
+	return template.render()
+}
+
+// defaultPlatforms restricts the segment to the platforms it can read the CPU times on
+func (s *sysinfo) defaultPlatforms() []string {
+	return []string{linuxPlatform, darwinPlatform}
+}
+
+func (s *sysinfo) init(props *properties, env environmentInfo) {
+	s.props = props
+	s.env = env
+}
+
+// parseProcStat returns the combined and per core samples of the cpu lines in /proc/stat:
+// cpu0 user nice system idle iowait irq softirq steal guest guest_nice
+func parseProcStat(content string) (cpuSample, []cpuSample) {
+	var total cpuSample
+	var cores []cpuSample
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		var sample cpuSample
+		// guest and guest_nice are already part of user and nice
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				continue
+			}
+			sample.total += value
+			// idle and iowait
+			if i == 3 || i == 4 {
+				sample.idle += value
+			}
+		}
+		if fields[0] == "cpu" {
+			total = sample
+			continue
+		}
+		cores = append(cores, sample)
+	}
+	return total, cores
+}
+
+// formatSamples stores the samples as "total idle" lines, the combined one first
+func formatSamples(total cpuSample, cores []cpuSample) string {
+	lines := make([]string, 0, len(cores)+1)
+	for _, sample := range append([]cpuSample{total}, cores...) {
+		lines = append(lines, strconv.FormatFloat(sample.total, 'f', -1, 64)+" "+strconv.FormatFloat(sample.idle, 'f', -1, 64))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSamples reads the samples stored by formatSamples, anything else results in no samples
+func parseSamples(content string) (cpuSample, []cpuSample) {
+	var samples []cpuSample
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return cpuSample{}, nil
+		}
+		total, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return cpuSample{}, nil
+		}
+		idle, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return cpuSample{}, nil
+		}
+		samples = append(samples, cpuSample{total: total, idle: idle})
+	}
+	if len(samples) == 0 {
+		return cpuSample{}, nil
+	}
+	return samples[0], samples[1:]
+}
+
+// cpuUsage returns the percentage of non idle time between two samples
+func cpuUsage(previous, current cpuSample) float64 {
+	total := current.total - previous.total
+	if total <= 0 {
+		return 0
+	}
+	idle := current.idle - previous.idle
+	return (total - idle) / total * 100
+}
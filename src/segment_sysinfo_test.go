@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	procStatFirst = `cpu  300 0 100 500 100 0 0 0 0 0
+cpu0 100 0 50 300 50 0 0 0 0 0
+cpu1 200 0 50 200 50 0 0 0 0 0
+intr 1234 0 0
+ctxt 5678`
+	procStatSecond = `cpu  500 0 200 700 100 0 0 0 0 0
+cpu0 100 0 50 400 50 0 0 0 0 0
+cpu1 400 0 150 300 50 0 0 0 0 0
+intr 2345 0 0
+ctxt 6789`
+)
+
+func bootStrapSysInfoTest(t *testing.T, content string) (*sysinfo, *fileCache, func()) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	env := new(MockedEnvironment)
+	env.On("getRuntimeGOOS", nil).Return("linux")
+	env.On("getFileContent", procStat).Return(content)
+	env.On("cache", nil).Return(fc)
+	s := &sysinfo{
+		env:   env,
+		props: &properties{},
+	}
+	return s, fc, cleanup
+}
+
+func TestParseProcStat(t *testing.T) {
+	total, cores := parseProcStat(procStatFirst)
+	assert.Equal(t, cpuSample{total: 1000, idle: 600}, total)
+	assert.Equal(t, []cpuSample{{total: 500, idle: 350}, {total: 500, idle: 250}}, cores)
+}
+
+func TestParseProcStatEmpty(t *testing.T) {
+	total, cores := parseProcStat("")
+	assert.Equal(t, cpuSample{}, total)
+	assert.Empty(t, cores)
+}
+
+func TestSysInfoPerCoreDelta(t *testing.T) {
+	s, fc, cleanup := bootStrapSysInfoTest(t, procStatSecond)
+	defer cleanup()
+	fc.set(cpuSampleCacheKey, formatSamples(parseProcStat(procStatFirst)))
+	assert.True(t, s.enabled())
+	// cpu0: 100 more idle of 100 total, cpu1: 300 busy of 400 total
+	assert.Equal(t, []float64{0, 75}, s.PerCore)
+	assert.Equal(t, float64(60), s.CPU)
+	value, _, _ := fc.get(cpuSampleCacheKey)
+	assert.Equal(t, "1500 800\n600 450\n900 350", value)
+}
+
+func TestSysInfoWithoutPreviousSample(t *testing.T) {
+	s, _, cleanup := bootStrapSysInfoTest(t, procStatFirst)
+	defer cleanup()
+	assert.True(t, s.enabled())
+	assert.Equal(t, []float64{30, 50}, s.PerCore)
+	assert.Equal(t, float64(40), s.CPU)
+}
+
+func TestSysInfoCoreCountChanged(t *testing.T) {
+	s, fc, cleanup := bootStrapSysInfoTest(t, procStatSecond)
+	defer cleanup()
+	fc.set(cpuSampleCacheKey, "1000 600\n500 350")
+	assert.True(t, s.enabled())
+	assert.Len(t, s.PerCore, 2)
+}
+
+func TestSysInfoNotAvailable(t *testing.T) {
+	s, _, cleanup := bootStrapSysInfoTest(t, "")
+	defer cleanup()
+	assert.False(t, s.enabled())
+}
+
+func TestSysInfoTemplate(t *testing.T) {
+	s, _, cleanup := bootStrapSysInfoTest(t, procStatFirst)
+	defer cleanup()
+	assert.True(t, s.enabled())
+	assert.Equal(t, "40%", s.string())
+	s.props.values = map[Property]interface{}{
+		SegmentTemplate: "{{ range .PerCore }}{{ printf \"%.0f \" . }}{{ end }}",
+	}
+	assert.Equal(t, "30 50 ", s.string())
+}
+
+func TestSysInfoPreviousProcStatFormat(t *testing.T) {
+	s, fc, cleanup := bootStrapSysInfoTest(t, procStatFirst)
+	defer cleanup()
+	// the whole /proc/stat was cached before, it counts as no previous sample
+	fc.set(cpuSampleCacheKey, procStatFirst)
+	assert.True(t, s.enabled())
+	assert.Equal(t, float64(40), s.CPU)
+}
+
+func TestParseSamples(t *testing.T) {
+	total, cores := parseSamples(formatSamples(cpuSample{total: 1000.5, idle: 600}, []cpuSample{{total: 500, idle: 350}}))
+	assert.Equal(t, cpuSample{total: 1000.5, idle: 600}, total)
+	assert.Equal(t, []cpuSample{{total: 500, idle: 350}}, cores)
+	_, cores = parseSamples("")
+	assert.Empty(t, cores)
+}
+
+func TestSysInfoDarwin(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	fc.set(cpuSampleCacheKey, "1000 600\n500 350\n500 250")
+	env := new(MockedEnvironment)
+	env.On("getRuntimeGOOS", nil).Return(darwinPlatform)
+	env.On("cache", nil).Return(fc)
+	env.On("getCPUTimes", nil).Return([]cpu.TimesStat{
+		{CPU: "cpu0", User: 100, System: 50, Idle: 450},
+		{CPU: "cpu1", User: 400, System: 150, Idle: 250},
+	}, nil)
+	s := &sysinfo{
+		env:   env,
+		props: &properties{},
+	}
+	assert.True(t, s.enabled())
+	// cpu0: 100 more idle of 100 total, cpu1: 300 busy of 300 total
+	assert.Equal(t, []float64{0, 100}, s.PerCore)
+	assert.Equal(t, float64(75), s.CPU)
+	env.AssertNotCalled(t, "getFileContent", procStat)
+}
+
+func TestSysInfoDarwinNotAvailable(t *testing.T) {
+	fc, cleanup := newTestFileCache(t, time.Now())
+	defer cleanup()
+	env := new(MockedEnvironment)
+	env.On("getRuntimeGOOS", nil).Return(darwinPlatform)
+	env.On("cache", nil).Return(fc)
+	env.On("getCPUTimes", nil).Return([]cpu.TimesStat{}, errors.New("not implemented yet"))
+	s := &sysinfo{
+		env:   env,
+		props: &properties{},
+	}
+	assert.False(t, s.enabled())
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -97,3 +98,277 @@ func TestShouldIgnoreFolderRegexInvertedNonEscaped(t *testing.T) {
 	got := segment.shouldIgnoreFolder(cwd)
 	assert.False(t, got)
 }
+
+func bootStrapCachedCommandSegment(fc *fileCache, output string) (*Segment, *MockedEnvironment) {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "bash").Return(true)
+	env.On("runShellCommand", "bash", "expensive").Return(output)
+	env.On("cache", nil).Return(fc)
+	segment := &Segment{
+		Type: Cmd,
+		Properties: map[Property]interface{}{
+			Command: "expensive",
+			Cache:   float64(60),
+		},
+	}
+	return segment, env
+}
+
+func TestSetStringValueCacheHitWithinTTL(t *testing.T) {
+	now := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	segment, env := bootStrapCachedCommandSegment(fc, "first")
+	segment.setStringValue(env, cwd, false)
+	assert.Equal(t, "first", segment.stringValue)
+	fc.now = func() time.Time { return now.Add(59 * time.Second) }
+	segment, env = bootStrapCachedCommandSegment(fc, "second")
+	segment.setStringValue(env, cwd, false)
+	assert.True(t, segment.active)
+	assert.Equal(t, "first", segment.stringValue)
+	env.AssertNotCalled(t, "runShellCommand", "bash", "expensive")
+}
+
+func TestSetStringValueCacheRefreshAfterTTL(t *testing.T) {
+	now := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	segment, env := bootStrapCachedCommandSegment(fc, "first")
+	segment.setStringValue(env, cwd, false)
+	fc.now = func() time.Time { return now.Add(61 * time.Second) }
+	segment, env = bootStrapCachedCommandSegment(fc, "second")
+	segment.setStringValue(env, cwd, false)
+	assert.Equal(t, "second", segment.stringValue)
+	value, _, _ := fc.get(segment.cacheKey(cwd))
+	assert.Equal(t, "second", value)
+}
+
+func TestSetStringValueCachedEmptyOutput(t *testing.T) {
+	now := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	segment, env := bootStrapCachedCommandSegment(fc, "")
+	segment.setStringValue(env, cwd, false)
+	segment, env = bootStrapCachedCommandSegment(fc, "second")
+	segment.setStringValue(env, cwd, false)
+	assert.False(t, segment.active)
+	env.AssertNotCalled(t, "runShellCommand", "bash", "expensive")
+}
+
+func TestCacheKeyDependsOnProperties(t *testing.T) {
+	first := &Segment{Type: Cmd, Properties: map[Property]interface{}{Command: "a"}}
+	second := &Segment{Type: Cmd, Properties: map[Property]interface{}{Command: "b"}}
+	assert.Equal(t, first.cacheKey(cwd), first.cacheKey(cwd))
+	assert.NotEqual(t, first.cacheKey(cwd), second.cacheKey(cwd))
+}
+
+func TestCacheKeyDependsOnWorkingDirectory(t *testing.T) {
+	segment := &Segment{Type: Cmd, Properties: map[Property]interface{}{Command: "a"}}
+	assert.NotEqual(t, segment.cacheKey("/usr/home/first"), segment.cacheKey("/usr/home/second"))
+}
+
+func TestSetStringValueCacheOtherFolder(t *testing.T) {
+	now := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	fc, cleanup := newTestFileCache(t, now)
+	defer cleanup()
+	segment, env := bootStrapCachedCommandSegment(fc, "first")
+	segment.setStringValue(env, cwd, false)
+	segment, env = bootStrapCachedCommandSegment(fc, "second")
+	segment.setStringValue(env, "/usr/home/other", false)
+	assert.Equal(t, "second", segment.stringValue)
+}
+
+func TestSetStringValueEnabledTemplate(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Template string
+		Env      map[string]string
+		Expected bool
+	}{
+		{Case: "No template", Expected: true},
+		{Case: "Env var set", Template: "{{ if .Env.POSH_PATH }}true{{ end }}", Env: map[string]string{"POSH_PATH": "1"}, Expected: true},
+		{Case: "Env var missing", Template: "{{ if .Env.POSH_PATH }}true{{ end }}", Env: map[string]string{}},
+		{Case: "Env var comparison", Template: "{{ eq .Env.POSH_PATH \"show\" }}", Env: map[string]string{"POSH_PATH": "show"}, Expected: true},
+		{Case: "Env var comparison false", Template: "{{ eq .Env.POSH_PATH \"show\" }}", Env: map[string]string{"POSH_PATH": "hide"}},
+		{Case: "Shell and cwd", Template: "{{ and (eq .Shell \"pwsh\") (eq .Cwd \"/usr/home\") }}", Env: map[string]string{}, Expected: true},
+		{Case: "Invalid template", Template: "{{ if .Env.POSH_PATH }", Env: map[string]string{}},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("environ", nil).Return(tc.Env)
+		env.On("getShellName", nil).Return("pwsh")
+		env.On("getcwd", nil).Return("/usr/home")
+		env.On("hasFolder", "/usr/home").Return(true)
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getPathSeperator", nil).Return("/")
+		segment := &Segment{
+			Type: Path,
+			Properties: map[Property]interface{}{
+				Style: Folder,
+			},
+		}
+		if tc.Template != "" {
+			segment.Properties[EnabledTemplate] = tc.Template
+		}
+		segment.setStringValue(env, "/usr/home", false)
+		assert.Equal(t, tc.Expected, segment.active, tc.Case)
+		if tc.Expected {
+			assert.Equal(t, "~", segment.stringValue, tc.Case)
+		}
+	}
+}
+
+func TestSetStringValueWidth(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Output   string
+		MinWidth float64
+		MaxWidth float64
+		Expected string
+	}{
+		{Case: "Pad short output", Output: "abc", MinWidth: 6, Expected: "abc   "},
+		{Case: "Truncate long output", Output: "abcdefghij", MaxWidth: 6, Expected: "abcde\u2026"},
+		{Case: "Pad and truncate", Output: "abcdefghij", MinWidth: 4, MaxWidth: 6, Expected: "abcde\u2026"},
+		{Case: "Minimum over maximum", Output: "ab", MinWidth: 8, MaxWidth: 4, Expected: "ab  "},
+		{Case: "No width", Output: "abcdefghij", Expected: "abcdefghij"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("hasCommand", "bash").Return(true)
+		env.On("runShellCommand", "bash", "echo").Return(tc.Output)
+		segment := &Segment{
+			Type: Cmd,
+			Properties: map[Property]interface{}{
+				Command:  "echo",
+				MinWidth: tc.MinWidth,
+				MaxWidth: tc.MaxWidth,
+			},
+		}
+		segment.setStringValue(env, cwd, false)
+		assert.Equal(t, tc.Expected, segment.stringValue, tc.Case)
+	}
+}
+
+func TestSetStringValueTransforms(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("hasCommand", "bash").Return(true)
+	env.On("runShellCommand", "bash", "echo").Return("Feature/OMP-123")
+	segment := &Segment{
+		Type: Cmd,
+		Properties: map[Property]interface{}{
+			Command:    "echo",
+			Transforms: []interface{}{"lower", "replace:feature/:"},
+			MinWidth:   float64(10),
+		},
+	}
+	segment.setStringValue(env, cwd, false)
+	// the width is applied after the transforms
+	assert.Equal(t, "omp-123   ", segment.stringValue)
+}
+
+func TestSetStringValuePlatforms(t *testing.T) {
+	cases := []struct {
+		Case      string
+		Platforms []interface{}
+		GOOS      string
+		Expected  bool
+	}{
+		{Case: "No platforms", GOOS: "linux", Expected: true},
+		{Case: "Listed platform", Platforms: []interface{}{"windows"}, GOOS: windowsPlatform, Expected: true},
+		{Case: "One of the listed platforms", Platforms: []interface{}{"linux", "darwin"}, GOOS: darwinPlatform, Expected: true},
+		{Case: "Case insensitive", Platforms: []interface{}{"Windows"}, GOOS: windowsPlatform, Expected: true},
+		{Case: "Other platform", Platforms: []interface{}{"windows"}, GOOS: "linux"},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getRuntimeGOOS", nil).Return(tc.GOOS)
+		env.On("getcwd", nil).Return("/usr/home")
+		env.On("hasFolder", "/usr/home").Return(true)
+		env.On("homeDir", nil).Return("/usr/home")
+		env.On("getPathSeperator", nil).Return("/")
+		segment := &Segment{
+			Type: Path,
+			Properties: map[Property]interface{}{
+				Style: Folder,
+			},
+		}
+		if tc.Platforms != nil {
+			segment.Properties[Platforms] = tc.Platforms
+		}
+		segment.setStringValue(env, "/usr/home", false)
+		assert.Equal(t, tc.Expected, segment.active, tc.Case)
+		if tc.Expected {
+			assert.Equal(t, "~", segment.stringValue, tc.Case)
+			continue
+		}
+		env.AssertNotCalled(t, "getcwd", nil)
+	}
+}
+
+func TestEnabledOnPlatformDefault(t *testing.T) {
+	cases := []struct {
+		Case      string
+		Platforms []interface{}
+		GOOS      string
+		Expected  bool
+	}{
+		{Case: "Supported platform", GOOS: "linux", Expected: true},
+		{Case: "Unsupported platform", GOOS: windowsPlatform},
+		{Case: "Listed platform", Platforms: []interface{}{"windows"}, GOOS: windowsPlatform, Expected: true},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getRuntimeGOOS", nil).Return(tc.GOOS)
+		segment := &Segment{
+			Type:       SysInfo,
+			Properties: map[Property]interface{}{},
+		}
+		if tc.Platforms != nil {
+			segment.Properties[Platforms] = tc.Platforms
+		}
+		assert.NoError(t, segment.mapSegmentWithWriter(env))
+		assert.Equal(t, tc.Expected, segment.enabledOnPlatform(env), tc.Case)
+	}
+}
+
+func TestSetStringValueRenderError(t *testing.T) {
+	cases := []struct {
+		Case        string
+		RenderError bool
+		Template    string
+		Output      string
+		Err         error
+		Expected    string
+	}{
+		{Case: "Hidden by default", Err: &commandError{exitCode: 2}},
+		{Case: "Default template", RenderError: true, Err: &commandError{exitCode: 2}, Expected: "\uF071 2"},
+		{Case: "Custom template", RenderError: true, Template: "{{ .Type }} failed: {{ .Error }}", Err: &commandError{exitCode: 2}, Expected: "command failed: 2"},
+		{Case: "Empty template", RenderError: true, Template: " ", Err: &commandError{exitCode: 2}, Expected: " "},
+		{Case: "No error", RenderError: true, Output: "hello", Expected: "hello"},
+	}
+	for _, tc := range cases {
+		fc, cleanup := newTestFileCache(t, time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC))
+		env := new(MockedEnvironment)
+		env.On("cache", nil).Return(fc)
+		env.On("hasCommand", "bash").Return(true)
+		env.On("runShellCommand", "bash", "failing").Return(tc.Output)
+		env.On("runCommandContext", "bash", []string{"-c", "failing"}).Return(tc.Output, "", 2, tc.Err)
+		segment := &Segment{
+			Type: Cmd,
+			Properties: map[Property]interface{}{
+				Command:     "failing",
+				RenderError: tc.RenderError,
+				Cache:       float64(60),
+			},
+		}
+		if tc.Template != "" {
+			segment.Properties[ErrorTemplate] = tc.Template
+		}
+		segment.setStringValue(env, cwd, false)
+		assert.Equal(t, tc.Expected != "", segment.active, tc.Case)
+		assert.Equal(t, tc.Expected, segment.stringValue, tc.Case)
+		_, _, cached := fc.get(segment.cacheKey(cwd))
+		assert.Equal(t, !tc.RenderError || tc.Err == nil, cached, tc.Case)
+		cleanup()
+	}
+}
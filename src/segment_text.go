@@ -1,8 +1,12 @@
 package main
 
+import "strings"
+
 type text struct {
-	props *properties
-	env   environmentInfo
+	props    *properties
+	env      environmentInfo
+	segments map[string]SegmentWriter
+	content  string
 }
 
 const (
@@ -11,15 +15,45 @@ const (
 )
 
 func (t *text) enabled() bool {
-	return true
+	textProperty := t.props.getString(TextProperty, "!!text property not defined!!")
+	if !strings.Contains(textProperty, "{{") {
+		t.content = textProperty
+		return true
+	}
+	template := &textTemplate{
+		Template: textProperty,
+		Context: struct {
+			Segments map[string]SegmentWriter
+		}{
+			Segments: t.templateSegments(),
+		},
+	}
+	t.content = template.render()
+	return t.content != ""
 }
 
 func (t *text) string() string {
-	textProperty := t.props.getString(TextProperty, "!!text property not defined!!")
-	return textProperty
+	return t.content
 }
 
 func (t *text) init(props *properties, env environmentInfo) {
 	t.props = props
 	t.env = env
 }
+
+// templateSegments holds a writer for every segment type, the ones which didn't render
+// are zero values so referencing them renders their empty fields instead of failing
+func (t *text) templateSegments() map[string]SegmentWriter {
+	segments := make(map[string]SegmentWriter)
+	for segmentType, writer := range newSegmentWriters() {
+		segments[string(segmentType)] = writer
+	}
+	for name, writer := range t.segments {
+		segments[name] = writer
+	}
+	return segments
+}
+
+func (t *text) setSegments(segments map[string]SegmentWriter) {
+	t.segments = segments
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextSegment(t *testing.T) {
+	dirtyGit := &git{
+		repo: &gitRepo{
+			working: &gitStatus{changed: true},
+			staging: &gitStatus{},
+		},
+	}
+	cleanGit := &git{
+		repo: &gitRepo{
+			working: &gitStatus{},
+			staging: &gitStatus{},
+		},
+	}
+	cases := []struct {
+		Case            string
+		ExpectedEnabled bool
+		ExpectedString  string
+		Text            string
+		Segments        map[string]SegmentWriter
+	}{
+		{Case: "Plain text", ExpectedEnabled: true, ExpectedString: "hello", Text: "hello"},
+		{Case: "Segment rendered", ExpectedEnabled: true, ExpectedString: "main", Text: "{{ .Segments.git.UserName }}", Segments: map[string]SegmentWriter{"git": &git{UserName: "main"}}},
+		{Case: "Segment not rendered", ExpectedEnabled: false, Text: "{{ .Segments.git.UserName }}", Segments: map[string]SegmentWriter{}},
+		{Case: "No segments", ExpectedEnabled: false, Text: "{{ .Segments.git.UserName }}"},
+		{Case: "Segment data", ExpectedEnabled: true, ExpectedString: "dirty", Text: "{{ if .Segments.git.Dirty }}dirty{{ end }}", Segments: map[string]SegmentWriter{"git": dirtyGit}},
+		{Case: "Segment data false", ExpectedEnabled: false, Text: "{{ if .Segments.git.Dirty }}dirty{{ end }}", Segments: map[string]SegmentWriter{"git": cleanGit}},
+		{Case: "Missing segment data", ExpectedEnabled: false, Text: "{{ if .Segments.git.Dirty }}dirty{{ end }}", Segments: map[string]SegmentWriter{}},
+	}
+	for _, tc := range cases {
+		text := &text{
+			props: &properties{
+				values: map[Property]interface{}{
+					TextProperty: tc.Text,
+				},
+			},
+		}
+		text.setSegments(tc.Segments)
+		assert.Equal(t, tc.ExpectedEnabled, text.enabled(), tc.Case)
+		assert.Equal(t, tc.ExpectedString, text.string(), tc.Case)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "strings"
+
+type tmux struct {
+	props *properties
+	env   environmentInfo
+	// Session is the name of the tmux session
+	Session string
+	// Window is the name of the tmux window
+	Window string
+}
+
+func (t *tmux) enabled() bool {
+	if t.env.getenv("TMUX") == "" || !t.env.hasCommand("tmux") {
+		return false
+	}
+	args := []string{"display-message", "-p"}
+	// target the pane of this shell, the active one can belong to another client
+	if pane := t.env.getenv("TMUX_PANE"); pane != "" {
+		args = append(args, "-t", pane)
+	}
+	args = append(args, "#{session_name}\t#{window_name}")
+	output, err := t.env.runCommand("tmux", args...)
+	if err != nil {
+		return false
+	}
+	names := strings.SplitN(strings.TrimSpace(output), "\t", 2)
+	if len(names) != 2 {
+		return false
+	}
+	t.Session = names[0]
+	t.Window = names[1]
+	return t.Session != ""
+}
+
+func (t *tmux) string() string {
+	template := &textTemplate{
+		Template: t.props.getString(SegmentTemplate, "{{ .Session }}:{{ .Window }}"),
+		Context:  t,
+	}
+	return template.render()
+}
+
+func (t *tmux) init(props *properties, env environmentInfo) {
+	t.props = props
+	t.env = env
+}
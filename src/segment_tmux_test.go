@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tmuxArgs struct {
+	tmux     string
+	pane     string
+	hasTmux  bool
+	output   string
+	err      error
+	template string
+}
+
+func bootStrapTmuxTest(args *tmuxArgs) *tmux {
+	env := new(MockedEnvironment)
+	env.On("getenv", "TMUX").Return(args.tmux)
+	env.On("getenv", "TMUX_PANE").Return(args.pane)
+	env.On("hasCommand", "tmux").Return(args.hasTmux)
+	env.On("runCommand", "tmux", []string{"display-message", "-p", "-t", "%3", "#{session_name}\t#{window_name}"}).Return(args.output, args.err)
+	env.On("runCommand", "tmux", []string{"display-message", "-p", "#{session_name}\t#{window_name}"}).Return(args.output, args.err)
+	values := map[Property]interface{}{}
+	if args.template != "" {
+		values[SegmentTemplate] = args.template
+	}
+	return &tmux{
+		env: env,
+		props: &properties{
+			values: values,
+		},
+	}
+}
+
+func TestTmux(t *testing.T) {
+	cases := []struct {
+		Case            string
+		Args            *tmuxArgs
+		ExpectedEnabled bool
+		ExpectedString  string
+	}{
+		{
+			Case:            "Inside tmux",
+			Args:            &tmuxArgs{tmux: "/tmp/tmux-1000/default,1234,0", pane: "%3", hasTmux: true, output: "work\tvim"},
+			ExpectedEnabled: true,
+			ExpectedString:  "work:vim",
+		},
+		{
+			Case:            "Without pane",
+			Args:            &tmuxArgs{tmux: "/tmp/tmux-1000/default,1234,0", hasTmux: true, output: "work\tvim\n"},
+			ExpectedEnabled: true,
+			ExpectedString:  "work:vim",
+		},
+		{
+			Case:            "Custom template",
+			Args:            &tmuxArgs{tmux: "/tmp/tmux-1000/default,1234,0", pane: "%3", hasTmux: true, output: "work\tmy window", template: "{{ .Window }}"},
+			ExpectedEnabled: true,
+			ExpectedString:  "my window",
+		},
+		{Case: "Outside tmux", Args: &tmuxArgs{hasTmux: true, output: "work\tvim"}},
+		{Case: "No tmux executable", Args: &tmuxArgs{tmux: "/tmp/tmux-1000/default,1234,0"}},
+		{Case: "Command fails", Args: &tmuxArgs{tmux: "/tmp/tmux-1000/default,1234,0", hasTmux: true, err: errors.New("no server running")}},
+		{Case: "Unexpected output", Args: &tmuxArgs{tmux: "/tmp/tmux-1000/default,1234,0", hasTmux: true, output: "work"}},
+	}
+	for _, tc := range cases {
+		tmux := bootStrapTmuxTest(tc.Args)
+		assert.Equal(t, tc.ExpectedEnabled, tmux.enabled(), tc.Case)
+		if tc.ExpectedEnabled {
+			assert.Equal(t, tc.ExpectedString, tmux.string(), tc.Case)
+		}
+	}
+}
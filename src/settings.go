@@ -3,17 +3,31 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"muzzammil.xyz/jsonc"
 )
 
 // Settings holds all the theme for rendering the prompt
 type Settings struct {
-	FinalSpace        bool              `json:"final_space"`
-	ConsoleTitle      bool              `json:"console_title"`
-	ConsoleTitleStyle ConsoleTitleStyle `json:"console_title_style"`
-	Blocks            []*Block          `json:"blocks"`
+	FinalSpace         bool                `json:"final_space"`
+	ConsoleTitle       bool                `json:"console_title"`
+	ConsoleTitleStyle  ConsoleTitleStyle   `json:"console_title_style"`
+	ClearLine          bool                `json:"clear_line"`
+	OSC7               bool                `json:"osc7"`
+	NerdFontVersion    NerdFontVersion     `json:"nerd_font_version"`
+	IconPack           string              `json:"icon_pack"`
+	Accent             string              `json:"accent"`
+	CaseSensitiveGlobs *bool               `json:"case_sensitive_globs"`
+	Blocks             []*Block            `json:"blocks"`
+	SecondaryPrompt    *Block              `json:"secondary_prompt"`
+	Profiles           map[string][]*Block `json:"profiles"`
+	Profile            *ProfileSelector    `json:"profile"`
+	icons              iconPack
 }
 
 // BlockType type of block
@@ -25,6 +39,9 @@ type BlockAlignment string
 // ConsoleTitleStyle defines how to show the title in the console window
 type ConsoleTitleStyle string
 
+// NerdFontVersion the version of the installed Nerd Font, used to select the codepoints of the default icons
+type NerdFontVersion string
+
 const (
 	// Prompt writes one or more Segments
 	Prompt BlockType = "prompt"
@@ -40,15 +57,66 @@ const (
 	FolderName ConsoleTitleStyle = "folder"
 	// FullPath show the current path
 	FullPath ConsoleTitleStyle = "path"
+	// NerdFontV2 uses the Nerd Font v2 codepoints, this is the default
+	NerdFontV2 NerdFontVersion = "v2"
+	// NerdFontV3 uses the Nerd Font v3 codepoints, where the Material Design icons moved
+	NerdFontV3 NerdFontVersion = "v3"
 )
 
 // Block defines a part of the prompt with optional segments
 type Block struct {
-	Type             BlockType      `json:"type"`
-	Alignment        BlockAlignment `json:"alignment"`
-	HorizontalOffset int            `json:"horizontal_offset"`
-	VerticalOffset   int            `json:"vertical_offset"`
-	Segments         []*Segment     `json:"segments"`
+	Type               BlockType           `json:"type"`
+	Alignment          BlockAlignment      `json:"alignment"`
+	HorizontalOffset   int                 `json:"horizontal_offset"`
+	VerticalOffset     int                 `json:"vertical_offset"`
+	Segments           []*Segment          `json:"segments"`
+	SegmentColors      []string            `json:"segment_colors"`
+	Foreground         string              `json:"foreground"`
+	Background         string              `json:"background"`
+	GradientBackground *GradientBackground `json:"gradient_background"`
+}
+
+// ProfileSelector picks the profile whose blocks are rendered instead of the top level blocks,
+// the profile named by the environment variable comes first, followed by the rules and the default
+type ProfileSelector struct {
+	EnvVar  string         `json:"env_var"`
+	Rules   []*ProfileRule `json:"rules"`
+	Default string         `json:"default"`
+}
+
+// ProfileRule selects the profile when the hostname matches the glob, like *.corp.example.com
+type ProfileRule struct {
+	Hostname string `json:"hostname"`
+	Profile  string `json:"profile"`
+}
+
+// GradientBackground fades the background of the segments from one hex color to another
+type GradientBackground struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// inheritedBackground returns the background the segment at position, out of count displayed segments,
+// inherits from the block. The segment_colors take precedence over the gradient, followed by the block background
+func (b *Block) inheritedBackground(position, count int) string {
+	if color := b.segmentColor(position); color != "" {
+		return color
+	}
+	if b.GradientBackground != nil {
+		if colors := gradient(b.GradientBackground.From, b.GradientBackground.To, count); position < len(colors) {
+			return colors[position]
+		}
+	}
+	return b.Background
+}
+
+// segmentColor returns the background for the active segment at position,
+// cycling through the segment colors when there are more segments than colors
+func (b *Block) segmentColor(position int) string {
+	if len(b.SegmentColors) == 0 {
+		return ""
+	}
+	return b.SegmentColors[position%len(b.SegmentColors)]
 }
 
 // GetSettings returns the default configuration including possible user overrides
@@ -79,9 +147,239 @@ func loadUserConfiguration(env environmentInfo) (*Settings, error) {
 	if err != nil {
 		return nil, errors.New("INVALID CONFIG")
 	}
+	if err = settings.selectProfile(env); err != nil {
+		return nil, err
+	}
+	settings.resolvePalette()
+	if invalidColors := settings.invalidColors(); len(invalidColors) > 0 {
+		return nil, fmt.Errorf("INVALID COLOR: %s", strings.Join(invalidColors, ", "))
+	}
+	settings.icons, err = loadIconPack(settings.IconPack, filepath.Dir(settingsFile))
+	if err != nil {
+		return nil, fmt.Errorf("INVALID ICON PACK: %s", settings.IconPack)
+	}
 	return &settings, nil
 }
 
+// validateConfig loads the configuration of the --config flag and returns what is wrong with it, used by --validate
+func validateConfig(env environmentInfo) error {
+	_, err := loadUserConfiguration(env)
+	return err
+}
+
+// selectProfile replaces the blocks with the ones of the selected profile,
+// without a selector or a match the top level blocks are rendered
+func (s *Settings) selectProfile(env environmentInfo) error {
+	if s.Profile == nil {
+		return nil
+	}
+	name, ok := s.Profile.match(env, s.Profiles)
+	if !ok {
+		name = s.Profile.Default
+	}
+	if name == "" {
+		return nil
+	}
+	blocks, ok := s.Profiles[name]
+	if !ok {
+		return fmt.Errorf("UNKNOWN PROFILE: %s", name)
+	}
+	s.Blocks = blocks
+	return nil
+}
+
+// match returns the profile named by the environment variable, or else the one of the first rule matching the hostname
+func (p *ProfileSelector) match(env environmentInfo, profiles map[string][]*Block) (string, bool) {
+	if p.EnvVar != "" {
+		if name := env.getenv(p.EnvVar); name != "" {
+			if _, ok := profiles[name]; ok {
+				return name, true
+			}
+		}
+	}
+	if len(p.Rules) == 0 {
+		return "", false
+	}
+	hostname, err := env.getHostName()
+	if err != nil {
+		return "", false
+	}
+	for _, rule := range p.Rules {
+		if matched, _ := filepath.Match(strings.ToLower(rule.Hostname), strings.ToLower(hostname)); matched {
+			return rule.Profile, true
+		}
+	}
+	return "", false
+}
+
+// invalidColors returns the location and value of every color in the configuration
+// which can't be rendered, e.g. blocks[0].segments[1].foreground: #zzz
+func (s *Settings) invalidColors() []string {
+	var invalid []string
+	validate := func(location, value string) {
+		if value == "" || isValidColor(value) {
+			return
+		}
+		if strings.HasPrefix(value, paletteReference) {
+			invalid = append(invalid, fmt.Sprintf("%s: %s, not in the palette", location, value))
+			return
+		}
+		invalid = append(invalid, fmt.Sprintf("%s: %s", location, value))
+	}
+	// a gradient can only be computed between hex colors
+	validateHex := func(location, value string) {
+		if _, ok := parseHexColor(value); !ok {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", location, value))
+		}
+	}
+	// inherit is only valid when the block has a color to inherit
+	validateInherited := func(location, value string, blockDefault bool) {
+		if value != Inherit {
+			validate(location, value)
+			return
+		}
+		if !blockDefault {
+			invalid = append(invalid, fmt.Sprintf("%s: %s, the block has no default to inherit", location, value))
+		}
+	}
+	var validateProperty func(location string, value interface{})
+	validateProperty = func(location string, value interface{}) {
+		switch v := value.(type) {
+		case string:
+			validate(location, v)
+		case []interface{}:
+			for i, color := range v {
+				validateProperty(fmt.Sprintf("%s[%d]", location, i), color)
+			}
+		default:
+			invalid = append(invalid, fmt.Sprintf("%s: %v", location, value))
+		}
+	}
+	validateBlock := func(location string, block *Block) {
+		for j, color := range block.SegmentColors {
+			validate(fmt.Sprintf("%s.segment_colors[%d]", location, j), color)
+		}
+		validate(location+".foreground", block.Foreground)
+		validate(location+".background", block.Background)
+		if fade := block.GradientBackground; fade != nil {
+			validateHex(location+".gradient_background.from", fade.From)
+			validateHex(location+".gradient_background.to", fade.To)
+		}
+		for j, segment := range block.Segments {
+			segmentLocation := fmt.Sprintf("%s.segments[%d]", location, j)
+			validateInherited(segmentLocation+".foreground", segment.Foreground, block.Foreground != "")
+			validateInherited(segmentLocation+".background", segment.Background, block.Background != "" || len(block.SegmentColors) > 0 || block.GradientBackground != nil)
+			for property, value := range segment.Properties {
+				if colorProperties[property] {
+					validateProperty(fmt.Sprintf("%s.properties.%s", segmentLocation, property), value)
+				}
+			}
+		}
+	}
+	if s.Accent != "" {
+		validateHex("accent", s.Accent)
+	}
+	for i, block := range s.Blocks {
+		validateBlock(fmt.Sprintf("blocks[%d]", i), block)
+	}
+	if s.SecondaryPrompt != nil {
+		validateBlock("secondary_prompt", s.SecondaryPrompt)
+	}
+	sort.Strings(invalid)
+	return invalid
+}
+
+// resolvePalette replaces the p:name references in the colors of the blocks, the secondary prompt included, and segments
+// with the colors derived from the accent, unknown references are left for invalidColors to report
+func (s *Settings) resolvePalette() {
+	colors, ok := derivePalette(s.Accent)
+	if !ok {
+		return
+	}
+	blocks := s.Blocks
+	if s.SecondaryPrompt != nil {
+		blocks = append(blocks, s.SecondaryPrompt)
+	}
+	for _, block := range blocks {
+		for i, color := range block.SegmentColors {
+			block.SegmentColors[i] = colors.resolve(color)
+		}
+		block.Foreground = colors.resolve(block.Foreground)
+		block.Background = colors.resolve(block.Background)
+		if fade := block.GradientBackground; fade != nil {
+			fade.From = colors.resolve(fade.From)
+			fade.To = colors.resolve(fade.To)
+		}
+		for _, segment := range block.Segments {
+			segment.Foreground = colors.resolve(segment.Foreground)
+			segment.Background = colors.resolve(segment.Background)
+			for property, value := range segment.Properties {
+				if !colorProperties[property] {
+					continue
+				}
+				switch v := value.(type) {
+				case string:
+					segment.Properties[property] = colors.resolve(v)
+				case []interface{}:
+					for i, color := range v {
+						if colorString, ok := color.(string); ok {
+							v[i] = colors.resolve(colorString)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// colorProperties are the segment properties holding a color, or a list of colors, validated when loading the
+// configuration. A new color property has to be added here to be validated and to resolve palette references
+var colorProperties = map[Property]bool{
+	ChargedColor:             true,
+	ChargingColor:            true,
+	DischargingColor:         true,
+	ErrorColor:               true,
+	WorkingColor:             true,
+	StagingColor:             true,
+	StagedForeground:         true,
+	UnstagedForeground:       true,
+	LocalChangesColor:        true,
+	AheadAndBehindColor:      true,
+	BehindColor:              true,
+	AheadColor:               true,
+	StatusBarStagedColor:     true,
+	StatusBarModifiedColor:   true,
+	StatusBarUntrackedColor:  true,
+	StatusBarConflictedColor: true,
+	LastFetchStaleColor:      true,
+	BranchColorPalette:       true,
+	BaseForeground:           true,
+	BaseBackground:           true,
+	IgnoredForeground:        true,
+	UserColor:                true,
+	HostColor:                true,
+}
+
+// getDefaultSecondaryPrompt is used when the configuration has no secondary_prompt
+func getDefaultSecondaryPrompt() *Block {
+	return &Block{
+		Type:      Prompt,
+		Alignment: Left,
+		Segments: []*Segment{
+			{
+				Type:       Text,
+				Style:      Plain,
+				Foreground: "default",
+				Properties: map[Property]interface{}{
+					TextProperty: ">",
+					Prefix:       "",
+					Postfix:      " ",
+				},
+			},
+		},
+	}
+}
+
 func getDefaultSettings(info string) *Settings {
 	settings := &Settings{
 		FinalSpace:        true,
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidColorsValidSettings(t *testing.T) {
+	settings := &Settings{
+		Blocks: []*Block{
+			{
+				Segments: []*Segment{
+					{
+						Foreground: "#ffffff",
+						Background: Transparent,
+						Properties: map[Property]interface{}{
+							ErrorColor:        "red",
+							LocalChangesColor: "#fff",
+							Prefix:            "garbage",
+						},
+					},
+					{
+						Foreground: "lightBlue",
+					},
+				},
+			},
+		},
+	}
+	assert.Empty(t, settings.invalidColors())
+}
+
+func TestInvalidColors(t *testing.T) {
+	settings := &Settings{
+		Blocks: []*Block{
+			{
+				Segments: []*Segment{
+					{
+						Foreground: "#ffffff",
+					},
+				},
+			},
+			{
+				SegmentColors: []string{"#ff0000", "nope"},
+				Segments: []*Segment{
+					{
+						Foreground: "garbage",
+						Background: "#zzzzzz",
+						Properties: map[Property]interface{}{
+							ErrorColor:   "lightPurple",
+							WorkingColor: true,
+						},
+					},
+				},
+			},
+		},
+	}
+	expected := []string{
+		"blocks[1].segment_colors[1]: nope",
+		"blocks[1].segments[0].background: #zzzzzz",
+		"blocks[1].segments[0].foreground: garbage",
+		"blocks[1].segments[0].properties.error_color: lightPurple",
+		"blocks[1].segments[0].properties.working_color: true",
+	}
+	assert.Equal(t, expected, settings.invalidColors())
+}
+
+func TestColorProperties(t *testing.T) {
+	assert.True(t, colorProperties[ErrorColor])
+	assert.True(t, colorProperties[BaseForeground])
+	assert.True(t, colorProperties[BaseBackground])
+	assert.True(t, colorProperties[BranchColorPalette])
+	assert.False(t, colorProperties[ColorBackground])
+	assert.False(t, colorProperties[FetchInBackground])
+	assert.False(t, colorProperties[Prefix])
+}
+
+func TestBlockSegmentColor(t *testing.T) {
+	block := &Block{
+		SegmentColors: []string{"#ff0000", "#00ff00", "#0000ff"},
+	}
+	expected := []string{"#ff0000", "#00ff00", "#0000ff", "#ff0000", "#00ff00"}
+	for position, color := range expected {
+		assert.Equal(t, color, block.segmentColor(position))
+	}
+	assert.Empty(t, (&Block{}).segmentColor(1))
+}
+
+func TestInvalidColorsInherit(t *testing.T) {
+	settings := &Settings{
+		Blocks: []*Block{
+			{
+				Foreground: "#ffffff",
+				Background: "#000000",
+				Segments: []*Segment{
+					{Foreground: Inherit, Background: Inherit},
+				},
+			},
+			{
+				SegmentColors: []string{"#ff0000"},
+				Segments: []*Segment{
+					{Foreground: Inherit, Background: Inherit},
+				},
+			},
+			{
+				Foreground: "nope",
+				Segments: []*Segment{
+					{Background: Inherit},
+				},
+			},
+		},
+	}
+	expected := []string{
+		"blocks[1].segments[0].foreground: inherit, the block has no default to inherit",
+		"blocks[2].foreground: nope",
+		"blocks[2].segments[0].background: inherit, the block has no default to inherit",
+	}
+	assert.Equal(t, expected, settings.invalidColors())
+}
+
+func TestInheritedBackground(t *testing.T) {
+	cases := []struct {
+		Case          string
+		Background    string
+		SegmentColors []string
+		Gradient      *GradientBackground
+		Expected      string
+	}{
+		{Case: "Block background", Background: "#000000", Expected: "#000000"},
+		{Case: "Segment colors first", Background: "#000000", SegmentColors: []string{"#ff0000", "#00ff00"}, Expected: "#00ff00"},
+		{Case: "Gradient", Background: "#ff0000", Gradient: &GradientBackground{From: "#000000", To: "#ffffff"}, Expected: "#808080"},
+		{Case: "Invalid gradient", Background: "#ff0000", Gradient: &GradientBackground{From: "red", To: "#ffffff"}, Expected: "#ff0000"},
+		{Case: "Nothing to inherit"},
+	}
+	for _, tc := range cases {
+		block := &Block{
+			Background:         tc.Background,
+			SegmentColors:      tc.SegmentColors,
+			GradientBackground: tc.Gradient,
+		}
+		assert.Equal(t, tc.Expected, block.inheritedBackground(1, 3), tc.Case)
+	}
+}
+
+func TestInvalidColorsGradientBackground(t *testing.T) {
+	settings := &Settings{
+		Blocks: []*Block{
+			{
+				GradientBackground: &GradientBackground{From: "#000", To: "#ffffff"},
+				Segments: []*Segment{
+					{Background: Inherit},
+				},
+			},
+			{
+				GradientBackground: &GradientBackground{From: "red", To: "#ffffff"},
+			},
+		},
+	}
+	expected := []string{
+		"blocks[1].gradient_background.from: red",
+	}
+	assert.Equal(t, expected, settings.invalidColors())
+}
+
+func TestResolvePalette(t *testing.T) {
+	settings := &Settings{
+		Accent: "#0077c2",
+		Blocks: []*Block{
+			{
+				SegmentColors: []string{"p:accent", "p:accent.dark"},
+				Foreground:    "p:accent.lighter",
+				GradientBackground: &GradientBackground{
+					From: "p:accent.darker",
+					To:   "#ffffff",
+				},
+				Segments: []*Segment{
+					{
+						Foreground: "p:accent.light",
+						Background: "p:accent.complement",
+						Properties: map[Property]interface{}{
+							ErrorColor: "p:accent.dark",
+							Prefix:     "p:accent",
+						},
+					},
+				},
+			},
+		},
+		SecondaryPrompt: &Block{
+			Background: "p:accent",
+		},
+	}
+	settings.resolvePalette()
+	block := settings.Blocks[0]
+	assert.Equal(t, []string{"#0077c2", "#004875"}, block.SegmentColors)
+	assert.Equal(t, "#5cc0ff", block.Foreground)
+	assert.Equal(t, "#001929", block.GradientBackground.From)
+	assert.Equal(t, "#ffffff", block.GradientBackground.To)
+	segment := block.Segments[0]
+	assert.Equal(t, "#10a2ff", segment.Foreground)
+	assert.Equal(t, "#c24b00", segment.Background)
+	assert.Equal(t, "#004875", segment.Properties[ErrorColor])
+	assert.Equal(t, "p:accent", segment.Properties[Prefix], "only color properties are resolved")
+	assert.Equal(t, "#0077c2", settings.SecondaryPrompt.Background)
+	assert.Empty(t, settings.invalidColors())
+}
+
+func TestInvalidColorsPalette(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Accent   string
+		Expected []string
+	}{
+		{Case: "Unknown shade", Accent: "#0077c2", Expected: []string{"blocks[0].segments[0].foreground: p:accent.unknown, not in the palette"}},
+		{Case: "No accent", Expected: []string{"blocks[0].segments[0].background: p:accent, not in the palette", "blocks[0].segments[0].foreground: p:accent.unknown, not in the palette"}},
+		{Case: "Invalid accent", Accent: "blue", Expected: []string{
+			"accent: blue",
+			"blocks[0].segments[0].background: p:accent, not in the palette",
+			"blocks[0].segments[0].foreground: p:accent.unknown, not in the palette",
+		}},
+	}
+	for _, tc := range cases {
+		settings := &Settings{
+			Accent: tc.Accent,
+			Blocks: []*Block{
+				{
+					Segments: []*Segment{
+						{
+							Foreground: "p:accent.unknown",
+							Background: "p:accent",
+						},
+					},
+				},
+			},
+		}
+		settings.resolvePalette()
+		assert.Equal(t, tc.Expected, settings.invalidColors(), tc.Case)
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	home := []*Block{{Type: Prompt, Alignment: Left}}
+	work := []*Block{{Type: Prompt, Alignment: Right}}
+	top := []*Block{{Type: RPrompt}}
+	cases := []struct {
+		Case     string
+		EnvValue string
+		Hostname string
+		Default  string
+		Expected []*Block
+	}{
+		{Case: "Environment variable", EnvValue: "work", Hostname: "laptop", Expected: work},
+		{Case: "Environment variable before rules", EnvValue: "home", Hostname: "build01.corp.example.com", Expected: home},
+		{Case: "Unknown environment value", EnvValue: "nope", Hostname: "build01.corp.example.com", Expected: work},
+		{Case: "Hostname rule", Hostname: "BUILD01.corp.example.com", Expected: work},
+		{Case: "Default", Hostname: "laptop", Default: "home", Expected: home},
+		{Case: "Top level blocks", Hostname: "laptop", Expected: top},
+	}
+	for _, tc := range cases {
+		env := new(MockedEnvironment)
+		env.On("getenv", "OMP_PROFILE").Return(tc.EnvValue)
+		env.On("getHostName", nil).Return(tc.Hostname, nil)
+		settings := &Settings{
+			Blocks: top,
+			Profiles: map[string][]*Block{
+				"home": home,
+				"work": work,
+			},
+			Profile: &ProfileSelector{
+				EnvVar: "OMP_PROFILE",
+				Rules: []*ProfileRule{
+					{Hostname: "*.corp.example.com", Profile: "work"},
+				},
+				Default: tc.Default,
+			},
+		}
+		assert.NoError(t, settings.selectProfile(env), tc.Case)
+		assert.Equal(t, tc.Expected, settings.Blocks, tc.Case)
+	}
+}
+
+func TestSelectProfileWithoutSelector(t *testing.T) {
+	top := []*Block{{Type: Prompt}}
+	settings := &Settings{
+		Blocks: top,
+		Profiles: map[string][]*Block{
+			"work": {{Type: RPrompt}},
+		},
+	}
+	assert.NoError(t, settings.selectProfile(new(MockedEnvironment)))
+	assert.Equal(t, top, settings.Blocks)
+}
+
+func TestSelectProfileUnknown(t *testing.T) {
+	env := new(MockedEnvironment)
+	env.On("getenv", "OMP_PROFILE").Return("")
+	settings := &Settings{
+		Profile: &ProfileSelector{
+			EnvVar:  "OMP_PROFILE",
+			Default: "work",
+		},
+	}
+	assert.EqualError(t, settings.selectProfile(env), "UNKNOWN PROFILE: work")
+}
+
+func TestLoadUserConfigurationFetchInBackground(t *testing.T) {
+	dir, err := ioutil.TempDir("", "omp-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config.json")
+	content := `{
+  "blocks": [
+    {
+      "type": "prompt",
+      "alignment": "left",
+      "segments": [
+        {
+          "type": "git",
+          "style": "plain",
+          "foreground": "#193549",
+          "properties": {
+            "fetch_in_background": true,
+            "fetch_interval": 600
+          }
+        }
+      ]
+    }
+  ]
+}`
+	assert.NoError(t, ioutil.WriteFile(config, []byte(content), 0644))
+	env := new(MockedEnvironment)
+	env.On("getArgs", nil).Return(&args{Config: &config})
+	settings, err := loadUserConfiguration(env)
+	assert.NoError(t, err)
+	assert.Equal(t, true, settings.Blocks[0].Segments[0].Properties[FetchInBackground])
+}
+
+func TestInvalidColorsSecondaryPrompt(t *testing.T) {
+	settings := &Settings{
+		SecondaryPrompt: &Block{
+			Foreground: "#zzz",
+			Segments: []*Segment{
+				{
+					Foreground: "208",
+					Background: "lightPurple",
+				},
+			},
+		},
+	}
+	expected := []string{
+		"secondary_prompt.foreground: #zzz",
+		"secondary_prompt.segments[0].background: lightPurple",
+	}
+	assert.Equal(t, expected, settings.invalidColors())
+}
+
+func TestInvalidColorsPropertyList(t *testing.T) {
+	settings := &Settings{
+		Blocks: []*Block{
+			{
+				Segments: []*Segment{
+					{
+						Properties: map[Property]interface{}{
+							BranchColorPalette: []interface{}{"#ff0000", "auto", "256", "p:accent"},
+							ErrorColor:         float64(1),
+						},
+					},
+				},
+			},
+		},
+	}
+	expected := []string{
+		"blocks[0].segments[0].properties.branch_color_palette[2]: 256",
+		"blocks[0].segments[0].properties.branch_color_palette[3]: p:accent, not in the palette",
+		"blocks[0].segments[0].properties.error_color: 1",
+	}
+	assert.Equal(t, expected, settings.invalidColors())
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "omp-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config.json")
+	content := `{
+  "blocks": [
+    {
+      "type": "prompt",
+      "alignment": "left",
+      "segments": [
+        { "type": "path", "style": "plain", "foreground": "208", "background": "#zzz" }
+      ]
+    }
+  ]
+}`
+	assert.NoError(t, ioutil.WriteFile(config, []byte(content), 0644))
+	env := new(MockedEnvironment)
+	env.On("getArgs", nil).Return(&args{Config: &config})
+	assert.EqualError(t, validateConfig(env), "INVALID COLOR: blocks[0].segments[0].background: #zzz")
+}
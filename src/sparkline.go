@@ -0,0 +1,30 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+var sparks = []rune{'\u2581', '\u2582', '\u2583', '\u2584', '\u2585', '\u2586', '\u2587', '\u2588'}
+
+// sparkline maps every value to a block glyph, normalized between the lowest and highest value.
+// When all values are equal, a flat line of the lowest glyph is returned.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+	var builder strings.Builder
+	for _, value := range values {
+		index := 0
+		if max > min {
+			index = int(math.Round((value - min) / (max - min) * float64(len(sparks)-1)))
+		}
+		builder.WriteRune(sparks[index])
+	}
+	return builder.String()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparkline(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Values   []float64
+		Expected string
+	}{
+		{Case: "Empty", Values: []float64{}, Expected: ""},
+		{Case: "Nil", Expected: ""},
+		{Case: "Single value", Values: []float64{42}, Expected: "\u2581"},
+		{Case: "All equal", Values: []float64{3, 3, 3}, Expected: "\u2581\u2581\u2581"},
+		{Case: "Min and max", Values: []float64{0, 100}, Expected: "\u2581\u2588"},
+		{Case: "Series", Values: []float64{0, 1, 2, 3, 4, 5, 6, 7}, Expected: "\u2581\u2582\u2583\u2584\u2585\u2586\u2587\u2588"},
+		{Case: "Unordered", Values: []float64{10, 80, 45, 10}, Expected: "\u2581\u2588\u2585\u2581"},
+		{Case: "Negative", Values: []float64{-7, 0}, Expected: "\u2581\u2588"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, sparkline(tc.Values), tc.Case)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"text/template"
+)
+
+const (
+	// Errors to show when the template handling fails
+	invalidTemplate   = "invalid template text"
+	incorrectTemplate = "unable to create text based on template"
+)
+
+// templateFunctions are the functions available inside every template
+var templateFunctions = template.FuncMap{
+	"sparkline": sparkline,
+	"default":   defaultValue,
+	"coalesce":  coalesce,
+}
+
+// parsedTemplates reuses the parsed templates across the segments of a single render
+var parsedTemplates = newTemplateCache()
+
+// templateCache holds the parsed templates keyed by their text,
+// segments render concurrently so access is guarded
+type templateCache struct {
+	templates map[string]*template.Template
+	lock      sync.RWMutex
+	parse     func(text string) (*template.Template, error)
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{
+		templates: make(map[string]*template.Template),
+		parse: func(text string) (*template.Template, error) {
+			return template.New("text").Option("missingkey=zero").Funcs(templateFunctions).Parse(text)
+		},
+	}
+}
+
+// get returns the parsed template for the text, invalid templates are not cached
+func (tc *templateCache) get(text string) (*template.Template, error) {
+	tc.lock.RLock()
+	tmpl, found := tc.templates[text]
+	tc.lock.RUnlock()
+	if found {
+		return tmpl, nil
+	}
+	tmpl, err := tc.parse(text)
+	if err != nil {
+		return nil, err
+	}
+	tc.lock.Lock()
+	tc.templates[text] = tmpl
+	tc.lock.Unlock()
+	return tmpl, nil
+}
+
+type textTemplate struct {
+	Template string
+	Context  interface{}
+}
+
+func (t *textTemplate) render() string {
+	tmpl, err := parsedTemplates.get(t.Template)
+	if err != nil {
+		return invalidTemplate
+	}
+	buffer := new(bytes.Buffer)
+	defer buffer.Reset()
+	err = tmpl.Execute(buffer, t.Context)
+	if err != nil {
+		return incorrectTemplate
+	}
+	return buffer.String()
+}
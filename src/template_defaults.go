@@ -0,0 +1,40 @@
+package main
+
+import "reflect"
+
+// isEmptyValue reports whether a template value has nothing to display: nil, an empty string,
+// or an empty list or map. Numbers and booleans are values, a count of 0 is not empty
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// defaultValue returns the placeholder when the value is empty, pipes the value last:
+// {{ .UpstreamBranch | default "none" }}
+func defaultValue(placeholder, value interface{}) interface{} {
+	if isEmptyValue(value) {
+		return placeholder
+	}
+	return value
+}
+
+// coalesce returns the first value which is not empty, an empty string when all of them are:
+// {{ coalesce .UpstreamBranch .BaseBranch "none" }}
+func coalesce(values ...interface{}) interface{} {
+	for _, value := range values {
+		if !isEmptyValue(value) {
+			return value
+		}
+	}
+	return ""
+}
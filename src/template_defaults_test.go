@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEmptyValue(t *testing.T) {
+	var nilPointer *gitStash
+	cases := []struct {
+		Case     string
+		Value    interface{}
+		Expected bool
+	}{
+		{Case: "nil", Value: nil, Expected: true},
+		{Case: "empty string", Value: "", Expected: true},
+		{Case: "string", Value: "main"},
+		{Case: "zero int", Value: 0},
+		{Case: "int", Value: 3},
+		{Case: "zero float", Value: 0.0},
+		{Case: "false", Value: false},
+		{Case: "empty slice", Value: []string{}, Expected: true},
+		{Case: "slice", Value: []string{"a"}},
+		{Case: "empty map", Value: map[string]string{}, Expected: true},
+		{Case: "nil pointer", Value: nilPointer, Expected: true},
+		{Case: "pointer", Value: &gitStash{}},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, isEmptyValue(tc.Value), tc.Case)
+	}
+}
+
+func TestRenderTemplateDefaults(t *testing.T) {
+	context := struct {
+		Branch   string
+		Upstream string
+		Ahead    int
+		Behind   int
+		Stash    []*gitStash
+	}{
+		Branch: "main",
+		Behind: 2,
+	}
+	cases := []struct {
+		Case     string
+		Template string
+		Expected string
+	}{
+		{Case: "default on value", Template: `on {{ .Branch | default "none" }}`, Expected: "on main"},
+		{Case: "default on empty", Template: `up {{ .Upstream | default "none" }}`, Expected: "up none"},
+		{Case: "default keeps zero", Template: `{{ .Ahead | default "-" }}`, Expected: "0"},
+		{Case: "default on number", Template: `{{ .Behind | default "-" }}`, Expected: "2"},
+		{Case: "default on empty list", Template: `{{ .Stash | default "no stash" }}`, Expected: "no stash"},
+		{Case: "coalesce first value", Template: `{{ coalesce .Upstream .Branch "detached" }}`, Expected: "main"},
+		{Case: "coalesce fallback", Template: `{{ coalesce .Upstream "detached" }}`, Expected: "detached"},
+		{Case: "coalesce keeps zero", Template: `{{ coalesce .Upstream .Ahead }}`, Expected: "0"},
+		{Case: "coalesce all empty", Template: `[{{ coalesce .Upstream "" }}]`, Expected: "[]"},
+	}
+	for _, tc := range cases {
+		template := &textTemplate{
+			Template: tc.Template,
+			Context:  context,
+		}
+		assert.Equal(t, tc.Expected, template.render(), tc.Case)
+	}
+}
+
+func TestRenderTemplateDefaultMissingField(t *testing.T) {
+	template := &textTemplate{
+		Template: `{{ .Nope | default "n/a" }}`,
+		Context:  map[string]interface{}{},
+	}
+	assert.Equal(t, "n/a", template.render())
+}
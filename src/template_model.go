@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// templateModel returns the data a template can reference on a segment writer: the exported fields
+// and the exported methods without arguments, like .Dirty on git, keyed by their name
+func templateModel(writer interface{}) map[string]interface{} {
+	model := make(map[string]interface{})
+	value := reflect.ValueOf(writer)
+	if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
+		return model
+	}
+	for i := 0; i < value.NumMethod(); i++ {
+		method := value.Type().Method(i)
+		// a template can only use the first return value, an optional second one is the error
+		if method.Type.NumIn() != 1 || method.Type.NumOut() == 0 || method.Type.NumOut() > 2 {
+			continue
+		}
+		model[method.Name] = value.Method(i).Call(nil)[0].Interface()
+	}
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return model
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return model
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		model[field.Name] = value.Field(i).Interface()
+	}
+	return model
+}
+
+// dumpTemplateModel returns the template model of the writer as indented JSON
+func dumpTemplateModel(writer interface{}) string {
+	dump, err := json.MarshalIndent(templateModel(writer), "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(dump)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateModelGit(t *testing.T) {
+	g := &git{
+		repo: &gitRepo{
+			working: &gitStatus{changed: true, untracked: 2},
+			staging: &gitStatus{},
+		},
+		UserName:       "Jan",
+		CompareAhead:   3,
+		Tags:           []string{"v1.0.0"},
+		UpstreamBranch: "main",
+	}
+	model := templateModel(g)
+	documented := []string{
+		"Dirty", "IsBare", "Untracked", "Stash", "UserName", "UserEmail", "CompareAhead", "CompareBehind",
+		"RebaseStep", "RebaseTotal", "Signed", "SignatureStatus", "BaseBranch", "UpstreamBranch", "NameMismatch",
+		"LocalBranches", "Tags", "IndexLocked", "LastFetch",
+	}
+	for _, field := range documented {
+		assert.Contains(t, model, field)
+	}
+	assert.Equal(t, true, model["Dirty"])
+	assert.Equal(t, "2", model["Untracked"])
+	assert.Equal(t, "Jan", model["UserName"])
+	assert.Equal(t, 3, model["CompareAhead"])
+	assert.Equal(t, []string{"v1.0.0"}, model["Tags"])
+	for _, internal := range []string{"props", "env", "repo", "SetStatusColor"} {
+		assert.NotContains(t, model, internal)
+	}
+}
+
+func TestTemplateModelNil(t *testing.T) {
+	var g *git
+	assert.Empty(t, templateModel(nil))
+	assert.NotContains(t, templateModel(g), "UserName")
+}
+
+func TestDumpTemplateModel(t *testing.T) {
+	jj := &jujutsu{
+		ChangeId: "kxqvopwn",
+		Bookmark: "main",
+	}
+	expected := `{
+  "Bookmark": "main",
+  "ChangeId": "kxqvopwn",
+  "Dirty": false
+}`
+	assert.Equal(t, expected, dumpTemplateModel(jj))
+}
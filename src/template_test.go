@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Expected string
+		Template string
+		Context  interface{}
+	}{
+		{Case: "single property", Expected: "PR 123", Template: "PR {{.PRNumber}}", Context: map[string]string{"PRNumber": "123"}},
+		{Case: "missing property", Expected: "PR ", Template: "PR {{.PRNumber}}", Context: map[string]string{}},
+		{Case: "invalid template", Expected: invalidTemplate, Template: "PR {{.PRNumber}", Context: map[string]string{}},
+		{Case: "sparkline", Expected: "\u2581\u2588", Template: "{{ sparkline .Values }}", Context: map[string][]float64{"Values": {1, 2}}},
+		{Case: "execution error", Expected: incorrectTemplate, Template: "{{.PRNumber.Nope}}", Context: struct{ PRNumber string }{PRNumber: "1"}},
+	}
+	for _, tc := range cases {
+		template := &textTemplate{
+			Template: tc.Template,
+			Context:  tc.Context,
+		}
+		assert.Equal(t, tc.Expected, template.render(), tc.Case)
+	}
+}
+
+func TestTemplateCacheParsesOnce(t *testing.T) {
+	cache := newTemplateCache()
+	parse := cache.parse
+	var parsed int
+	cache.parse = func(text string) (*template.Template, error) {
+		parsed++
+		return parse(text)
+	}
+	first, err := cache.get("PR {{.PRNumber}}")
+	assert.NoError(t, err)
+	second, err := cache.get("PR {{.PRNumber}}")
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, parsed)
+	_, _ = cache.get("{{.Other}}")
+	assert.Equal(t, 2, parsed)
+}
+
+func TestTemplateCacheInvalidTemplate(t *testing.T) {
+	cache := newTemplateCache()
+	_, err := cache.get("PR {{.PRNumber}")
+	assert.Error(t, err)
+	assert.Empty(t, cache.templates)
+}
+
+func BenchmarkTemplateRender(b *testing.B) {
+	context := map[string]interface{}{"PRNumber": "123", "Values": []float64{1, 5, 2, 8}}
+	text := "PR {{ .PRNumber }} {{ sparkline .Values }}"
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			template := &textTemplate{
+				Template: text,
+				Context:  context,
+			}
+			_ = template.render()
+		}
+	})
+	b.Run("parsed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tmpl, _ := newTemplateCache().parse(text)
+			_ = tmpl.Execute(new(bytes.Buffer), context)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// transformFunc changes the output of a segment, args are the colon separated values following the name
+type transformFunc func(text string, args []string) string
+
+var transforms = map[string]transformFunc{
+	"lower": func(text string, args []string) string {
+		return mapVisibleText(text, strings.ToLower)
+	},
+	"upper": func(text string, args []string) string {
+		return mapVisibleText(text, strings.ToUpper)
+	},
+	"trim": func(text string, args []string) string {
+		return strings.TrimSpace(text)
+	},
+	"replace": func(text string, args []string) string {
+		if len(args) == 0 || args[0] == "" {
+			return text
+		}
+		var replacement string
+		if len(args) > 1 {
+			replacement = args[1]
+		}
+		return mapVisibleText(text, func(visible string) string {
+			return strings.ReplaceAll(visible, args[0], replacement)
+		})
+	},
+	"trunc": func(text string, args []string) string {
+		return truncateToWidth(text, transformWidth(args))
+	},
+	"pad": func(text string, args []string) string {
+		return padToWidth(text, transformWidth(args))
+	},
+}
+
+// transformArities is the number of arguments of the transforms which take any,
+// colons beyond those are part of the last argument: replace:10:10: inserts a colon
+var transformArities = map[string]int{
+	"replace": 2,
+	"trunc":   1,
+	"pad":     1,
+}
+
+// applyTransforms runs the transforms in order, unknown transforms are ignored.
+// A transform is written as name:arg1:arg2, e.g. replace:foo:bar
+func applyTransforms(text string, pipeline []string) string {
+	for _, step := range pipeline {
+		name, args := splitTransform(step)
+		transform, ok := transforms[name]
+		if !ok {
+			continue
+		}
+		text = transform(text, args)
+	}
+	return text
+}
+
+// splitTransform returns the name and the arguments of a transform, an escaped colon \: is part of an argument
+func splitTransform(step string) (string, []string) {
+	var elements []string
+	var element strings.Builder
+	for i := 0; i < len(step); i++ {
+		switch {
+		case step[i] == '\\' && i+1 < len(step) && step[i+1] == ':':
+			element.WriteByte(':')
+			i++
+		case step[i] == ':':
+			elements = append(elements, element.String())
+			element.Reset()
+		default:
+			element.WriteByte(step[i])
+		}
+	}
+	elements = append(elements, element.String())
+	name := strings.TrimSpace(elements[0])
+	args := elements[1:]
+	if arity, ok := transformArities[name]; ok && len(args) > arity {
+		args = append(args[:arity-1], strings.Join(args[arity-1:], ":"))
+	}
+	return name, args
+}
+
+// mapVisibleText applies the mapping to the displayed text only, leaving color overrides untouched
+func mapVisibleText(text string, mapping func(string) string) string {
+	var builder strings.Builder
+	for _, part := range splitVisibleText(text) {
+		if part.visible {
+			builder.WriteString(mapping(part.text))
+			continue
+		}
+		builder.WriteString(part.text)
+	}
+	return builder.String()
+}
+
+func transformWidth(args []string) int {
+	if len(args) == 0 {
+		return 0
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(args[0]))
+	if err != nil {
+		return 0
+	}
+	return width
+}
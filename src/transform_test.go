@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	cases := []struct {
+		Case       string
+		Text       string
+		Transforms []string
+		Expected   string
+	}{
+		{Case: "Pipeline", Text: "Feature/FOO-Branch", Transforms: []string{"lower", "replace:foo:bar", "trunc:12"}, Expected: "feature/bar\u2026"},
+		{Case: "Order matters", Text: "Feature/FOO-Branch", Transforms: []string{"replace:foo:bar", "lower"}, Expected: "feature/foo-branch"},
+		{Case: "Colors untouched", Text: "<lightRed>Main</>", Transforms: []string{"upper"}, Expected: "<lightRed>MAIN</>"},
+		{Case: "Replace with nothing", Text: "refs/heads/main", Transforms: []string{"replace:refs/heads/"}, Expected: "main"},
+		{Case: "Trim and pad", Text: " main ", Transforms: []string{"trim", "pad:6"}, Expected: "main  "},
+		{Case: "Unknown transform", Text: "main", Transforms: []string{"reverse", "upper"}, Expected: "MAIN"},
+		{Case: "Invalid argument", Text: "main", Transforms: []string{"trunc:abc"}, Expected: "main"},
+		{Case: "No transforms", Text: "main", Expected: "main"},
+		{Case: "Colon in the replacement", Text: "1030", Transforms: []string{"replace:10:10:"}, Expected: "10:30"},
+		{Case: "Escaped colon", Text: "C:/Users/jan", Transforms: []string{"replace:C\\:/Users:~"}, Expected: "~/jan"},
+		{Case: "Escaped colons", Text: "10:30", Transforms: []string{"replace:\\::h"}, Expected: "10h30"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, applyTransforms(tc.Text, tc.Transforms), tc.Case)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	cases := []struct {
+		Bytes    uint64
+		Expected string
+	}{
+		{Bytes: 0, Expected: "0B"},
+		{Bytes: 1023, Expected: "1023B"},
+		{Bytes: 1024, Expected: "1.0KB"},
+		{Bytes: 1536, Expected: "1.5KB"},
+		{Bytes: 5 * 1024 * 1024, Expected: "5.0MB"},
+		{Bytes: 2254857830, Expected: "2.1GB"},
+		{Bytes: 3 * 1024 * 1024 * 1024 * 1024, Expected: "3.0TB"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, formatBytes(tc.Bytes))
+	}
+}
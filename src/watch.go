@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	clearScreen   = "\x1b[2J\x1b[H"
+)
+
+// fileWatcher polls the modification time of a file to detect changes
+type fileWatcher struct {
+	path    string
+	modTime time.Time
+	stat    func(name string) (os.FileInfo, error)
+}
+
+func newFileWatcher(path string) *fileWatcher {
+	fw := &fileWatcher{
+		path: path,
+		stat: os.Stat,
+	}
+	_ = fw.changed()
+	return fw
+}
+
+// changed reports whether the file was modified since the previous call,
+// a file which can't be read is considered unchanged
+func (fw *fileWatcher) changed() bool {
+	info, err := fw.stat(fw.path)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(fw.modTime) {
+		return false
+	}
+	fw.modTime = info.ModTime()
+	return true
+}
+
+// watchConfig renders the prompt and renders it again on a clear screen
+// every time the config file changes, until the process is interrupted
+func watchConfig(config string, render func()) {
+	if config == "" {
+		fmt.Println("Add the path to the configuration you want to watch using --config")
+		return
+	}
+	watcher := newFileWatcher(config)
+	for {
+		fmt.Print(clearScreen)
+		render()
+		for !watcher.changed() {
+			time.Sleep(watchInterval)
+		}
+	}
+}
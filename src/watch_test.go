@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileWatcherChanged(t *testing.T) {
+	file, err := ioutil.TempFile("", "omp-watch-*.json")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.Close()
+	watcher := newFileWatcher(file.Name())
+	assert.False(t, watcher.changed(), "untouched file")
+	modified := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(file.Name(), modified, modified))
+	assert.True(t, watcher.changed(), "modified file")
+	assert.False(t, watcher.changed(), "change already reported")
+}
+
+func TestFileWatcherMissingFile(t *testing.T) {
+	watcher := newFileWatcher("/no/such/config.json")
+	assert.False(t, watcher.changed())
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ellipsis = "\u2026"
+)
+
+// invisibleText matches the color overrides and ANSI sequences which take no space in the terminal
+var invisibleText = regexp.MustCompile(`</>|<#?\w+(,#?\w+)?>|<,#?\w+>|[\x1b\x{9b}][[\]()#;?]*(?:(?:(?:[a-zA-Z\d]*(?:;[a-zA-Z\d]*)*)?\x07)|(?:(?:\d{1,4}(?:;\d{0,4})*)?[\dA-PRZcf-ntqry=><~]))`)
+
+// textPart is either visible text or a sequence which isn't displayed
+type textPart struct {
+	text    string
+	visible bool
+}
+
+func splitVisibleText(text string) []*textPart {
+	var parts []*textPart
+	previous := 0
+	for _, match := range invisibleText.FindAllStringIndex(text, -1) {
+		if match[0] > previous {
+			parts = append(parts, &textPart{text: text[previous:match[0]], visible: true})
+		}
+		parts = append(parts, &textPart{text: text[match[0]:match[1]]})
+		previous = match[1]
+	}
+	if previous < len(text) {
+		parts = append(parts, &textPart{text: text[previous:], visible: true})
+	}
+	return parts
+}
+
+// visibleWidth returns the number of characters of the text displayed in the terminal
+func visibleWidth(text string) int {
+	var width int
+	for _, part := range splitVisibleText(text) {
+		if part.visible {
+			width += utf8.RuneCountInString(part.text)
+		}
+	}
+	return width
+}
+
+// padToWidth appends spaces until the visible text is at least width characters wide
+func padToWidth(text string, width int) string {
+	missing := width - visibleWidth(text)
+	if missing <= 0 {
+		return text
+	}
+	return text + strings.Repeat(" ", missing)
+}
+
+// truncateToWidth cuts the visible text to width characters, ending in an ellipsis.
+// A color override which is cut off is closed to keep the colors of what follows intact
+func truncateToWidth(text string, width int) string {
+	if width <= 0 || visibleWidth(text) <= width {
+		return text
+	}
+	// keep room for the ellipsis
+	remaining := width - 1
+	colorOpen := false
+	var builder strings.Builder
+	for _, part := range splitVisibleText(text) {
+		if !part.visible {
+			builder.WriteString(part.text)
+			switch {
+			case part.text == "</>":
+				colorOpen = false
+			case strings.HasPrefix(part.text, "<"):
+				colorOpen = true
+			}
+			continue
+		}
+		runes := []rune(part.text)
+		if len(runes) <= remaining {
+			builder.WriteString(part.text)
+			remaining -= len(runes)
+			continue
+		}
+		builder.WriteString(string(runes[:remaining]))
+		break
+	}
+	builder.WriteString(ellipsis)
+	if colorOpen {
+		builder.WriteString("</>")
+	}
+	return builder.String()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisibleWidth(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Text     string
+		Expected int
+	}{
+		{Case: "Plain", Text: "main", Expected: 4},
+		{Case: "Color override", Text: "<#ff0000>main</> \uF044", Expected: 6},
+		{Case: "Background override", Text: "<,#ff0000>main</>", Expected: 4},
+		{Case: "ANSI", Text: "\x1b[31mmain\x1b[0m", Expected: 4},
+		{Case: "Not a color", Text: "a < b", Expected: 5},
+		{Case: "Empty", Text: "", Expected: 0},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, visibleWidth(tc.Text), tc.Case)
+	}
+}
+
+func TestPadToWidth(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Text     string
+		Width    int
+		Expected string
+	}{
+		{Case: "Short", Text: "main", Width: 8, Expected: "main    "},
+		{Case: "Short with colors", Text: "<#ff0000>main</>", Width: 6, Expected: "<#ff0000>main</>  "},
+		{Case: "Exact", Text: "main", Width: 4, Expected: "main"},
+		{Case: "Long", Text: "feature", Width: 4, Expected: "feature"},
+		{Case: "Disabled", Text: "main", Expected: "main"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, padToWidth(tc.Text, tc.Width), tc.Case)
+	}
+}
+
+func TestTruncateToWidth(t *testing.T) {
+	cases := []struct {
+		Case     string
+		Text     string
+		Width    int
+		Expected string
+	}{
+		{Case: "Long", Text: "feature/width", Width: 8, Expected: "feature\u2026"},
+		{Case: "Inside color override", Text: "on <#ff0000>feature/width</> now", Width: 8, Expected: "on <#ff0000>feat\u2026</>"},
+		{Case: "After color override", Text: "<#ff0000>on</> feature", Width: 6, Expected: "<#ff0000>on</> fe\u2026"},
+		{Case: "Multibyte", Text: "\uF044\uF046\uF071\uF00C", Width: 3, Expected: "\uF044\uF046\u2026"},
+		{Case: "Short", Text: "main", Width: 8, Expected: "main"},
+		{Case: "Disabled", Text: "feature/width", Expected: "feature/width"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.Expected, truncateToWidth(tc.Text, tc.Width), tc.Case)
+	}
+}